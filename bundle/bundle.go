@@ -0,0 +1,218 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bundle contains implementation of portable content bundle. Bundle
+// is one file containing parsed rule content, groups, parse status of all
+// rules and a manifest describing the bundle. It can be consumed offline by
+// other services in their tests and it can be served by content service
+// without access to the content files.
+package bundle
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/ghodss/yaml"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/groups"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+)
+
+// Bundle formats
+const (
+	FormatGob  = "gob"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// FormatVersion is version of bundle structure, it needs to be increased
+// whenever the structure changes in incompatible way
+const FormatVersion = 1
+
+// ErrContentHashMismatch is returned when content stored in bundle does not
+// match content hash in the bundle manifest
+var ErrContentHashMismatch = errors.New("bundle content does not match content hash in its manifest")
+
+// Manifest describes content of the bundle
+type Manifest struct {
+	// FormatVersion is version of bundle structure
+	FormatVersion int `json:"format_version"`
+	// CreatedAt is time the bundle has been created
+	CreatedAt time.Time `json:"created_at"`
+	// ServiceVersion is version of content service the bundle has been
+	// created by
+	ServiceVersion string `json:"service_version"`
+	// RulesVersion is version of rules the content has been read from
+	RulesVersion string `json:"rules_version"`
+	// ContentHash is SHA-256 hash of the rule content, it is the same as
+	// hash of content snapshot served by content service
+	ContentHash string `json:"content_hash"`
+	// Rules is number of parsed rules
+	Rules int `json:"rules"`
+	// InvalidRules is number of rules that failed to parse
+	InvalidRules int `json:"invalid_rules"`
+	// Groups is number of groups
+	Groups int `json:"groups"`
+	// Source is signed manifest the content files have been verified
+	// against, it is not set when the content has not been verified. It is
+	// informative only, the signature can't be checked again.
+	Source *manifest.Verified `json:"source,omitempty"`
+}
+
+// Bundle contains parsed content together with groups and parse status
+type Bundle struct {
+	Manifest Manifest                           `json:"manifest"`
+	Content  content.RuleContentDirectory       `json:"content"`
+	Groups   map[string]groups.Group            `json:"groups"`
+	Status   map[string]types.RuleContentStatus `json:"status"`
+}
+
+// New constructs bundle of provided content and computes its manifest
+func New(contentDir content.RuleContentDirectory, statusMap map[string]types.RuleContentStatus,
+	groupsMap map[string]groups.Group) (*Bundle, error) {
+	hash, err := content.Hash(contentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidRules := 0
+	for _, status := range statusMap {
+		if !status.Loaded {
+			invalidRules++
+		}
+	}
+
+	return &Bundle{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			CreatedAt:     time.Now().UTC(),
+			ContentHash:   hash,
+			Rules:         len(contentDir.Rules),
+			InvalidRules:  invalidRules,
+			Groups:        len(groupsMap),
+		},
+		Content: contentDir,
+		Groups:  groupsMap,
+		Status:  statusMap,
+	}, nil
+}
+
+// FormatFromPath function returns bundle format according to file extension
+func FormatFromPath(path string) (string, error) {
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".gob":
+		return FormatGob, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown bundle format of file '%s'", path)
+	}
+}
+
+// Encode method writes the bundle in selected format
+func (bundle *Bundle) Encode(writer io.Writer, format string) error {
+	switch format {
+	case FormatGob:
+		return gob.NewEncoder(writer).Encode(bundle)
+	case FormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(bundle)
+	case FormatYAML:
+		// YAML is produced from JSON encoding, so it has the same structure
+		// and nil slices are not turned into empty ones
+		encoded, err := yaml.Marshal(bundle)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(encoded)
+		return err
+	default:
+		return fmt.Errorf("unknown bundle format '%s'", format)
+	}
+}
+
+// Decode function reads bundle in selected format
+func Decode(reader io.Reader, format string) (*Bundle, error) {
+	var bundle Bundle
+
+	switch format {
+	case FormatGob:
+		if err := gob.NewDecoder(reader).Decode(&bundle); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if err := json.NewDecoder(reader).Decode(&bundle); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		encoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(encoded, &bundle); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown bundle format '%s'", format)
+	}
+
+	if bundle.Manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", bundle.Manifest.FormatVersion)
+	}
+
+	// content hash stored by the exporter is not trusted, content might have
+	// been changed after the bundle has been written
+	hash, err := content.Hash(bundle.Content)
+	if err != nil {
+		return nil, err
+	}
+	if hash != bundle.Manifest.ContentHash {
+		return nil, ErrContentHashMismatch
+	}
+
+	return &bundle, nil
+}
+
+// Load function reads bundle from file, format is selected by file
+// extension
+func Load(path string) (*Bundle, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return Decode(file, format)
+}
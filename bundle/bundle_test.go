@@ -0,0 +1,133 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/bundle"
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/groups"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+)
+
+// newBundle constructs bundle of test content and groups
+func newBundle(t *testing.T) *bundle.Bundle {
+	contentDir, statusMap, err := content.ParseRuleContentDir("../tests/content/ok")
+	assert.NoError(t, err)
+
+	groupsMap, err := groups.ParseGroupConfigFile("../groups_config.yaml")
+	assert.NoError(t, err)
+
+	contentBundle, err := bundle.New(contentDir, statusMap, groupsMap)
+	assert.NoError(t, err)
+	return contentBundle
+}
+
+// TestNew checks the bundle manifest
+func TestNew(t *testing.T) {
+	contentBundle := newBundle(t)
+
+	hash, err := content.Hash(contentBundle.Content)
+	assert.NoError(t, err)
+
+	assert.Equal(t, bundle.FormatVersion, contentBundle.Manifest.FormatVersion)
+	assert.Equal(t, hash, contentBundle.Manifest.ContentHash)
+	assert.Equal(t, 2, contentBundle.Manifest.Rules)
+	assert.Equal(t, 0, contentBundle.Manifest.InvalidRules)
+	assert.Equal(t, len(contentBundle.Groups), contentBundle.Manifest.Groups)
+	assert.NotZero(t, contentBundle.Manifest.Groups)
+}
+
+// TestEncodeDecode checks that bundle can be read in all supported formats
+func TestEncodeDecode(t *testing.T) {
+	contentBundle := newBundle(t)
+	contentBundle.Manifest.RulesVersion = "1.0.0"
+	contentBundle.Manifest.Source = &manifest.Verified{
+		Manifest: manifest.Manifest{Files: map[string]string{"config.yaml": "0123"}},
+		Signer:   "rules-team",
+	}
+
+	for _, format := range []string{bundle.FormatGob, bundle.FormatJSON, bundle.FormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			assert.NoError(t, contentBundle.Encode(buffer, format))
+
+			decoded, err := bundle.Decode(buffer, format)
+			assert.NoError(t, err)
+			assert.Equal(t, contentBundle.Content, decoded.Content)
+			assert.Equal(t, contentBundle.Groups, decoded.Groups)
+			assert.Equal(t, contentBundle.Status, decoded.Status)
+			assert.Equal(t, contentBundle.Manifest.ContentHash, decoded.Manifest.ContentHash)
+			assert.Equal(t, "1.0.0", decoded.Manifest.RulesVersion)
+			assert.True(t, contentBundle.Manifest.CreatedAt.Equal(decoded.Manifest.CreatedAt))
+			assert.Equal(t, "rules-team", decoded.Manifest.Source.Signer)
+		})
+	}
+}
+
+// TestEncodeUnknownFormat checks that unknown format is refused
+func TestEncodeUnknownFormat(t *testing.T) {
+	assert.Error(t, newBundle(t).Encode(new(bytes.Buffer), "xml"))
+
+	_, err := bundle.Decode(new(bytes.Buffer), "xml")
+	assert.Error(t, err)
+}
+
+// TestDecodeUnsupportedVersion checks that bundle of unknown format
+// version is refused
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	_, err := bundle.Decode(bytes.NewBufferString(`{"manifest": {"format_version": 2}}`), bundle.FormatJSON)
+	assert.EqualError(t, err, "unsupported bundle format version 2")
+}
+
+// TestDecodeContentHashMismatch checks that bundle with content changed
+// after it has been exported is refused
+func TestDecodeContentHashMismatch(t *testing.T) {
+	contentBundle := newBundle(t)
+	rule := contentBundle.Content.Rules["rule1"]
+	rule.Summary = "changed summary"
+	contentBundle.Content.Rules["rule1"] = rule
+
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, contentBundle.Encode(buffer, bundle.FormatJSON))
+
+	_, err := bundle.Decode(buffer, bundle.FormatJSON)
+	assert.ErrorIs(t, err, bundle.ErrContentHashMismatch)
+}
+
+// TestLoad checks that format is selected by file extension
+func TestLoad(t *testing.T) {
+	contentBundle := newBundle(t)
+
+	path := filepath.Join(t.TempDir(), "content.yml")
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, contentBundle.Encode(buffer, bundle.FormatYAML))
+	assert.NoError(t, os.WriteFile(path, buffer.Bytes(), 0o600))
+
+	loaded, err := bundle.Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, contentBundle.Content, loaded.Content)
+
+	_, err = bundle.Load(filepath.Join(t.TempDir(), "content.txt"))
+	assert.Error(t, err)
+}
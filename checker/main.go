@@ -66,35 +66,12 @@ func initLogger() {
 // checkGroupConfig reads the group configuration file and performs defined checks on it.
 // Then it returns the config to be used by the rule content checks.
 func checkGroupConfig() groupConfigMap {
-	groupCfg, err := groups.ParseGroupConfigFile(groupConfigPath)
+	groupCfg, findings, err := groups.ValidateConfigFile(groupConfigPath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to parse group config file")
 	}
 
-	// Unique group is just a check that makes sure no two groups have the same name property.
-	uniqueGroups := map[string]string{}
-
-	// For each group defined in the group configuration file.
-	for groupKey, group := range groupCfg {
-		if firstGroupKey, exists := uniqueGroups[group.Name]; exists {
-			log.Warn().Msgf("multiple groups with the name '%s' (first with key '%s', but also with key '%s')", group.Name, firstGroupKey, groupKey)
-		} else {
-			uniqueGroups[group.Name] = groupKey
-		}
-
-		// Check for duplicate tag in a single group.
-		// The same tag being used by multiple groups is allowed.
-		uniqueTags := map[string]struct{}{}
-
-		// For each tag assigned to the group.
-		for _, tag := range group.Tags {
-			if _, exists := uniqueTags[tag]; exists {
-				log.Warn().Str("tag", tag).Str("group", group.Name).Msg("duplicate tag reference in group")
-			} else {
-				uniqueTags[tag] = struct{}{}
-			}
-		}
-	}
+	groups.LogFindings(findings)
 
 	return groupCfg
 }
@@ -137,6 +114,18 @@ func checkRuleContent(groupCfg groupConfigMap) {
 			checkErrorCodeTags(groupCfg, ruleName, errCode, errContent)
 		}
 	}
+
+	checkOrphanedTags(groupCfg, ruleContentDir)
+}
+
+// checkOrphanedTags reports tags that are used by content but not included in
+// any group, as well as tags declared in groups but never used.
+func checkOrphanedTags(groupCfg groupConfigMap, ruleContentDir content.RuleContentDirectory) {
+	for _, tag := range groups.TagsCatalog(groupCfg, ruleContentDir) {
+		if tag.Orphaned {
+			log.Warn().Str("tag", tag.Name).Str("reason", tag.OrphanReason).Msg("orphaned tag")
+		}
+	}
 }
 
 // checkErrorCodeTags checks that the tags referenced by the error code are valid.
@@ -0,0 +1,341 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/RedHatInsights/insights-content-service/bundle"
+	"github.com/RedHatInsights/insights-content-service/conf"
+	"github.com/RedHatInsights/insights-content-service/contentdiff"
+)
+
+// commandOptions contains values of all command flags, each command
+// registers just the flags it understands
+type commandOptions struct {
+	configFile  string
+	contentPath string
+	rule        string
+	ruleType    string
+	failedOnly  bool
+	format      string
+	out         string
+	json        bool
+}
+
+// command describes one subcommand of the CLI
+type command struct {
+	// name is the name used on command line
+	name string
+	// aliases are other names of the command
+	aliases []string
+	// arguments describes positional arguments in help message
+	arguments string
+	// description is shown in help messages, it might have more lines
+	description string
+	// flags registers command specific flags
+	flags func(flags *flag.FlagSet, options *commandOptions)
+	// run executes the command
+	run func(options *commandOptions, args []string) ExitCode
+}
+
+// commands function returns all commands understood by the CLI in order
+// they are shown in help message
+func commands() []command {
+	return []command{
+		{
+			name:        "start-service",
+			description: "starts content service",
+			flags:       contentPathFlag,
+			run: func(*commandOptions, []string) ExitCode {
+				logVersionInfo()
+				return startService()
+			},
+		},
+		{
+			name:        "help",
+			aliases:     []string{"print-help"},
+			arguments:   "[command]",
+			description: "prints help, use 'help <command>' to get help for one command",
+			run: func(_ *commandOptions, args []string) ExitCode {
+				if len(args) > 0 {
+					return printCommandHelp(args[0])
+				}
+				return printHelp()
+			},
+		},
+		{
+			name:        "print-config",
+			description: "prints current configuration set by files & env variables",
+			run: func(*commandOptions, []string) ExitCode {
+				return printConfig(&conf.Config)
+			},
+		},
+		{
+			name:        "print-groups",
+			description: "prints current groups configuration",
+			run: func(*commandOptions, []string) ExitCode {
+				return printGroups()
+			},
+		},
+		{
+			name:        "print-rules",
+			description: "prints current parsed rules",
+			flags: func(flags *flag.FlagSet, options *commandOptions) {
+				contentPathFlag(flags, options)
+				ruleFlags(flags, options)
+				formatFlag(flags, options, outputFormatJSON,
+					outputFormatJSON, outputFormatYAML, outputFormatTable, outputFormatTree)
+			},
+			run: func(options *commandOptions, _ []string) ExitCode {
+				return printRules(options.rule, options.ruleType, options.format)
+			},
+		},
+		{
+			name:        "print-parse-status",
+			description: "prints information about all rules that have been parsed",
+			flags: func(flags *flag.FlagSet, options *commandOptions) {
+				contentPathFlag(flags, options)
+				ruleFlags(flags, options)
+				flags.BoolVar(&options.failedOnly, "failed-only", false, "print only rules that failed to parse")
+				formatFlag(flags, options, outputFormatJSON,
+					outputFormatJSON, outputFormatYAML, outputFormatTable, outputFormatTree)
+			},
+			run: func(options *commandOptions, _ []string) ExitCode {
+				return printParseStatus(options.rule, options.ruleType, options.failedOnly, options.format)
+			},
+		},
+		{
+			name:        "export-content",
+			description: "writes parsed rules, groups and parse status into one bundle",
+			flags: func(flags *flag.FlagSet, options *commandOptions) {
+				contentPathFlag(flags, options)
+				formatFlag(flags, options, bundle.FormatJSON, bundle.FormatGob, bundle.FormatJSON, bundle.FormatYAML)
+				flags.StringVar(&options.out, "out", "-", "output file, '-' means standard output")
+			},
+			run: func(options *commandOptions, _ []string) ExitCode {
+				return exportContent(options.format, options.out)
+			},
+		},
+		{
+			name:        "diff-content",
+			arguments:   "<old-dir> <new-dir>",
+			description: "compares two rule content directories and reports changes\nvisible to customers",
+			flags: func(flags *flag.FlagSet, options *commandOptions) {
+				formatFlag(flags, options, contentdiff.FormatText,
+					contentdiff.FormatText, contentdiff.FormatJSON, contentdiff.FormatMarkdown)
+			},
+			run: func(options *commandOptions, args []string) ExitCode {
+				return diffContent(args, options.format)
+			},
+		},
+		{
+			name:        "print-version-info",
+			description: "prints version info",
+			run: func(*commandOptions, []string) ExitCode {
+				return printVersionInfo()
+			},
+		},
+		{
+			name:        "validate-config",
+			description: "checks the whole configuration and reports all problems",
+			flags: func(flags *flag.FlagSet, options *commandOptions) {
+				contentPathFlag(flags, options)
+				formatFlag(flags, options, outputFormatHuman, outputFormatHuman, outputFormatJSON)
+				flags.BoolVar(&options.json, "json", false, "same as --format json")
+			},
+			run: func(options *commandOptions, args []string) ExitCode {
+				format := options.format
+				// format used to be selected by the first argument
+				if len(args) > 0 {
+					format = args[0]
+				}
+				if options.json {
+					format = outputFormatJSON
+				}
+				return validateConfig(&conf.Config, format)
+			},
+		},
+	}
+}
+
+// contentPathFlag function registers flag overriding path to rule content
+func contentPathFlag(flags *flag.FlagSet, options *commandOptions) {
+	flags.StringVar(&options.contentPath, "content-path", "", "path to rule content directory, overrides configuration")
+}
+
+// ruleFlags function registers flags selecting rules
+func ruleFlags(flags *flag.FlagSet, options *commandOptions) {
+	flags.StringVar(&options.rule, "rule", "", "select only rule with given name")
+	flags.StringVar(&options.ruleType, "type", "", "select only rules of given type: external or internal")
+}
+
+// formatFlag function registers flag selecting output format
+func formatFlag(flags *flag.FlagSet, options *commandOptions, defaultFormat string, formats ...string) {
+	flags.StringVar(&options.format, "format", defaultFormat, "output format: "+strings.Join(formats, ", "))
+}
+
+// findCommand function finds command by its name or alias
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet function constructs set of all flags understood by the command
+func newFlagSet(cmd command, options *commandOptions, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&options.configFile, "config", "", "configuration file, overrides the default one")
+	if cmd.flags != nil {
+		cmd.flags(flags, options)
+	}
+	flags.Usage = func() {
+		writeCommandHelp(flags.Output(), cmd, flags)
+	}
+	return flags
+}
+
+// parseFlags function parses command flags that might be mixed with
+// positional arguments and returns the positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// commandConfigFile function returns configuration file selected by flag of
+// the command. Problems with flags are not reported, because they are
+// reported when the command is handled.
+func commandConfigFile(name string, args []string) string {
+	cmd, found := findCommand(name)
+	if !found {
+		return ""
+	}
+
+	options := commandOptions{}
+	if _, err := parseFlags(newFlagSet(cmd, &options, io.Discard), args); err != nil {
+		return ""
+	}
+	return options.configFile
+}
+
+// handleCommand function parses flags of selected command and runs it
+func handleCommand(name string, args ...string) ExitCode {
+	cmd, found := findCommand(name)
+	if !found {
+		fmt.Printf("\nCommand '%v' not found\n", name)
+		return printHelp()
+	}
+
+	options := commandOptions{}
+	positional, err := parseFlags(newFlagSet(cmd, &options, os.Stdout), args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitStatusOK
+	}
+	if err != nil {
+		return ExitStatusOther
+	}
+
+	if options.contentPath != "" {
+		conf.SetContentPath(options.contentPath)
+	}
+
+	return cmd.run(&options, positional)
+}
+
+const helpMessageHeader = `
+Service to provide content for OCP rules
+
+Usage:
+
+    %+v [command] [flags]
+
+The commands are:
+
+    <EMPTY>             starts content service
+`
+
+const helpMessageFooter = `
+Use '%+v help <command>' to get description of all command flags.
+
+`
+
+// descriptionIndent is used to align command descriptions in help message
+const descriptionIndent = "                        "
+
+func printHelp() ExitCode {
+	fmt.Printf(helpMessageHeader, os.Args[0])
+	for _, cmd := range commands() {
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			description := strings.ReplaceAll(cmd.description, "\n", "\n"+descriptionIndent)
+			fmt.Printf("    %-20s%s\n", name, description)
+		}
+	}
+	fmt.Printf(helpMessageFooter, os.Args[0])
+	return ExitStatusOK
+}
+
+// printCommandHelp function prints help message of one command including
+// all its flags
+func printCommandHelp(name string) ExitCode {
+	cmd, found := findCommand(name)
+	if !found {
+		fmt.Printf("\nCommand '%v' not found\n", name)
+		return printHelp()
+	}
+
+	writeCommandHelp(os.Stdout, cmd, newFlagSet(cmd, &commandOptions{}, os.Stdout))
+	return ExitStatusOK
+}
+
+// writeCommandHelp function writes usage, description and flags of the
+// command
+func writeCommandHelp(out io.Writer, cmd command, flags *flag.FlagSet) {
+	usage := cmd.name + " [flags]"
+	if cmd.arguments != "" {
+		usage += " " + cmd.arguments
+	}
+
+	fmt.Fprintf(out, "\nUsage:\n\n    %s %s\n\n", os.Args[0], usage)
+	fmt.Fprintf(out, "%s\n\nThe flags are:\n\n", strings.ReplaceAll(cmd.description, "\n", " "))
+	flags.SetOutput(out)
+	flags.PrintDefaults()
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/tisnik/go-capture"
+
+	main "github.com/RedHatInsights/insights-content-service"
+	"github.com/RedHatInsights/insights-content-service/conf"
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// resetContentPath restores content path changed by --content-path flag
+func resetContentPath() {
+	conf.SetContentPath("")
+	conf.Config.Content.ContentPath = ""
+}
+
+// TestPrintHelpAllCommands checks that all commands are listed in help
+func TestPrintHelpAllCommands(t *testing.T) {
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusOK, int(main.PrintHelp()))
+	})
+	checkStandardOutputStatus(t, err)
+
+	for _, command := range []string{"start-service", "help", "print-help", "print-config", "print-groups",
+		"print-rules", "print-parse-status", "export-content", "diff-content", "print-version-info",
+		"validate-config"} {
+		assert.Contains(t, captured, "\n    "+command+" ")
+	}
+}
+
+// TestHandleCommandHelpForCommand checks help of one command
+func TestHandleCommandHelpForCommand(t *testing.T) {
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusOK, int(main.HandleCommand("help", "print-parse-status")))
+	})
+	checkStandardOutputStatus(t, err)
+
+	assert.Contains(t, captured, " print-parse-status [flags]\n")
+	assert.Contains(t, captured, "prints information about all rules that have been parsed")
+	for _, flag := range []string{"-config", "-content-path", "-rule", "-type", "-failed-only", "-format"} {
+		assert.Contains(t, captured, "\n  "+flag)
+	}
+
+	// the same help is printed by --help flag
+	capturedFlag, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusOK, int(main.HandleCommand("print-parse-status", "--help")))
+	})
+	checkStandardOutputStatus(t, err)
+	assert.Equal(t, captured, capturedFlag)
+}
+
+// TestHandleCommandInvalidFlag checks that unknown flags are refused
+func TestHandleCommandInvalidFlag(t *testing.T) {
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusOther, int(main.HandleCommand("print-version-info", "--rule", "rule1")))
+	})
+	checkStandardOutputStatus(t, err)
+	assert.True(t, strings.HasPrefix(captured, "flag provided but not defined: -rule\n"))
+}
+
+// TestHandleCommandPrintParseStatus checks filters of print-parse-status
+// command
+func TestHandleCommandPrintParseStatus(t *testing.T) {
+	defer resetContentPath()
+
+	captured, err := capture.StandardOutput(func() {
+		retval := main.HandleCommand("print-parse-status", "--content-path", "tests/content/missing",
+			"--failed-only", "--type", "internal")
+		assert.Equal(t, main.ExitStatusOK, int(retval))
+	})
+	checkStandardOutputStatus(t, err)
+
+	var parseStatus map[string]ctypes.RuleContentStatus
+	assert.NoError(t, json.Unmarshal([]byte(captured), &parseStatus))
+	assert.Equal(t, map[string]ctypes.RuleContentStatus{
+		"rule3": {RuleType: "internal", Loaded: false, Error: "Missing required file: metadata.yaml"},
+	}, parseStatus)
+}
+
+// TestHandleCommandPrintRules checks rule filter and YAML output of
+// print-rules command
+func TestHandleCommandPrintRules(t *testing.T) {
+	defer resetContentPath()
+
+	captured, err := capture.StandardOutput(func() {
+		retval := main.HandleCommand("print-rules", "--content-path", "tests/content/ok",
+			"--rule", "rule1", "--format", "yaml")
+		assert.Equal(t, main.ExitStatusOK, int(retval))
+	})
+	checkStandardOutputStatus(t, err)
+
+	var contentDir content.RuleContentDirectory
+	assert.NoError(t, yaml.Unmarshal([]byte(captured), &contentDir))
+	assert.Len(t, contentDir.Rules, 1)
+	assert.Contains(t, contentDir.Rules, "rule1")
+}
+
+// TestPrintParseStatusInvalidFilter checks that unknown rule and rule type
+// are refused
+func TestPrintParseStatusInvalidFilter(t *testing.T) {
+	conf.Config.Content.ContentPath = "tests/content/ok"
+	defer resetContentPath()
+
+	assert.Equal(t, main.ExitStatusOther, int(main.PrintParseStatus("xyzzy", "", false, "json")))
+	assert.Equal(t, main.ExitStatusOther, int(main.PrintParseStatus("", "ocs", false, "json")))
+	assert.Equal(t, main.ExitStatusOther, int(main.PrintParseStatus("", "", false, "xml")))
+}
+
+// TestCommandConfigFile checks that configuration file can be selected by
+// flag of any command
+func TestCommandConfigFile(t *testing.T) {
+	assert.Equal(t, "tests/config.toml", main.CommandConfigFile("print-rules", []string{"--rule", "rule1",
+		"--config", "tests/config.toml"}))
+	assert.Equal(t, "tests/config.toml", main.CommandConfigFile("diff-content", []string{"old", "new",
+		"--config=tests/config.toml"}))
+	assert.Equal(t, "", main.CommandConfigFile("print-rules", nil))
+	assert.Equal(t, "", main.CommandConfigFile("foo-bar-baz", []string{"--config", "tests/config.toml"}))
+	assert.Equal(t, "", main.CommandConfigFile("print-rules", []string{"--xyzzy", "--config", "tests/config.toml"}))
+}
+
+// TestHandleCommandValidateConfigFormats checks all ways to select format
+// of validate-config command
+func TestHandleCommandValidateConfigFormats(t *testing.T) {
+	for _, args := range [][]string{{"json"}, {"--json"}, {"--format", "json"}} {
+		captured, err := capture.StandardOutput(func() {
+			main.HandleCommand("validate-config", args...)
+		})
+		checkStandardOutputStatus(t, err)
+		assert.True(t, strings.HasPrefix(captured, "{"), args)
+	}
+}
+
+// TestHandleCommandPrintParseStatusTable checks table output of
+// print-parse-status command
+func TestHandleCommandPrintParseStatusTable(t *testing.T) {
+	defer resetContentPath()
+
+	captured, err := capture.StandardOutput(func() {
+		retval := main.HandleCommand("print-parse-status", "--content-path", "tests/content/missing",
+			"--format", "table")
+		assert.Equal(t, main.ExitStatusOK, int(retval))
+	})
+	checkStandardOutputStatus(t, err)
+
+	assert.Equal(t, "RULE   TYPE      LOADED  ERROR KEYS  ERROR\n"+
+		"rule2  external  no      0           Missing required file: metadata.yaml\n"+
+		"rule3  internal  no      0           Missing required file: metadata.yaml\n"+
+		"\n2 rules, 0 loaded, 2 failed\n", captured)
+}
+
+// TestHandleCommandPrintRulesTree checks tree output of print-rules command
+func TestHandleCommandPrintRulesTree(t *testing.T) {
+	defer resetContentPath()
+
+	captured, err := capture.StandardOutput(func() {
+		retval := main.HandleCommand("print-rules", "--content-path", "tests/content/ok", "--format", "tree")
+		assert.Equal(t, main.ExitStatusOK, int(retval))
+	})
+	checkStandardOutputStatus(t, err)
+
+	assert.Equal(t, "external\n"+
+		"└── rule1 (1 error keys)\n"+
+		"    └── err_key\n"+
+		"internal\n"+
+		"└── rule2 (1 error keys)\n"+
+		"    └── err_key\n"+
+		"\n2 rules, 2 loaded, 0 failed\n", captured)
+}
+
+// TestHandleCommandPrintParseStatusSortedJSON checks that JSON output is
+// indented and keys are sorted
+func TestHandleCommandPrintParseStatusSortedJSON(t *testing.T) {
+	defer resetContentPath()
+
+	captured, err := capture.StandardOutput(func() {
+		retval := main.HandleCommand("print-parse-status", "--content-path", "tests/content/missing",
+			"--rule", "rule2")
+		assert.Equal(t, main.ExitStatusOK, int(retval))
+	})
+	checkStandardOutputStatus(t, err)
+
+	assert.Equal(t, "{\n"+
+		"    \"rule2\": {\n"+
+		"        \"error\": \"Missing required file: metadata.yaml\",\n"+
+		"        \"loaded\": false,\n"+
+		"        \"type\": \"external\"\n"+
+		"    }\n"+
+		"}\n", captured)
+}
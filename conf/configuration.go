@@ -36,6 +36,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/RedHatInsights/insights-operator-utils/logger"
@@ -43,18 +44,32 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
+	"github.com/RedHatInsights/insights-content-service/content"
 	"github.com/RedHatInsights/insights-content-service/groups"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+	"github.com/RedHatInsights/insights-content-service/producer"
 	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/source"
+	"github.com/RedHatInsights/insights-content-service/webhooks"
 )
 
 const (
 	configFileEnvVariableName = "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE"
-	defaultContentPath        = "/rules-content"
+	// clowderConfigEnvVariableName is set by Clowder to path to JSON file
+	// with application configuration
+	clowderConfigEnvVariableName = "ACG_CONFIG"
+	// serverAddressEnvVariableName overrides address provided by Clowder
+	serverAddressEnvVariableName = "INSIGHTS_CONTENT_SERVICE__SERVER__ADDRESS"
+	defaultContentPath           = "/rules-content"
+	defaultMetricsPath           = "/metrics"
 )
 
-// MetricsConf contains the metrics configuration
+// MetricsConf contains the metrics configuration. When Address is set,
+// metrics are also served by a separate HTTP server on that address and Path.
 type MetricsConf struct {
 	Namespace string `mapstructure:"namespace" toml:"namespace"`
+	Address   string `mapstructure:"address" toml:"address"`
+	Path      string `mapstructure:"path" toml:"path"`
 }
 
 // ConfigStruct is a structure holding the whole service configuration
@@ -62,21 +77,80 @@ type ConfigStruct struct {
 	Server  server.Configuration `mapstructure:"server" toml:"server"`
 	Groups  groups.Configuration `mapstructure:"groups" toml:"groups"`
 	Content struct {
-		ContentPath string `mapstructure:"path" toml:"path"`
+		ContentPath string                     `mapstructure:"path" toml:"path"`
+		Bundle      string                     `mapstructure:"bundle" toml:"bundle"`
+		S3          source.S3Configuration     `mapstructure:"s3" toml:"s3"`
+		Git         source.GitConfiguration    `mapstructure:"git" toml:"git"`
+		Manifest    manifest.Configuration     `mapstructure:"manifest" toml:"manifest"`
+		Cache       content.CacheConfiguration `mapstructure:"cache" toml:"cache"`
 	} `mapstructure:"content" toml:"content"`
 	Metrics           MetricsConf                       `mapstructure:"metrics" toml:"metrics"`
 	Logging           logger.LoggingConfiguration       `mapstructure:"logging" toml:"logging"`
 	CloudWatch        logger.CloudWatchConfiguration    `mapstructure:"cloudwatch" toml:"cloudwatch"`
 	SentryLoggingConf logger.SentryLoggingConfiguration `mapstructure:"sentry" toml:"sentry"`
 	KafkaZerologConf  logger.KafkaZerologConfiguration  `mapstructure:"kafka_zerolog" toml:"kafka_zerolog"`
+	Webhooks          webhooks.Configuration            `mapstructure:"webhooks" toml:"webhooks"`
+	KafkaProducer     producer.Configuration            `mapstructure:"kafka_producer" toml:"kafka_producer"`
 }
 
 // Config has exactly the same structure as *.toml file
 var Config ConfigStruct
 
+// configMutex guards Config, which is replaced when configuration is
+// reloaded while the service is running
+var configMutex sync.RWMutex
+
+// contentPathOverride replaces path to rule content in every loaded
+// configuration, it is guarded by configMutex
+var contentPathOverride string
+
+// SetConfigFile selects configuration file that is used instead of the
+// default one, the same way as configFileEnvVariableName environment
+// variable does
+func SetConfigFile(configFile string) error {
+	return os.Setenv(configFileEnvVariableName, configFile)
+}
+
 // LoadConfiguration loads configuration from defaultConfigFile, file set in
 // configFileEnvVariableName or from env
 func LoadConfiguration(defaultConfigFile string) error {
+	config, err := loadConfiguration(defaultConfigFile)
+	if err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	applyOverrides(&config)
+	Config = config
+	return nil
+}
+
+// SetContentPath overrides path to rule content, for example by command line
+// flag. The override is kept when configuration is loaded or reloaded again,
+// empty path removes it.
+func SetContentPath(path string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	contentPathOverride = path
+	applyOverrides(&Config)
+}
+
+// applyOverrides replaces options that have been overridden, configMutex
+// needs to be held by caller
+func applyOverrides(config *ConfigStruct) {
+	if contentPathOverride != "" {
+		config.Content.ContentPath = contentPathOverride
+	}
+}
+
+// loadConfiguration reads configuration into a new structure, so options
+// missing in the configuration are not taken from the current one
+func loadConfiguration(defaultConfigFile string) (ConfigStruct, error) {
+	var config ConfigStruct
+
 	configFile, specified := os.LookupEnv(configFileEnvVariableName)
 	if specified {
 		// we need to separate the directory name and filename without
@@ -98,9 +172,9 @@ func LoadConfiguration(defaultConfigFile string) error {
 		// config by itself
 		fakeTomlConfigWriter := new(bytes.Buffer)
 
-		err := toml.NewEncoder(fakeTomlConfigWriter).Encode(Config)
+		err := toml.NewEncoder(fakeTomlConfigWriter).Encode(config)
 		if err != nil {
-			return err
+			return config, err
 		}
 
 		fakeTomlConfig := fakeTomlConfigWriter.String()
@@ -109,10 +183,10 @@ func LoadConfiguration(defaultConfigFile string) error {
 
 		err = viper.ReadConfig(strings.NewReader(fakeTomlConfig))
 		if err != nil {
-			return err
+			return config, err
 		}
 	} else if err != nil {
-		return fmt.Errorf("fatal error config file: %s", err)
+		return config, fmt.Errorf("fatal error config file: %s", err)
 	}
 
 	// override config from env if there's variable in env
@@ -123,78 +197,221 @@ func LoadConfiguration(defaultConfigFile string) error {
 	viper.SetEnvPrefix(envPrefix)
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "__"))
 
-	err = viper.Unmarshal(&Config)
+	err = viper.Unmarshal(&config)
 	if err != nil {
-		return fmt.Errorf("fatal - can not unmarshal configuration: %s", err)
+		return config, fmt.Errorf("fatal - can not unmarshal configuration: %s", err)
 	}
 
 	if clowder.IsClowderEnabled() {
 		// can not use Zerolog at this moment!
 		fmt.Println("Clowder is enabled")
 
-		// TODO: insert logic to replace SELECTED configuration variables
+		err = updateConfigFromClowder(&config)
+		if err != nil {
+			return config, fmt.Errorf("fatal - can not apply Clowder configuration: %s", err)
+		}
 	} else {
 		// can not use Zerolog at this moment!
 		fmt.Println("Clowder is disabled")
 	}
 
 	// everything's should be ok
+	return config, nil
+}
+
+// updateConfigFromClowder function replaces selected configuration
+// variables by values provided by Clowder
+func updateConfigFromClowder(config *ConfigStruct) error {
+	clowderConfig, err := clowder.LoadConfig(os.Getenv(clowderConfigEnvVariableName))
+	if err != nil {
+		return err
+	}
+
+	// public port is used by REST API server, private port is used when
+	// just private web service is enabled; explicitly configured address
+	// is always kept
+	if os.Getenv(serverAddressEnvVariableName) == "" {
+		switch {
+		case clowderConfig.PublicPort != nil:
+			config.Server.Address = fmt.Sprintf(":%d", *clowderConfig.PublicPort)
+		case clowderConfig.PrivatePort != nil:
+			config.Server.Address = fmt.Sprintf(":%d", *clowderConfig.PrivatePort)
+		}
+	}
+
+	// metrics might be provided on different port and path
+	if clowderConfig.MetricsPort != 0 {
+		config.Metrics.Address = fmt.Sprintf(":%d", clowderConfig.MetricsPort)
+	}
+	if clowderConfig.MetricsPath != "" {
+		config.Metrics.Path = clowderConfig.MetricsPath
+	}
+
+	// CloudWatch credentials
+	if cloudWatch := clowderConfig.Logging.Cloudwatch; cloudWatch != nil {
+		config.CloudWatch.AWSAccessID = cloudWatch.AccessKeyId
+		config.CloudWatch.AWSSecretKey = cloudWatch.SecretAccessKey
+		config.CloudWatch.AWSRegion = cloudWatch.Region
+		config.CloudWatch.LogGroup = cloudWatch.LogGroup
+	} else {
+		fmt.Println("No CloudWatch configuration available in Clowder, using default one")
+	}
+
+	// Kafka broker used to send log messages
+	if clowderConfig.Kafka == nil || len(clowderConfig.Kafka.Brokers) == 0 {
+		fmt.Println("No Kafka configuration available in Clowder, using default one")
+		return nil
+	}
+
+	broker := clowderConfig.Kafka.Brokers[0]
+	// port can be empty in Clowder, so taking it into account
+	brokerAddress := broker.Hostname
+	if broker.Port != nil {
+		brokerAddress = fmt.Sprintf("%s:%d", broker.Hostname, *broker.Port)
+	}
+
+	// the same broker is used by producer of content change messages
+	config.KafkaZerologConf.Broker = brokerAddress
+	config.KafkaProducer.Address = brokerAddress
+
+	// topic names might be changed by Clowder
+	config.KafkaZerologConf.Topic = clowderTopic(clowderConfig, config.KafkaZerologConf.Topic)
+	config.KafkaProducer.Topic = clowderTopic(clowderConfig, config.KafkaProducer.Topic)
+
 	return nil
 }
 
+// clowderTopic function returns actual name of topic with given requested
+// name, the requested name is returned when Clowder does not provide such
+// topic
+func clowderTopic(clowderConfig *clowder.AppConfig, requestedName string) string {
+	for _, topic := range clowderConfig.Kafka.Topics {
+		if topic.RequestedName == requestedName {
+			return topic.Name
+		}
+	}
+	return requestedName
+}
+
+// currentConfig returns copy of the current configuration
+func currentConfig() ConfigStruct {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return Config
+}
+
 // GetServerConfiguration returns server configuration
 func GetServerConfiguration() server.Configuration {
-	err := checkIfFileExists(Config.Server.APISpecFile)
+	serverCfg := currentConfig().Server
+	err := checkIfFileExists(serverCfg.APISpecFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("All customer facing APIs MUST serve the current OpenAPI specification")
 	}
 
-	return Config.Server
+	return serverCfg
 }
 
 // GetGroupsConfiguration returns groups configuration
 func GetGroupsConfiguration() groups.Configuration {
-	err := checkIfFileExists(Config.Groups.ConfigPath)
+	groupsCfg := currentConfig().Groups
+	err := checkIfFileExists(groupsCfg.ConfigPath)
 	if err != nil {
 		log.Error().Err(err).Msg("The groups configuration file is not defined")
 	}
 
-	return Config.Groups
+	return groupsCfg
 }
 
 // GetContentPathConfiguration get the path to the content files from the
 // configuration
 func GetContentPathConfiguration() string {
-	if Config.Content.ContentPath == "" {
-		Config.Content.ContentPath = defaultContentPath
+	contentPath := currentConfig().Content.ContentPath
+	if contentPath == "" {
+		contentPath = defaultContentPath
+	}
+
+	return contentPath
+}
+
+// GetContentBundleConfiguration returns path to pre-built content bundle,
+// content is parsed from content directory when it is not set
+func GetContentBundleConfiguration() string {
+	return currentConfig().Content.Bundle
+}
+
+// GetContentS3Configuration returns configuration of content bundle stored
+// in S3-compatible object store
+func GetContentS3Configuration() source.S3Configuration {
+	return currentConfig().Content.S3
+}
+
+// GetContentGitConfiguration returns configuration of content read from
+// local git repository
+func GetContentGitConfiguration() source.GitConfiguration {
+	gitCfg := currentConfig().Content.Git
+	if gitCfg.Path == "" {
+		gitCfg.Path = source.DefaultGitContentPath
 	}
 
-	return Config.Content.ContentPath
+	return gitCfg
+}
+
+// GetContentManifestConfiguration returns configuration of content manifest
+// verification
+func GetContentManifestConfiguration() manifest.Configuration {
+	return currentConfig().Content.Manifest
+}
+
+// GetContentCacheConfiguration returns configuration of cache of parsed
+// content
+func GetContentCacheConfiguration() content.CacheConfiguration {
+	cacheCfg := currentConfig().Content.Cache
+	if cacheCfg.Fingerprint == "" {
+		cacheCfg.Fingerprint = content.FingerprintStat
+	}
+
+	return cacheCfg
 }
 
 // GetMetricsConfiguration get MetricsConf from the loaded configuration
 func GetMetricsConfiguration() MetricsConf {
-	return Config.Metrics
+	metricsCfg := currentConfig().Metrics
+	if metricsCfg.Address != "" && metricsCfg.Path == "" {
+		metricsCfg.Path = defaultMetricsPath
+	}
+
+	return metricsCfg
 }
 
 // GetLoggingConfiguration returns logging configuration
 func GetLoggingConfiguration() logger.LoggingConfiguration {
-	return Config.Logging
+	return currentConfig().Logging
 }
 
 // GetCloudWatchConfiguration returns cloudwatch configuration
 func GetCloudWatchConfiguration() logger.CloudWatchConfiguration {
-	return Config.CloudWatch
+	return currentConfig().CloudWatch
 }
 
 // GetSentryLoggingConfiguration returns the sentry log configuration
 func GetSentryLoggingConfiguration() logger.SentryLoggingConfiguration {
-	return Config.SentryLoggingConf
+	return currentConfig().SentryLoggingConf
 }
 
 // GetKafkaZerologConfiguration returns the kafkazero log configuration
 func GetKafkaZerologConfiguration() logger.KafkaZerologConfiguration {
-	return Config.KafkaZerologConf
+	return currentConfig().KafkaZerologConf
+}
+
+// GetWebhooksConfiguration returns configuration of webhook notifications
+func GetWebhooksConfiguration() webhooks.Configuration {
+	return currentConfig().Webhooks
+}
+
+// GetKafkaProducerConfiguration returns configuration of Kafka producer
+func GetKafkaProducerConfiguration() producer.Configuration {
+	return currentConfig().KafkaProducer
 }
 
 // checkIfFileExists returns nil if path doesn't exist or isn't a file,
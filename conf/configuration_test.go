@@ -19,10 +19,13 @@ package conf_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-content-service/conf"
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/source"
 )
 
 func init() {
@@ -82,6 +85,32 @@ func TestLoadServerConfiguration(t *testing.T) {
 
 	assert.Equal(t, ":8080", serverCfg.Address)
 	assert.Equal(t, "/api/v1/", serverCfg.APIPrefix)
+	assert.Equal(t, 5*time.Second, serverCfg.ShutdownDrainPeriod)
+	assert.Equal(t, 10*time.Second, serverCfg.ShutdownTimeout)
+	assert.Equal(t, 2*time.Minute, serverCfg.WriteTimeout)
+	assert.Equal(t, 16384, serverCfg.MaxHeaderBytes)
+	assert.False(t, serverCfg.DisableKeepAlives)
+}
+
+// TestLoadConfigurationInvalidServerLimits checks that invalid HTTP server
+// timeouts and limits don't prevent configuration loading, so they can be
+// reported by configuration validation
+func TestLoadConfigurationInvalidServerLimits(t *testing.T) {
+	os.Clearenv()
+	err := conf.LoadConfiguration("tests/config_invalid_limits")
+	config := conf.Config
+
+	// invalid values must not be used by other tests
+	conf.Config = conf.ConfigStruct{}
+
+	assert.NoError(t, err)
+	assert.EqualError(t, conf.CheckServerLimits(&config), "invalid server configuration: "+
+		"[server] read_timeout: timeout must not be negative; "+
+		"[server] max_header_bytes: limit must not be negative")
+	assert.Subset(t, problemOptions(conf.Validate(&config)), []string{
+		"server.read_timeout",
+		"server.max_header_bytes",
+	})
 }
 
 // TestLoadContentPathConfiguration tests loading the content path configuration
@@ -139,6 +168,19 @@ func TestGetLoggingConfiguration(t *testing.T) {
 	assert.True(t, loggingCfg.Debug)
 }
 
+// TestGettersKeepConfiguration checks that default values returned by
+// getters are not written into the loaded configuration
+func TestGettersKeepConfiguration(t *testing.T) {
+	os.Clearenv()
+	conf.Config = conf.ConfigStruct{}
+	original := conf.Config
+
+	assert.Equal(t, "/rules-content", conf.GetContentPathConfiguration())
+	assert.Equal(t, source.DefaultGitContentPath, conf.GetContentGitConfiguration().Path)
+	assert.Equal(t, content.FingerprintStat, conf.GetContentCacheConfiguration().Fingerprint)
+	assert.Equal(t, original, conf.Config)
+}
+
 // TestCheckIfFileExists tests the functionality of function checkIfFileExists
 func TestCheckIfFileExists(t *testing.T) {
 	err := conf.CheckIfFileExists("")
@@ -166,3 +208,81 @@ func TestCheckIfFileExists(t *testing.T) {
 		t.Fatal("File '..' is a directory")
 	}
 }
+
+// TestLoadConfigurationClowder checks that configuration provided by Clowder
+// replaces selected configuration variables
+func TestLoadConfigurationClowder(t *testing.T) {
+	os.Clearenv()
+	mustSetEnv(t, "ACG_CONFIG", "tests/clowder_config.json")
+	mustLoadConfiguration(t, "tests/config")
+
+	assert.Equal(t, ":8000", conf.GetServerConfiguration().Address)
+
+	metricsCfg := conf.GetMetricsConfiguration()
+	assert.Equal(t, ":9000", metricsCfg.Address)
+	assert.Equal(t, "/metrics", metricsCfg.Path)
+	assert.Equal(t, "contentservice", metricsCfg.Namespace)
+
+	cloudWatchCfg := conf.GetCloudWatchConfiguration()
+	assert.Equal(t, "access-key-id", cloudWatchCfg.AWSAccessID)
+	assert.Equal(t, "secret-access-key", cloudWatchCfg.AWSSecretKey)
+	assert.Equal(t, "us-east-1", cloudWatchCfg.AWSRegion)
+	assert.Equal(t, "content-service", cloudWatchCfg.LogGroup)
+
+	kafkaCfg := conf.GetKafkaZerologConfiguration()
+	assert.Equal(t, "kafka.local:29092", kafkaCfg.Broker)
+	assert.Equal(t, "platform.logs-clowder", kafkaCfg.Topic)
+
+	assert.Equal(t, "kafka.local:29092", conf.GetKafkaProducerConfiguration().Address)
+}
+
+// TestLoadConfigurationClowderPrivatePort checks that private port provided
+// by Clowder is used when public web service is not enabled
+func TestLoadConfigurationClowderPrivatePort(t *testing.T) {
+	os.Clearenv()
+	mustSetEnv(t, "ACG_CONFIG", "tests/clowder_config_private.json")
+	mustLoadConfiguration(t, "tests/config")
+
+	assert.Equal(t, ":10000", conf.GetServerConfiguration().Address)
+	assert.Equal(t, ":9000", conf.GetMetricsConfiguration().Address)
+}
+
+// TestLoadConfigurationClowderExplicitAddress checks that address configured
+// explicitly is not replaced by port provided by Clowder
+func TestLoadConfigurationClowderExplicitAddress(t *testing.T) {
+	os.Clearenv()
+	mustSetEnv(t, "ACG_CONFIG", "tests/clowder_config.json")
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE__SERVER__ADDRESS", ":10000")
+	mustLoadConfiguration(t, "tests/config")
+
+	assert.Equal(t, ":10000", conf.GetServerConfiguration().Address)
+	assert.Equal(t, ":9000", conf.GetMetricsConfiguration().Address)
+}
+
+// TestLoadConfigurationClowderMinimal checks that configuration is kept when
+// Clowder does not provide selected configuration variables
+func TestLoadConfigurationClowderMinimal(t *testing.T) {
+	os.Clearenv()
+	conf.Config = conf.ConfigStruct{}
+	mustSetEnv(t, "ACG_CONFIG", "tests/clowder_config_minimal.json")
+	mustLoadConfiguration(t, "tests/config")
+
+	assert.Equal(t, ":8080", conf.GetServerConfiguration().Address)
+
+	metricsCfg := conf.GetMetricsConfiguration()
+	assert.Equal(t, ":9000", metricsCfg.Address)
+	assert.Equal(t, "/metrics", metricsCfg.Path)
+
+	assert.Equal(t, "", conf.GetCloudWatchConfiguration().AWSAccessID)
+	assert.Equal(t, "", conf.GetKafkaZerologConfiguration().Broker)
+}
+
+// TestLoadConfigurationClowderNonExistingFile checks that improper Clowder
+// configuration is reported
+func TestLoadConfigurationClowderNonExistingFile(t *testing.T) {
+	os.Clearenv()
+	mustSetEnv(t, "ACG_CONFIG", "tests/this_does_not_exist.json")
+
+	err := conf.LoadConfiguration("tests/config")
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Options that can be changed without restarting the service
+const (
+	logLevelOption      = "logging.log_level"
+	groupsPathOption    = "groups.path"
+	corsOriginsOption   = "server.cors_allowed_origins"
+	disabledRulesOption = "server.disabled_rules"
+	optionTagName       = "mapstructure"
+	optionTagSplitter   = ","
+)
+
+// Diff returns list of all options that differ in provided configurations.
+// Options are returned in form section.option, for example server.address.
+func Diff(oldConfig, newConfig ConfigStruct) []string {
+	changed := []string{}
+
+	oldValue := reflect.ValueOf(oldConfig)
+	newValue := reflect.ValueOf(newConfig)
+
+	for i := 0; i < oldValue.NumField(); i++ {
+		section := optionName(oldValue.Type().Field(i))
+		oldSection := oldValue.Field(i)
+		newSection := newValue.Field(i)
+
+		for j := 0; j < oldSection.NumField(); j++ {
+			if !reflect.DeepEqual(oldSection.Field(j).Interface(), newSection.Field(j).Interface()) {
+				option := optionName(oldSection.Type().Field(j))
+				changed = append(changed, section+"."+option)
+			}
+		}
+	}
+
+	return changed
+}
+
+// optionName returns name of option as used in configuration file
+func optionName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get(optionTagName), optionTagSplitter)[0]
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// Reload loads the configuration again and applies just the options that can
+// be changed without restart. Names of applied options and names of rejected
+// options (that need restart) are returned. The current configuration is not
+// changed when the configuration can't be loaded. Options removed from the
+// configuration are reported as changed to their default values.
+func Reload(defaultConfigFile string) (applied, rejected []string, err error) {
+	newConfig, err := loadConfiguration(defaultConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	// overridden options are not reported as changed
+	applyOverrides(&newConfig)
+
+	// the configuration is changed selectively and replaced as a whole, so
+	// readers never see partially applied changes
+	config := Config
+
+	applied = []string{}
+	rejected = []string{}
+
+	for _, option := range Diff(Config, newConfig) {
+		if applyOption(&config, &newConfig, option) {
+			applied = append(applied, option)
+		} else {
+			rejected = append(rejected, option)
+		}
+	}
+
+	Config = config
+
+	return applied, rejected, nil
+}
+
+// applyOption copies value of selected option from new configuration if the
+// option can be changed without restart
+func applyOption(config, newConfig *ConfigStruct, option string) bool {
+	switch option {
+	case logLevelOption:
+		config.Logging.LogLevel = newConfig.Logging.LogLevel
+	case groupsPathOption:
+		config.Groups.ConfigPath = newConfig.Groups.ConfigPath
+	case corsOriginsOption:
+		config.Server.CORSAllowedOrigins = newConfig.Server.CORSAllowedOrigins
+	case disabledRulesOption:
+		config.Server.DisabledRules = newConfig.Server.DisabledRules
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/conf"
+)
+
+// TestDiffNoChanges checks that no changes are reported for the same configurations
+func TestDiffNoChanges(t *testing.T) {
+	config := validConfig()
+	assert.Empty(t, conf.Diff(config, config))
+}
+
+// TestDiff checks that all changed options are reported
+func TestDiff(t *testing.T) {
+	oldConfig := validConfig()
+	newConfig := validConfig()
+	newConfig.Server.Address = ":9999"
+	newConfig.Content.ContentPath = "rules-content"
+	newConfig.Logging.LogLevel = "error"
+
+	assert.Equal(t, []string{"server.address", "content.path", "logging.log_level"},
+		conf.Diff(oldConfig, newConfig))
+}
+
+// TestReload checks that just selected options are applied during reload and
+// that options removed from configuration are reported as changed
+func TestReload(t *testing.T) {
+	os.Clearenv()
+	conf.Config = conf.ConfigStruct{}
+	// file name needs to be unique, because viper remembers all search paths
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE", "tests/tests.toml")
+	mustLoadConfiguration(t, "foobar")
+
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE", "tests/config_reload.toml")
+	applied, rejected, err := conf.Reload("foobar")
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"server.cors_allowed_origins",
+		"server.disabled_rules",
+		"groups.path",
+		"logging.log_level",
+	}, applied)
+	// api_spec_file is not set in the new configuration
+	assert.Equal(t, []string{"server.address", "server.api_spec_file"}, rejected)
+
+	serverCfg := conf.GetServerConfiguration()
+	assert.Equal(t, ":8080", serverCfg.Address)
+	assert.Equal(t, "openapi.json", serverCfg.APISpecFile)
+	assert.Equal(t, []string{"https://console.redhat.com"}, serverCfg.CORSAllowedOrigins)
+	assert.Equal(t, []string{"rule1"}, serverCfg.DisabledRules)
+	assert.Equal(t, "tests/groups/hierarchy.yaml", conf.GetGroupsConfiguration().ConfigPath)
+	assert.Equal(t, "error", conf.GetLoggingConfiguration().LogLevel)
+}
+
+// TestReloadContentPathOverride checks that overridden path to rule content
+// is kept and not reported as rejected during reload
+func TestReloadContentPathOverride(t *testing.T) {
+	os.Clearenv()
+	conf.Config = conf.ConfigStruct{}
+	// file name needs to be unique, because viper remembers all search paths
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE", "tests/tests.toml")
+	mustLoadConfiguration(t, "foobar")
+
+	conf.SetContentPath("/override")
+	t.Cleanup(func() { conf.SetContentPath("") })
+	assert.Equal(t, "/override", conf.GetContentPathConfiguration())
+
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE", "tests/config_reload.toml")
+	_, rejected, err := conf.Reload("foobar")
+	assert.NoError(t, err)
+
+	assert.NotContains(t, rejected, "content.path")
+	assert.Equal(t, "/override", conf.GetContentPathConfiguration())
+}
+
+// TestReloadImproperConfig checks that current configuration is kept when
+// configuration can't be loaded
+func TestReloadImproperConfig(t *testing.T) {
+	loadProperConfigFile(t)
+	original := conf.Config
+
+	mustSetEnv(t, "INSIGHTS_CONTENT_SERVICE_CONFIG_FILE", "tests/config_improper_format.toml")
+	_, _, err := conf.Reload("foobar")
+	assert.Error(t, err)
+	assert.Equal(t, original, conf.Config)
+}
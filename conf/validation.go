@@ -0,0 +1,461 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-content-service/bundle"
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+)
+
+// Problem represents one problem found in the configuration
+type Problem struct {
+	Section string `json:"section"`
+	Option  string `json:"option"`
+	Message string `json:"message"`
+}
+
+// String returns human readable representation of the problem
+func (problem Problem) String() string {
+	return fmt.Sprintf("[%s] %s: %s", problem.Section, problem.Option, problem.Message)
+}
+
+// logLevels contains all logging levels understood by logger
+var logLevels = []string{"debug", "info", "warn", "warning", "error", "fatal"}
+
+// problems is a list of problems found during validation
+type problems []Problem
+
+func (list *problems) add(section, option, format string, args ...interface{}) {
+	*list = append(*list, Problem{
+		Section: section,
+		Option:  option,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addIfError adds a problem only if error is not nil
+func (list *problems) addIfError(section, option string, err error) {
+	if err != nil {
+		list.add(section, option, "%v", err)
+	}
+}
+
+// Validate checks all sections of provided configuration and returns list of
+// all problems found. Empty list is returned for valid configuration.
+func Validate(config *ConfigStruct) []Problem {
+	list := problems{}
+
+	validateServer(config, &list)
+	validateGroups(config, &list)
+	validateContent(config, &list)
+	validateMetrics(config, &list)
+	validateLogging(config, &list)
+	validateWebhooks(config, &list)
+	validateKafkaProducer(config, &list)
+
+	return list
+}
+
+func validateServer(config *ConfigStruct, list *problems) {
+	const section = "server"
+
+	list.addIfError(section, "address", checkAddress(config.Server.Address))
+
+	apiPrefix := config.Server.APIPrefix
+	if !strings.HasPrefix(apiPrefix, "/") || !strings.HasSuffix(apiPrefix, "/") {
+		list.add(section, "api_prefix", "API prefix '%s' must start and end with '/'", apiPrefix)
+	}
+
+	list.addIfError(section, "api_spec_file", checkIfFileExists(config.Server.APISpecFile))
+
+	validateServerLimits(config, list)
+
+	if config.Server.ShutdownDrainPeriod < 0 {
+		list.add(section, "shutdown_drain_period", "drain period must not be negative")
+	}
+	if config.Server.ShutdownTimeout < 0 {
+		list.add(section, "shutdown_timeout", "timeout must not be negative")
+	}
+	if ratio := config.Server.MaxInvalidRulesRatio; ratio < 0 || ratio > 1 {
+		list.add(section, "max_invalid_rules_ratio", "ratio %v must be between 0 and 1", ratio)
+	}
+	if config.Server.MaxSnapshots < 0 {
+		list.add(section, "max_snapshots", "number of snapshots must not be negative")
+	}
+	if config.Server.EventsHeartbeat < 0 {
+		list.add(section, "events_heartbeat", "heartbeat interval must not be negative")
+	}
+	if config.Server.MaxEventSubscribers < 0 {
+		list.add(section, "max_event_subscribers", "number of subscribers must not be negative")
+	}
+	if ratio := config.Server.MaxDroppedRulesRatio; ratio < 0 || ratio > 1 {
+		list.add(section, "max_dropped_rules_ratio", "ratio %v must be between 0 and 1", ratio)
+	}
+}
+
+// validateServerLimits checks HTTP server timeouts and limits. These checks
+// are performed before the service is started too.
+func validateServerLimits(config *ConfigStruct, list *problems) {
+	const section = "server"
+
+	timeouts := []struct {
+		option  string
+		timeout time.Duration
+	}{
+		{"read_timeout", config.Server.ReadTimeout},
+		{"read_header_timeout", config.Server.ReadHeaderTimeout},
+		{"write_timeout", config.Server.WriteTimeout},
+		{"idle_timeout", config.Server.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.timeout < 0 {
+			list.add(section, t.option, "timeout must not be negative")
+		}
+	}
+
+	if config.Server.ReadTimeout > 0 && config.Server.ReadHeaderTimeout > config.Server.ReadTimeout {
+		list.add(section, "read_header_timeout", "timeout %v must not be longer than read timeout %v",
+			config.Server.ReadHeaderTimeout, config.Server.ReadTimeout)
+	}
+
+	if config.Server.MaxHeaderBytes < 0 {
+		list.add(section, "max_header_bytes", "limit must not be negative")
+	}
+}
+
+// CheckServerLimits returns error describing all problems found in HTTP
+// server timeouts and limits
+func CheckServerLimits(config *ConfigStruct) error {
+	list := problems{}
+	validateServerLimits(config, &list)
+	if len(list) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(list))
+	for i, problem := range list {
+		messages[i] = problem.String()
+	}
+	return fmt.Errorf("invalid server configuration: %s", strings.Join(messages, "; "))
+}
+
+func validateGroups(config *ConfigStruct, list *problems) {
+	// groups are read from content bundle when it is configured
+	if config.Content.Bundle != "" {
+		return
+	}
+	list.addIfError("groups", "path", checkIfFileExists(config.Groups.ConfigPath))
+}
+
+func validateContent(config *ConfigStruct, list *problems) {
+	validateContentManifest(config, list)
+	validateContentCache(config, list)
+
+	// content is read from pre-built bundle when it is configured
+	if config.Content.Bundle != "" {
+		validateContentBundle(config, list)
+		return
+	}
+
+	// content is downloaded from object store when S3 source is enabled
+	if config.Content.S3.Enabled {
+		validateContentS3(config, list)
+		if config.Content.Git.Enabled {
+			list.add("content.git", "enabled", "git and S3 content sources can't be enabled together")
+		}
+		return
+	}
+
+	// content is read from git repository when git source is enabled
+	if config.Content.Git.Enabled {
+		validateContentGit(config, list)
+		return
+	}
+
+	contentPath := config.Content.ContentPath
+	if contentPath == "" {
+		contentPath = defaultContentPath
+	}
+	list.addIfError("content", "path", checkIfDirectoryExists(contentPath))
+}
+
+func validateContentBundle(config *ConfigStruct, list *problems) {
+	if err := checkIfFileExists(config.Content.Bundle); err != nil {
+		list.addIfError("content", "bundle", err)
+	} else {
+		_, err := bundle.FormatFromPath(config.Content.Bundle)
+		list.addIfError("content", "bundle", err)
+	}
+	if config.Content.S3.Enabled || config.Content.Git.Enabled {
+		list.add("content", "bundle", "bundle can't be used together with S3 or git content source")
+	}
+	if config.Content.Manifest.Enabled() {
+		list.add("content", "bundle", "bundled content can't be verified against signed manifest, "+
+			"remove public keys or content bundle")
+	}
+}
+
+func validateContentManifest(config *ConfigStruct, list *problems) {
+	for _, key := range config.Content.Manifest.PublicKeys {
+		_, err := manifest.ParsePublicKey(key)
+		list.addIfError("content.manifest", "public_keys", err)
+	}
+}
+
+func validateContentCache(config *ConfigStruct, list *problems) {
+	switch fingerprint := config.Content.Cache.Fingerprint; fingerprint {
+	case "", content.FingerprintStat, content.FingerprintContent:
+	default:
+		list.add("content.cache", "fingerprint", "unknown fingerprint mode '%s', expected '%s' or '%s'",
+			fingerprint, content.FingerprintStat, content.FingerprintContent)
+	}
+}
+
+func validateContentS3(config *ConfigStruct, list *problems) {
+	const section = "content.s3"
+	s3Config := config.Content.S3
+
+	if s3Config.Endpoint != "" {
+		list.addIfError(section, "endpoint", checkHTTPURL(s3Config.Endpoint))
+	}
+	if s3Config.Bucket == "" {
+		list.add(section, "bucket", "bucket must be set when S3 content source is enabled")
+	}
+	if s3Config.Key == "" {
+		list.add(section, "key", "key must be set when S3 content source is enabled")
+	}
+	if s3Config.CacheDir == "" {
+		list.add(section, "cache_dir", "cache directory must be set when S3 content source is enabled")
+	}
+	if s3Config.AccessKeyID != "" && s3Config.SecretAccessKey == "" {
+		list.add(section, "secret_access_key", "secret access key must be set together with access key ID")
+	}
+	if s3Config.CheckInterval < 0 {
+		list.add(section, "check_interval", "interval must not be negative")
+	}
+	if s3Config.Timeout < 0 {
+		list.add(section, "timeout", "timeout must not be negative")
+	}
+}
+
+func validateContentGit(config *ConfigStruct, list *problems) {
+	const section = "content.git"
+	gitConfig := config.Content.Git
+
+	if gitConfig.Repository == "" {
+		list.add(section, "repository", "repository must be set when git content source is enabled")
+	} else {
+		list.addIfError(section, "repository", checkIfDirectoryExists(gitConfig.Repository))
+	}
+	if gitConfig.Ref == "" {
+		list.add(section, "ref", "ref must be set when git content source is enabled")
+	} else if strings.HasPrefix(gitConfig.Ref, "-") {
+		list.add(section, "ref", "ref must not start with '-'")
+	}
+	if gitConfig.CacheDir == "" {
+		list.add(section, "cache_dir", "cache directory must be set when git content source is enabled")
+	}
+	if gitConfig.CheckInterval < 0 {
+		list.add(section, "check_interval", "interval must not be negative")
+	}
+}
+
+func validateMetrics(config *ConfigStruct, list *problems) {
+	const section = "metrics"
+
+	if config.Metrics.Address != "" {
+		list.addIfError(section, "address", checkAddress(config.Metrics.Address))
+	}
+	if config.Metrics.Path != "" && !strings.HasPrefix(config.Metrics.Path, "/") {
+		list.add(section, "path", "path '%s' must start with '/'", config.Metrics.Path)
+	}
+}
+
+func validateLogging(config *ConfigStruct, list *problems) {
+	list.addIfError("logging", "log_level", checkLogLevel(config.Logging.LogLevel))
+
+	if config.Logging.LoggingToCloudWatchEnabled {
+		if config.CloudWatch.AWSRegion == "" {
+			list.add("cloudwatch", "aws_region", "region must be set when logging to CloudWatch is enabled")
+		}
+		if config.CloudWatch.LogGroup == "" {
+			list.add("cloudwatch", "log_group", "log group must be set when logging to CloudWatch is enabled")
+		}
+	}
+
+	dsn := config.SentryLoggingConf.SentryDSN
+	if dsn != "" {
+		list.addIfError("sentry", "dsn", checkSentryDSN(dsn))
+	} else if config.Logging.LoggingToSentryEnabled {
+		list.add("sentry", "dsn", "DSN must be set when logging to Sentry is enabled")
+	}
+
+	if config.KafkaZerologConf.Broker != "" {
+		list.addIfError("kafka_zerolog", "broker", checkAddress(config.KafkaZerologConf.Broker))
+	}
+	list.addIfError("kafka_zerolog", "level", checkLogLevel(config.KafkaZerologConf.Level))
+}
+
+func validateWebhooks(config *ConfigStruct, list *problems) {
+	const section = "webhooks"
+
+	for _, webhookURL := range config.Webhooks.URLs {
+		list.addIfError(section, "urls", checkHTTPURL(webhookURL))
+	}
+	if config.Webhooks.Enabled() && config.Webhooks.Secret == "" {
+		list.add(section, "secret", "secret must be set when webhook URLs are configured")
+	}
+	if config.Webhooks.MaxRetries < 0 {
+		list.add(section, "max_retries", "number of retries must not be negative")
+	}
+	if config.Webhooks.MaxDeliveryLogSize < 0 {
+		list.add(section, "max_delivery_log_size", "size must not be negative")
+	}
+
+	durations := []struct {
+		option   string
+		duration time.Duration
+	}{
+		{"initial_backoff", config.Webhooks.InitialBackoff},
+		{"max_backoff", config.Webhooks.MaxBackoff},
+		{"timeout", config.Webhooks.Timeout},
+	}
+	for _, d := range durations {
+		if d.duration < 0 {
+			list.add(section, d.option, "duration must not be negative")
+		}
+	}
+}
+
+func validateKafkaProducer(config *ConfigStruct, list *problems) {
+	const section = "kafka_producer"
+
+	if config.KafkaProducer.Enabled {
+		list.addIfError(section, "address", checkAddress(config.KafkaProducer.Address))
+		if config.KafkaProducer.Topic == "" {
+			list.add(section, "topic", "topic must be set when Kafka producer is enabled")
+		}
+	}
+	if config.KafkaProducer.Retries < 0 {
+		list.add(section, "retries", "number of retries must not be negative")
+	}
+
+	durations := []struct {
+		option   string
+		duration time.Duration
+	}{
+		{"timeout", config.KafkaProducer.Timeout},
+		{"retry_backoff", config.KafkaProducer.RetryBackoff},
+		{"max_retry_backoff", config.KafkaProducer.MaxRetryBackoff},
+	}
+	for _, d := range durations {
+		if d.duration < 0 {
+			list.add(section, d.option, "duration must not be negative")
+		}
+	}
+}
+
+// checkHTTPURL returns error if provided URL is not absolute HTTP(S) URL
+func checkHTTPURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("Unsupported scheme '%s' in URL '%s'", parsed.Scheme, rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("Missing host in URL '%s'", rawURL)
+	}
+	return nil
+}
+
+// checkAddress returns error if provided address is not in host:port form
+func checkAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("Empty address provided")
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if port == "" {
+		return fmt.Errorf("Missing port in address '%s'", address)
+	}
+	return nil
+}
+
+// checkLogLevel returns error if provided logging level is not known. Empty
+// level is allowed, the default one is used in such case.
+func checkLogLevel(level string) error {
+	if level == "" {
+		return nil
+	}
+	for _, known := range logLevels {
+		if strings.EqualFold(level, known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown logging level '%s', expected one of %s", level, strings.Join(logLevels, ", "))
+}
+
+// checkSentryDSN returns error if provided DSN does not have form
+// scheme://key@host/project
+func checkSentryDSN(dsn string) error {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("Unsupported scheme '%s' in DSN", parsed.Scheme)
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return fmt.Errorf("Missing public key in DSN")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("Missing host in DSN")
+	}
+	if strings.Trim(parsed.Path, "/") == "" {
+		return fmt.Errorf("Missing project ID in DSN")
+	}
+	return nil
+}
+
+// checkIfDirectoryExists returns nil if path exists and is a directory,
+// otherwise it returns corresponding error
+func checkIfDirectoryExists(path string) error {
+	fileInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("The following directory path does not exist. Path: '%v'", path)
+	} else if err != nil {
+		return err
+	}
+
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("The following path is not a directory. Path: '%v'", path)
+	}
+
+	return nil
+}
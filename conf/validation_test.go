@@ -0,0 +1,293 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/conf"
+)
+
+// validConfig returns configuration that passes all checks
+func validConfig() conf.ConfigStruct {
+	config := conf.ConfigStruct{}
+	config.Server.Address = ":8080"
+	config.Server.APIPrefix = "/api/v1/"
+	config.Server.APISpecFile = "openapi.json"
+	config.Groups.ConfigPath = "groups_config.yaml"
+	config.Content.ContentPath = "tests/content/ok"
+	return config
+}
+
+// problemOptions returns section.option of all problems
+func problemOptions(problems []conf.Problem) []string {
+	options := []string{}
+	for _, problem := range problems {
+		options = append(options, problem.Section+"."+problem.Option)
+	}
+	return options
+}
+
+// TestValidateValidConfig checks that no problems are found in valid configuration
+func TestValidateValidConfig(t *testing.T) {
+	config := validConfig()
+	config.Logging.LogLevel = "WARN"
+	config.SentryLoggingConf.SentryDSN = "https://public@sentry.example.com/42"
+	config.Metrics.Address = "localhost:9000"
+	config.Metrics.Path = "/metrics"
+	config.Webhooks.URLs = []string{"https://example.com/hook"}
+	config.Webhooks.Secret = "secret"
+	config.KafkaProducer.Enabled = true
+	config.KafkaProducer.Address = "kafka:29092"
+	config.KafkaProducer.Topic = "platform.content-service.changes"
+
+	assert.Empty(t, conf.Validate(&config))
+}
+
+// TestValidateReportsAllProblems checks that all problems are reported together
+func TestValidateReportsAllProblems(t *testing.T) {
+	config := conf.ConfigStruct{}
+	config.Server.Address = "localhost"
+	config.Server.APIPrefix = "api/v1"
+	config.Server.APISpecFile = "xyzzy"
+	config.Server.ReadTimeout = time.Second
+	config.Server.ReadHeaderTimeout = time.Minute
+	config.Server.WriteTimeout = -1
+	config.Server.IdleTimeout = -1
+	config.Server.MaxHeaderBytes = -1
+	config.Server.ShutdownDrainPeriod = -1
+	config.Server.ShutdownTimeout = -1
+	config.Server.MaxInvalidRulesRatio = 1.5
+	config.Server.MaxSnapshots = -1
+	config.Server.EventsHeartbeat = -1
+	config.Server.MaxEventSubscribers = -1
+	config.Server.MaxDroppedRulesRatio = -0.5
+	config.Groups.ConfigPath = "tests"
+	config.Content.ContentPath = "config.toml"
+	config.Metrics.Address = ":"
+	config.Metrics.Path = "metrics"
+	config.Logging.LogLevel = "verbose"
+	config.Logging.LoggingToCloudWatchEnabled = true
+	config.Logging.LoggingToSentryEnabled = true
+	config.KafkaZerologConf.Broker = "kafka"
+	config.KafkaZerologConf.Level = "trace"
+	config.Webhooks.URLs = []string{"ftp://example.com/hook"}
+	config.Webhooks.MaxRetries = -1
+	config.Webhooks.InitialBackoff = -1
+	config.Webhooks.MaxBackoff = -1
+	config.Webhooks.Timeout = -1
+	config.Webhooks.MaxDeliveryLogSize = -1
+	config.KafkaProducer.Enabled = true
+	config.KafkaProducer.Retries = -1
+	config.KafkaProducer.Timeout = -1
+	config.KafkaProducer.RetryBackoff = -1
+	config.KafkaProducer.MaxRetryBackoff = -1
+
+	problems := conf.Validate(&config)
+
+	assert.Equal(t, []string{
+		"server.address",
+		"server.api_prefix",
+		"server.api_spec_file",
+		"server.write_timeout",
+		"server.idle_timeout",
+		"server.read_header_timeout",
+		"server.max_header_bytes",
+		"server.shutdown_drain_period",
+		"server.shutdown_timeout",
+		"server.max_invalid_rules_ratio",
+		"server.max_snapshots",
+		"server.events_heartbeat",
+		"server.max_event_subscribers",
+		"server.max_dropped_rules_ratio",
+		"groups.path",
+		"content.path",
+		"metrics.address",
+		"metrics.path",
+		"logging.log_level",
+		"cloudwatch.aws_region",
+		"cloudwatch.log_group",
+		"sentry.dsn",
+		"kafka_zerolog.broker",
+		"kafka_zerolog.level",
+		"webhooks.urls",
+		"webhooks.secret",
+		"webhooks.max_retries",
+		"webhooks.max_delivery_log_size",
+		"webhooks.initial_backoff",
+		"webhooks.max_backoff",
+		"webhooks.timeout",
+		"kafka_producer.address",
+		"kafka_producer.topic",
+		"kafka_producer.retries",
+		"kafka_producer.timeout",
+		"kafka_producer.retry_backoff",
+		"kafka_producer.max_retry_backoff",
+	}, problemOptions(problems))
+}
+
+// TestValidateContentS3 checks the S3 content source checks
+func TestValidateContentS3(t *testing.T) {
+	config := validConfig()
+	config.Content.ContentPath = "xyzzy"
+	config.Content.S3.Enabled = true
+	config.Content.S3.Bucket = "content"
+	config.Content.S3.Key = "rules-content.tar.gz"
+	config.Content.S3.CacheDir = "/tmp/content-cache"
+	config.Content.S3.Endpoint = "http://localhost:9000"
+
+	// content path is not checked when content is downloaded
+	assert.Empty(t, conf.Validate(&config))
+
+	config.Content.S3.Endpoint = "localhost:9000"
+	config.Content.S3.Bucket = ""
+	config.Content.S3.Key = ""
+	config.Content.S3.CacheDir = ""
+	config.Content.S3.AccessKeyID = "access"
+	config.Content.S3.CheckInterval = -1
+	config.Content.S3.Timeout = -1
+
+	assert.Equal(t, []string{
+		"content.s3.endpoint",
+		"content.s3.bucket",
+		"content.s3.key",
+		"content.s3.cache_dir",
+		"content.s3.secret_access_key",
+		"content.s3.check_interval",
+		"content.s3.timeout",
+	}, problemOptions(conf.Validate(&config)))
+}
+
+// TestValidateContentGit checks the git content source checks
+func TestValidateContentGit(t *testing.T) {
+	config := validConfig()
+	config.Content.ContentPath = "xyzzy"
+	config.Content.Git.Enabled = true
+	config.Content.Git.Repository = "tests/content"
+	config.Content.Git.Ref = "v1.0.0"
+	config.Content.Git.CacheDir = "/tmp/content-cache"
+
+	// content path is not checked when content is read from repository
+	assert.Empty(t, conf.Validate(&config))
+
+	config.Content.Git.Repository = "xyzzy"
+	config.Content.Git.Ref = "--upload-pack=x"
+	config.Content.Git.CacheDir = ""
+	config.Content.Git.CheckInterval = -1
+
+	assert.Equal(t, []string{
+		"content.git.repository",
+		"content.git.ref",
+		"content.git.cache_dir",
+		"content.git.check_interval",
+	}, problemOptions(conf.Validate(&config)))
+
+	config.Content.S3.Enabled = true
+	config.Content.S3.Bucket = "content"
+	config.Content.S3.Key = "rules-content.tar.gz"
+	config.Content.S3.CacheDir = "/tmp/content-cache"
+
+	assert.Equal(t, []string{
+		"content.git.enabled",
+	}, problemOptions(conf.Validate(&config)))
+}
+
+// TestValidateContentManifest checks the manifest public keys checks
+func TestValidateContentManifest(t *testing.T) {
+	config := validConfig()
+	config.Content.Manifest.PublicKeys = []string{
+		"rules-team:11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+	}
+	assert.Empty(t, conf.Validate(&config))
+
+	config.Content.Manifest.PublicKeys = append(config.Content.Manifest.PublicKeys, "rules-team:xyzzy")
+	assert.Equal(t, []string{
+		"content.manifest.public_keys",
+	}, problemOptions(conf.Validate(&config)))
+}
+
+// TestValidateContentCache checks the fingerprint mode check
+func TestValidateContentCache(t *testing.T) {
+	config := validConfig()
+	config.Content.Cache.Dir = "/tmp/content-cache"
+	config.Content.Cache.Fingerprint = "content"
+	assert.Empty(t, conf.Validate(&config))
+
+	config.Content.Cache.Fingerprint = "mtime"
+	assert.Equal(t, []string{
+		"content.cache.fingerprint",
+	}, problemOptions(conf.Validate(&config)))
+}
+
+// TestValidateContentBundle checks that neither content directory nor groups
+// configuration are checked when content is read from bundle
+func TestValidateContentBundle(t *testing.T) {
+	bundlePath := filepath.Join(t.TempDir(), "content.json")
+	assert.NoError(t, os.WriteFile(bundlePath, []byte("{}"), 0o600))
+
+	config := validConfig()
+	config.Content.ContentPath = "xyzzy"
+	config.Groups.ConfigPath = "xyzzy.yaml"
+	config.Content.Bundle = bundlePath
+	assert.Empty(t, conf.Validate(&config))
+
+	config.Content.Bundle = "openapi.xml"
+	assert.Equal(t, []string{
+		"content.bundle",
+	}, problemOptions(conf.Validate(&config)))
+
+	config.Content.Bundle = bundlePath
+	config.Content.Git.Enabled = true
+	assert.Equal(t, []string{
+		"content.bundle",
+	}, problemOptions(conf.Validate(&config)))
+
+	// bundled content can't be verified
+	config.Content.Git.Enabled = false
+	config.Content.Manifest.PublicKeys = []string{"rules-team:11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}
+	assert.Equal(t, []string{
+		"content.bundle",
+	}, problemOptions(conf.Validate(&config)))
+}
+
+// TestValidateSentryDSN checks the Sentry DSN syntax checks
+func TestValidateSentryDSN(t *testing.T) {
+	invalidDSNs := []string{
+		"ftp://public@sentry.example.com/42",
+		"https://sentry.example.com/42",
+		"https://public@/42",
+		"https://public@sentry.example.com/",
+		"https://public@sentry.example.com:port/42",
+	}
+
+	for _, dsn := range invalidDSNs {
+		config := validConfig()
+		config.SentryLoggingConf.SentryDSN = dsn
+		assert.Equal(t, []string{"sentry.dsn"}, problemOptions(conf.Validate(&config)), dsn)
+	}
+}
+
+// TestProblemString checks human readable representation of a problem
+func TestProblemString(t *testing.T) {
+	problem := conf.Problem{Section: "server", Option: "address", Message: "Empty address provided"}
+	assert.Equal(t, "[server] address: Empty address provided", problem.String())
+}
@@ -19,19 +19,34 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/logger"
 	"github.com/RedHatInsights/insights-operator-utils/metrics"
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-content-service/bundle"
 	"github.com/RedHatInsights/insights-content-service/conf"
 	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/contentdiff"
 	"github.com/RedHatInsights/insights-content-service/groups"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+	"github.com/RedHatInsights/insights-content-service/producer"
 	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/source"
+	"github.com/RedHatInsights/insights-content-service/webhooks"
 )
 
 // ExitCode represents numeric value returned to parent process when the
@@ -51,11 +66,24 @@ const (
 	// ExitStatusOther represents other errors that might happen
 	ExitStatusOther
 
+	// ExitStatusConfigError is returned when the configuration is not valid
+	ExitStatusConfigError
+
+	// ExitStatusShutdown is returned when the service has been stopped
+	// gracefully by a signal
+	ExitStatusShutdown
+
 	defaultConfigFilename = "config"
+
+	// defaultShutdownTimeout is used when shutdown timeout is not configured
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 var (
-	serverInstance *server.HTTPServer
+	serverInstance        *server.HTTPServer
+	metricsServerInstance *server.MetricsServer
+	kafkaPublisher        *producer.Publisher
+	stopContentWatcher    context.CancelFunc
 
 	// BuildVersion contains the major.minor version of the CLI client
 	BuildVersion = "*not set*"
@@ -80,29 +108,144 @@ var (
 
 // startService starts service and returns error code
 func startService() ExitCode {
+	// invalid timeouts and limits are reported by validate-config command
+	// too, but the server must not be started with them
+	if err := conf.CheckServerLimits(&conf.Config); err != nil {
+		log.Error().Err(err).Msg("Server configuration error")
+		return ExitStatusConfigError
+	}
+
 	serverCfg := conf.GetServerConfiguration()
-	groupsCfg := conf.GetGroupsConfiguration()
-	parsedGroups, err := groups.ParseGroupConfigFile(groupsCfg.ConfigPath)
+
+	// content and groups might be read from pre-built bundle instead of
+	// parsing them
+	contentBundle, err := loadContentBundle(conf.GetContentBundleConfiguration())
 	if err != nil {
-		log.Error().Err(err).Msg("Groups init error")
+		log.Error().Err(err).Msg("Unable to read content bundle")
+		return ExitStatusReadContentError
+	}
+
+	var parsedGroups map[string]groups.Group
+	var groupsFindings groups.Findings
+	if contentBundle != nil {
+		parsedGroups = contentBundle.Groups
+		groupsFindings = groups.Validate(parsedGroups)
+	} else {
+		groupsCfg := conf.GetGroupsConfiguration()
+		parsedGroups, groupsFindings, err = groups.ValidateConfigFile(groupsCfg.ConfigPath)
+		if err != nil {
+			log.Error().Err(err).Msg("Groups init error")
+			return ExitStatusServerError
+		}
+	}
+
+	groups.LogFindings(groupsFindings)
+	if groupsFindings.HasFatal() {
+		log.Error().Msg("Groups configuration is not valid")
 		return ExitStatusServerError
 	}
 
 	metricsCfg := conf.GetMetricsConfiguration()
 	if metricsCfg.Namespace != "" {
 		metrics.AddAPIMetricsWithNamespace(metricsCfg.Namespace)
+		content.AddMetricsWithNamespace(metricsCfg.Namespace)
+	}
+
+	// metrics might be served on different address than REST API
+	if metricsCfg.Address != "" && metricsCfg.Address != serverCfg.Address {
+		metricsServerInstance = server.NewMetricsServer(metricsCfg.Address, metricsCfg.Path)
+		go func() {
+			err := metricsServerInstance.Start()
+			if err != nil {
+				log.Error().Err(err).Msg("Metrics server start error")
+			}
+		}()
 	}
 
 	ruleContentDirPath := conf.GetContentPathConfiguration()
 
-	contentDir, ruleContentStatusMap, err := content.ParseRuleContentDir(ruleContentDirPath)
-	if osPathError, ok := err.(*os.PathError); ok {
-		log.Error().Err(osPathError).Msg("No rules directory")
-		return ExitStatusReadContentError
-	} else if err != nil {
-		log.Error().Err(err).Msg("error happened during parsing rules content dir")
+	// content might be downloaded from object store or read from git
+	// repository
+	s3Cfg := conf.GetContentS3Configuration()
+	gitCfg := conf.GetContentGitConfiguration()
+	var contentSource source.Source
+	var checkInterval time.Duration
+	var rulesVersionLoader func() string
+	switch {
+	case contentBundle != nil:
+		// no content source is used for bundled content
+	case s3Cfg.Enabled:
+		contentSource, err = openS3ContentSource(s3Cfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to download rule content")
+			return ExitStatusReadContentError
+		}
+		checkInterval = s3Cfg.CheckInterval
+	case gitCfg.Enabled:
+		gitSource, err := openGitContentSource(gitCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read rule content from git repository")
+			return ExitStatusReadContentError
+		}
+		contentSource = gitSource
+		checkInterval = gitCfg.CheckInterval
+		// resolved commit is used as rules version instead of the one set
+		// at build time
+		rulesVersionLoader = gitSource.Version
+		OCPRulesVersion = gitSource.Version()
+	}
+	if contentSource != nil {
+		ruleContentDirPath = contentSource.Path()
+	}
+
+	// content is verified against signed manifest before it is parsed
+	manifestKeys, err := manifest.ParsePublicKeys(conf.GetContentManifestConfiguration().PublicKeys)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid manifest public key")
 		return ExitStatusReadContentError
 	}
+	var verifiedManifest *manifest.Verified
+	var contentDir content.RuleContentDirectory
+	var ruleContentStatusMap map[string]ctypes.RuleContentStatus
+	parseStart := time.Now()
+	contentCacheCfg := conf.GetContentCacheConfiguration()
+
+	if contentBundle != nil {
+		// bundle is not signed, so there's nothing to verify it against and
+		// manifest stored in the bundle by exporter can't be trusted
+		if len(manifestKeys) > 0 {
+			log.Error().Msg("Content bundle can't be verified against signed manifest")
+			return ExitStatusReadContentError
+		}
+		contentDir, ruleContentStatusMap = contentBundle.Content, contentBundle.Status
+		content.GlobalConfig = contentDir.Config
+		if contentBundle.Manifest.RulesVersion != "" {
+			OCPRulesVersion = contentBundle.Manifest.RulesVersion
+		}
+	} else {
+		verifiedManifest, err = verifyContent(ruleContentDirPath, manifestKeys)
+		if err != nil {
+			log.Error().Err(err).Msg("Rule content verification failed")
+			return ExitStatusReadContentError
+		}
+
+		contentDir, ruleContentStatusMap, err = content.ParseRuleContentDirCached(ruleContentDirPath, contentCacheCfg)
+		if osPathError, ok := err.(*os.PathError); ok {
+			log.Error().Err(osPathError).Msg("No rules directory")
+			return ExitStatusReadContentError
+		} else if err != nil {
+			log.Error().Err(err).Msg("error happened during parsing rules content dir")
+			return ExitStatusReadContentError
+		}
+	}
+
+	// content is accepted just when it has been parsed, so content that
+	// can't be used is not used after restart either
+	if contentSource != nil {
+		contentSource.Accept(ruleContentDirPath)
+	}
+
+	content.UpdateMetrics(contentDir, ruleContentStatusMap, time.Since(parseStart), time.Now())
 
 	// start the HTTP server on specified port
 	serverInstance = server.New(serverCfg, parsedGroups, contentDir,
@@ -110,6 +253,83 @@ func startService() ExitCode {
 
 	// fill-in additional info used by /info endpoint handler
 	fillInInfoParams(serverInstance.InfoParams)
+	serverInstance.RulesVersion = OCPRulesVersion
+	serverInstance.RulesVersionLoader = rulesVersionLoader
+
+	// rule content can be reloaded via admin endpoint, bundled content is
+	// replaced by deploying new bundle
+	if contentBundle == nil {
+		// loader is called just by content reload and reloads are
+		// serialized, so the path does not need to be guarded
+		loadedPath := ruleContentDirPath
+		serverInstance.ContentLoader = func() (content.RuleContentDirectory, map[string]ctypes.RuleContentStatus, error) {
+			path := ruleContentDirPath
+			if contentSource != nil {
+				path = contentSource.Path()
+			}
+			loadedPath = path
+			verified, err := verifyContent(path, manifestKeys)
+			if err != nil {
+				return content.RuleContentDirectory{}, nil, err
+			}
+			verifiedManifest = verified
+			return content.ParseRuleContentDirCached(path, contentCacheCfg)
+		}
+
+		// fetched content is recorded as installed just when it passes
+		// all checks done by reload, refused content is offered again
+		// by the next check of content source
+		if contentSource != nil {
+			serverInstance.ContentInstalled = func() {
+				contentSource.Accept(loadedPath)
+			}
+		}
+	}
+
+	// verified manifest is provided via /manifest endpoint
+	if len(manifestKeys) > 0 || verifiedManifest != nil {
+		serverInstance.SetManifest(verifiedManifest)
+		// manifest is replaced only when reloaded content is installed
+		serverInstance.ManifestLoader = func() *manifest.Verified {
+			return verifiedManifest
+		}
+	}
+
+	// newer content is installed as soon as it is downloaded
+	if contentSource != nil {
+		var watchCtx context.Context
+		watchCtx, stopContentWatcher = context.WithCancel(context.Background())
+		if checkInterval <= 0 {
+			checkInterval = source.DefaultCheckInterval
+		}
+		go source.Watch(watchCtx, contentSource, checkInterval, func(string) {
+			if _, err := serverInstance.ReloadContent(); err != nil {
+				log.Error().Err(err).Msg("Fetched rule content has not been installed")
+			}
+		})
+	}
+
+	// warnings found in groups configuration are exposed via REST API
+	serverInstance.GroupsFindings = groupsFindings.Warnings()
+
+	// content changes can be announced via webhooks
+	if err := startWebhooks(serverInstance, conf.GetWebhooksConfiguration()); err != nil {
+		log.Error().Err(err).Msg("Webhooks init error")
+		return ExitStatusServerError
+	}
+
+	// installed snapshots can be announced via Kafka
+	if err := startKafkaProducer(serverInstance, conf.GetKafkaProducerConfiguration()); err != nil {
+		log.Error().Err(err).Msg("Kafka producer init error")
+		return ExitStatusServerError
+	}
+
+	// selected configuration options can be changed without restart
+	go handleReloadSignal()
+
+	// graceful shutdown
+	shutdownResult := make(chan ExitCode, 1)
+	go handleShutdownSignal(serverInstance, shutdownResult)
 
 	err = serverInstance.Start()
 	if err != nil {
@@ -117,7 +337,322 @@ func startService() ExitCode {
 		return ExitStatusServerError
 	}
 
-	return ExitStatusOK
+	// the server has been stopped by shutdown signal handler, so let's
+	// wait for the shutdown to finish
+	return <-shutdownResult
+}
+
+// loadContentBundle function reads pre-built content bundle. Nothing is read
+// when no bundle is configured.
+func loadContentBundle(path string) (*bundle.Bundle, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contentBundle, err := bundle.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("path", path).
+		Time("created at", contentBundle.Manifest.CreatedAt).
+		Str("service version", contentBundle.Manifest.ServiceVersion).
+		Str("rules version", contentBundle.Manifest.RulesVersion).
+		Int("rules", contentBundle.Manifest.Rules).
+		Int("groups", contentBundle.Manifest.Groups).
+		Msg("Content read from bundle")
+	return contentBundle, nil
+}
+
+// openS3ContentSource function downloads rule content from object store.
+// Content accepted by previous run is used instead when it is cached, newer
+// content is installed by content watcher.
+func openS3ContentSource(s3Cfg source.S3Configuration) (source.Source, error) {
+	s3Source, err := source.NewS3(s3Cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// content accepted by previous run is used until newer content passes
+	// checks done by content reload, so content refused by reload is not
+	// installed by restart
+	if path := s3Source.Path(); path != "" {
+		log.Info().Str("path", path).Msg("Using cached rule content")
+		return s3Source, nil
+	}
+
+	// requests to object store are limited by configured timeout
+	if _, _, err := s3Source.Fetch(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return s3Source, nil
+}
+
+// verifyContent function verifies content directory against its signed
+// manifest. Nothing is verified when no trusted key is configured.
+func verifyContent(path string, keys []manifest.PublicKey) (*manifest.Verified, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	verified, err := manifest.Verify(path, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("path", path).
+		Str("signer", verified.Signer).
+		Str("version", verified.Manifest.Version).
+		Msg("Rule content verified against manifest")
+	return verified, nil
+}
+
+// openGitContentSource function reads rule content from local git
+// repository at configured ref
+func openGitContentSource(gitCfg source.GitConfiguration) (*source.GitSource, error) {
+	gitSource, err := source.NewGit(gitCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := gitSource.Fetch(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return gitSource, nil
+}
+
+// startWebhooks function constructs webhooks dispatcher when at least one
+// webhook URL is configured and registers it as listener of content events
+func startWebhooks(httpServer *server.HTTPServer, webhooksCfg webhooks.Configuration) error {
+	if !webhooksCfg.Enabled() {
+		return nil
+	}
+
+	dispatcher, err := webhooks.New(webhooksCfg)
+	if err != nil {
+		return err
+	}
+
+	httpServer.Webhooks = dispatcher
+	httpServer.AddEventListener(func(event server.Event) {
+		// reloads that did not change anything are not announced
+		if event.Type == server.EventSnapshot && event.Added == 0 && event.Removed == 0 &&
+			event.Changed == 0 && event.NewlyFailing == 0 {
+			return
+		}
+		dispatcher.Notify(event.Type, event)
+	})
+
+	log.Info().Int("urls", len(webhooksCfg.URLs)).Msg("Webhook notifications enabled")
+	return nil
+}
+
+// startKafkaProducer function connects to Kafka broker when the producer is
+// enabled and publishes message for each installed content snapshot
+func startKafkaProducer(httpServer *server.HTTPServer, producerCfg producer.Configuration) error {
+	if !producerCfg.Enabled {
+		return nil
+	}
+
+	publisher, err := producer.New(producerCfg)
+	if err != nil {
+		return err
+	}
+	kafkaPublisher = publisher
+
+	// the current snapshot is announced by listener immediately, it is
+	// published just when requested, otherwise each restart of the service
+	// would publish the same content again
+	var registering atomic.Bool
+	registering.Store(true)
+	httpServer.AddSnapshotListener(func(change server.SnapshotChange) {
+		if registering.Load() && !producerCfg.PublishOnStart {
+			return
+		}
+
+		var previous *producer.SnapshotInfo
+		if change.Previous != nil {
+			// reloads that did not change anything are not announced
+			if change.Previous.Hash == change.Current.Hash {
+				return
+			}
+			previous = snapshotInfo(*change.Previous)
+		}
+		publisher.Publish(previous, *snapshotInfo(change.Current), change.PreviousContent, change.CurrentContent)
+	})
+	registering.Store(false)
+
+	log.Info().Str("topic", producerCfg.Topic).Msg("Kafka producer enabled")
+	return nil
+}
+
+// snapshotInfo function converts snapshot metadata to form used in Kafka
+// messages
+func snapshotInfo(snapshot server.Snapshot) *producer.SnapshotInfo {
+	return &producer.SnapshotInfo{
+		ID:           snapshot.ID,
+		Hash:         snapshot.Hash,
+		RulesVersion: snapshot.RulesVersion,
+		LoadedAt:     snapshot.LoadedAt,
+	}
+}
+
+// handleShutdownSignal function waits for SIGTERM or SIGINT signal and
+// then stops the server gracefully
+func handleShutdownSignal(httpServer *server.HTTPServer, result chan<- ExitCode) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	received := <-signals
+	log.Info().Str("signal", received.String()).Msg("Shutdown signal received")
+
+	result <- shutdown(httpServer)
+}
+
+// shutdown function marks the server as not ready, waits for configured
+// drain period, stops all servers and flushes all log hooks
+func shutdown(httpServer *server.HTTPServer) ExitCode {
+	exitCode := ExitCode(ExitStatusShutdown)
+
+	httpServer.StartDraining()
+
+	if stopContentWatcher != nil {
+		stopContentWatcher()
+	}
+
+	drainPeriod := httpServer.Config.ShutdownDrainPeriod
+	log.Info().Dur("drain period", drainPeriod).Msg("Draining connections")
+	time.Sleep(drainPeriod)
+
+	timeout := httpServer.Config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Info().Dur("timeout", timeout).Msg("Stopping HTTP server")
+	if err := httpServer.Stop(ctx); err != nil {
+		log.Error().Err(err).Msg("Unable to stop HTTP server gracefully")
+		exitCode = ExitStatusServerError
+	}
+
+	if metricsServerInstance != nil {
+		if err := metricsServerInstance.Stop(ctx); err != nil {
+			log.Error().Err(err).Msg("Unable to stop metrics server gracefully")
+			exitCode = ExitStatusServerError
+		}
+	}
+
+	if httpServer.Webhooks != nil {
+		log.Info().Msg("Stopping webhook deliveries")
+		httpServer.Webhooks.Stop()
+	}
+
+	if kafkaPublisher != nil {
+		log.Info().Msg("Closing Kafka producer")
+		if err := kafkaPublisher.Close(ctx); err != nil {
+			log.Error().Err(err).Msg("Unable to close Kafka producer")
+			exitCode = ExitStatusServerError
+		}
+	}
+
+	log.Info().Msg("Service stopped")
+
+	// Sentry and Kafka log hooks need to be flushed
+	logger.CloseZerolog()
+
+	return exitCode
+}
+
+// handleReloadSignal function reloads configuration each time the SIGHUP
+// signal is received
+func handleReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		reloadConfiguration()
+	}
+}
+
+// reloadConfiguration function loads configuration again and applies all
+// options that can be changed without restart
+func reloadConfiguration() {
+	log.Info().Msg("Reloading configuration")
+
+	applied, rejected, err := conf.Reload(defaultConfigFilename)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload configuration")
+		return
+	}
+
+	for _, option := range rejected {
+		log.Error().Str("option", option).Msg("Configuration option can't be changed without restart, change rejected")
+	}
+
+	for _, option := range applied {
+		log.Info().Str("option", option).Msg("Configuration option changed")
+	}
+
+	// logging level
+	zerolog.SetGlobalLevel(logLevel(conf.GetLoggingConfiguration().LogLevel))
+
+	// allowed origins and disabled rules are replaced even if they are not
+	// changed, the server does nothing in such case
+	if serverInstance != nil {
+		serverCfg := conf.GetServerConfiguration()
+		serverInstance.SetCORSAllowedOrigins(serverCfg.CORSAllowedOrigins)
+		serverInstance.SetDisabledRules(serverCfg.DisabledRules)
+	}
+
+	// groups configuration is read again even if path is not changed,
+	// bundled groups are never replaced
+	if serverInstance != nil && conf.GetContentBundleConfiguration() == "" {
+		reloadGroups(serverInstance)
+	}
+}
+
+// reloadGroups function reads and validates groups configuration and
+// replaces groups used by the server. The original groups are kept when
+// the configuration is not valid.
+func reloadGroups(httpServer *server.HTTPServer) {
+	groupsCfg := conf.GetGroupsConfiguration()
+	parsedGroups, groupsFindings, err := groups.ValidateConfigFile(groupsCfg.ConfigPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload groups configuration")
+		return
+	}
+
+	groups.LogFindings(groupsFindings)
+	if groupsFindings.HasFatal() {
+		log.Error().Msg("Groups configuration is not valid, keeping the original one")
+		return
+	}
+
+	httpServer.SetGroups(parsedGroups, groupsFindings.Warnings())
+	log.Info().Int("groups", len(parsedGroups)).Msg("Groups configuration reloaded")
+}
+
+// logLevel function converts logging level from configuration into zerolog
+// level, the same way as the logger initialization does
+func logLevel(level string) zerolog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "info":
+		return zerolog.InfoLevel
+	case "warn", "warning":
+		return zerolog.WarnLevel
+	case "error":
+		return zerolog.ErrorLevel
+	case "fatal":
+		return zerolog.FatalLevel
+	default:
+		return zerolog.DebugLevel
+	}
 }
 
 // fillInInfoParams function fills-in additional info used by /info endpoint
@@ -158,48 +693,201 @@ func printGroups() ExitCode {
 	return ExitStatusOK
 }
 
-func printRules() ExitCode {
+// printRules function prints parsed content of all rules that match the
+// filter in selected format
+func printRules(rule, ruleType, format string) ExitCode {
 	log.Info().Msg("Printing rules")
 	contentPath := conf.GetContentPathConfiguration()
-	contentDir, _, err := content.ParseRuleContentDir(contentPath)
+	contentDir, parseStatus, err := content.ParseRuleContentDir(contentPath)
 
 	if err != nil {
 		log.Error().Err(err).Msg("Error parsing the content")
 		return ExitStatusReadContentError
 	}
 
-	buffer := new(bytes.Buffer)
-	encoder := json.NewEncoder(buffer)
+	selected, err := filterParseStatus(parseStatus, rule, ruleType, false)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid rule filter")
+		return ExitStatusOther
+	}
 
-	if err := encoder.Encode(contentDir); err == nil {
-		fmt.Println(buffer)
-		return ExitStatusOK
+	rules := make(map[string]content.RuleContent)
+	for name := range selected {
+		if ruleContent, found := contentDir.Rules[name]; found {
+			rules[name] = ruleContent
+		}
 	}
+	contentDir.Rules = rules
 
-	return ExitStatusOther
+	if err := printRuleOutput(contentDir, contentDir, selected, format); err != nil {
+		log.Error().Err(err).Msg("Unable to print rules")
+		return ExitStatusOther
+	}
 
+	return ExitStatusOK
 }
 
-func printParseStatus() ExitCode {
+// printParseStatus function prints parse status of all rules that match the
+// filter in selected format
+func printParseStatus(rule, ruleType string, failedOnly bool, format string) ExitCode {
 	log.Info().Msg("Printing parse status")
 	contentPath := conf.GetContentPathConfiguration()
-	_, parseStatus, err := content.ParseRuleContentDir(contentPath)
+	contentDir, parseStatus, err := content.ParseRuleContentDir(contentPath)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Error parsing the content")
+		return ExitStatusReadContentError
+	}
+
+	selected, err := filterParseStatus(parseStatus, rule, ruleType, failedOnly)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid rule filter")
+		return ExitStatusOther
+	}
+
+	if err := printRuleOutput(selected, contentDir, selected, format); err != nil {
+		log.Error().Err(err).Msg("Unable to print parse status")
+		return ExitStatusOther
+	}
+
+	return ExitStatusOK
+}
+
+// filterParseStatus function selects parse status of rules with given name
+// and type, failed rules only are selected when failedOnly is set. Empty
+// name or type matches all rules.
+func filterParseStatus(parseStatus map[string]ctypes.RuleContentStatus, rule, ruleType string,
+	failedOnly bool) (map[string]ctypes.RuleContentStatus, error) {
+	switch ruleType {
+	case "", content.ExternalRulesGroup, content.InternalRulesGroup:
+	default:
+		return nil, fmt.Errorf("unknown rule type '%s', expected '%s' or '%s'",
+			ruleType, content.ExternalRulesGroup, content.InternalRulesGroup)
+	}
+
+	if _, found := parseStatus[rule]; rule != "" && !found {
+		return nil, fmt.Errorf("rule '%s' not found", rule)
+	}
+
+	selected := make(map[string]ctypes.RuleContentStatus)
+	for name, status := range parseStatus {
+		if rule != "" && name != rule {
+			continue
+		}
+		if ruleType != "" && string(status.RuleType) != ruleType {
+			continue
+		}
+		if failedOnly && status.Loaded {
+			continue
+		}
+		selected[name] = status
+	}
+
+	return selected, nil
+}
+
+// exportContent function parses the rule content and groups and writes them
+// together with parse status into one portable bundle
+func exportContent(format, out string) ExitCode {
+	log.Info().Msg("Exporting content")
+	contentPath := conf.GetContentPathConfiguration()
+
+	manifestKeys, err := manifest.ParsePublicKeys(conf.GetContentManifestConfiguration().PublicKeys)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid manifest public key")
+		return ExitStatusReadContentError
+	}
+	verifiedManifest, err := verifyContent(contentPath, manifestKeys)
+	if err != nil {
+		log.Error().Err(err).Msg("Rule content verification failed")
+		return ExitStatusReadContentError
+	}
 
+	contentDir, parseStatus, err := content.ParseRuleContentDir(contentPath)
 	if err != nil {
 		log.Error().Err(err).Msg("Error parsing the content")
 		return ExitStatusReadContentError
 	}
 
+	groupsMap, err := groups.ParseGroupConfigFile(conf.GetGroupsConfiguration().ConfigPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Groups parsing error")
+		return ExitStatusReadContentError
+	}
+
+	contentBundle, err := bundle.New(contentDir, parseStatus, groupsMap)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to construct content bundle")
+		return ExitStatusOther
+	}
+	contentBundle.Manifest.ServiceVersion = BuildVersion
+	contentBundle.Manifest.RulesVersion = OCPRulesVersion
+	contentBundle.Manifest.Source = verifiedManifest
+
+	if err := writeBundle(contentBundle, format, out); err != nil {
+		log.Error().Err(err).Str("out", out).Msg("Unable to write content bundle")
+		return ExitStatusOther
+	}
+
+	return ExitStatusOK
+}
+
+// writeBundle function writes the bundle into file or to standard output.
+// The file is replaced only when the whole bundle has been written.
+func writeBundle(contentBundle *bundle.Bundle, format, out string) error {
+	if out == "-" {
+		return contentBundle.Encode(os.Stdout, format)
+	}
+
 	buffer := new(bytes.Buffer)
-	encoder := json.NewEncoder(buffer)
+	if err := contentBundle.Encode(buffer, format); err != nil {
+		return err
+	}
 
-	if err := encoder.Encode(parseStatus); err == nil {
-		fmt.Println(buffer)
-		return ExitStatusOK
+	tmpFile, err := os.CreateTemp(filepath.Dir(out), ".bundle-*.tmp")
+	if err != nil {
+		return err
 	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
 
-	return ExitStatusOther
+	_, err = io.Copy(tmpFile, buffer)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), out)
+}
+
+// diffContent function parses two rule content trees and prints report
+// of differences between them
+func diffContent(paths []string, format string) ExitCode {
+	if len(paths) != 2 {
+		fmt.Println("Two content directories need to be provided")
+		return ExitStatusOther
+	}
 
+	oldContent, oldStatusMap, err := content.ParseRuleContentDir(paths[0])
+	if err != nil {
+		log.Error().Err(err).Str("path", paths[0]).Msg("Error parsing the old content")
+		return ExitStatusReadContentError
+	}
+	newContent, newStatusMap, err := content.ParseRuleContentDir(paths[1])
+	if err != nil {
+		log.Error().Err(err).Str("path", paths[1]).Msg("Error parsing the new content")
+		return ExitStatusReadContentError
+	}
+
+	report := contentdiff.New(paths[0], oldContent, oldStatusMap, paths[1], newContent, newStatusMap)
+	if err := report.Write(os.Stdout, format); err != nil {
+		log.Error().Err(err).Msg("Unable to write content diff")
+		return ExitStatusOther
+	}
+
+	return ExitStatusOK
 }
 
 func initInfoLog(msg string) {
@@ -215,32 +903,6 @@ func logVersionInfo() {
 	initInfoLog("OCP rules version:" + OCPRulesVersion)
 }
 
-const helpMessageTemplate = `
-Service to provide content for OCP rules
-
-Usage:
-
-    %+v [command]
-
-The commands are:
-
-    <EMPTY>             starts content service
-    start-service       starts content service
-    help                prints help
-    print-help          prints help
-    print-config        prints current configuration set by files & env variables
-    print-groups        prints current groups configuration
-    print-rules         prints current parsed rules
-    print-parse-status  prints information about all rules that have been parsed
-    print-version-info  prints version info
-
-`
-
-func printHelp() ExitCode {
-	fmt.Printf(helpMessageTemplate, os.Args[0])
-	return ExitStatusOK
-}
-
 func printConfig(config *conf.ConfigStruct) ExitCode {
 	configBytes, err := json.MarshalIndent(config, "", "    ")
 
@@ -254,7 +916,73 @@ func printConfig(config *conf.ConfigStruct) ExitCode {
 	return ExitStatusOK
 }
 
+// output formats supported by commands
+const (
+	outputFormatHuman = "human"
+	outputFormatJSON  = "json"
+	outputFormatYAML  = "yaml"
+	outputFormatTable = "table"
+	outputFormatTree  = "tree"
+)
+
+// validateConfig checks all sections of the configuration and prints all
+// problems found in selected output format
+func validateConfig(config *conf.ConfigStruct, format string) ExitCode {
+	problems := conf.Validate(config)
+
+	switch format {
+	case outputFormatJSON:
+		report := struct {
+			Valid    bool           `json:"valid"`
+			Problems []conf.Problem `json:"problems"`
+		}{
+			Valid:    len(problems) == 0,
+			Problems: problems,
+		}
+
+		reportBytes, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to encode validation report")
+			return ExitStatusOther
+		}
+		fmt.Println(string(reportBytes))
+	case outputFormatHuman:
+		if len(problems) == 0 {
+			fmt.Println("Configuration is valid")
+		} else {
+			fmt.Printf("Configuration is not valid, %d problem(s) found:\n", len(problems))
+			for _, problem := range problems {
+				fmt.Println("    " + problem.String())
+			}
+		}
+	default:
+		fmt.Printf("Unknown output format '%s'\n", format)
+		return ExitStatusOther
+	}
+
+	if len(problems) > 0 {
+		return ExitStatusConfigError
+	}
+	return ExitStatusOK
+}
+
 func main() {
+	command := "start-service"
+	args := os.Args[1:]
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = strings.ToLower(strings.TrimSpace(args[0]))
+		args = args[1:]
+	}
+
+	// configuration file might be selected by command flag, so it needs to
+	// be known before the configuration is loaded
+	if configFile := commandConfigFile(command, args); configFile != "" {
+		if err := conf.SetConfigFile(configFile); err != nil {
+			panic(err)
+		}
+	}
+
 	err := conf.LoadConfiguration(defaultConfigFilename)
 	if err != nil {
 		panic(err)
@@ -270,39 +998,5 @@ func main() {
 		panic(err)
 	}
 
-	command := "start-service"
-
-	if len(os.Args) >= 2 {
-		command = strings.ToLower(strings.TrimSpace(os.Args[1]))
-	}
-
-	os.Exit(int(handleCommand(command)))
-}
-
-func handleCommand(command string) ExitCode {
-	switch command {
-	case "start-service":
-		logVersionInfo()
-
-		errCode := startService()
-		if errCode != ExitStatusOK {
-			return errCode
-		}
-		return ExitStatusOK
-	case "help", "print-help":
-		return printHelp()
-	case "print-config":
-		return printConfig(&conf.Config)
-	case "print-version-info":
-		return printVersionInfo()
-	case "print-groups":
-		return printGroups()
-	case "print-rules":
-		return printRules()
-	case "print-parse-status":
-		return printParseStatus()
-	default:
-		fmt.Printf("\nCommand '%v' not found\n", command)
-		return printHelp()
-	}
+	os.Exit(int(handleCommand(command, args...)))
 }
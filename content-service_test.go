@@ -18,8 +18,12 @@ package main_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -28,7 +32,11 @@ import (
 	"github.com/tisnik/go-capture"
 
 	main "github.com/RedHatInsights/insights-content-service"
+	"github.com/RedHatInsights/insights-content-service/bundle"
 	"github.com/RedHatInsights/insights-content-service/conf"
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/contentdiff"
+	"github.com/RedHatInsights/insights-content-service/server"
 )
 
 // checkStandardOutputStatus tests whether the standard output capturing was successful
@@ -151,6 +159,8 @@ func TestHandleCommandUnknownInput(t *testing.T) {
 // TestInitInfoLog check the function initInfoLog
 func TestInitInfoLog(t *testing.T) {
 	buf := new(bytes.Buffer)
+	logger := log.Logger
+	t.Cleanup(func() { log.Logger = logger })
 	log.Logger = zerolog.New(buf)
 
 	expectedString := "*** message ***"
@@ -165,6 +175,8 @@ func TestInitInfoLog(t *testing.T) {
 // TestLogVersionInfo check the function logVersionInfo
 func TestLogVersionInfo(t *testing.T) {
 	buf := new(bytes.Buffer)
+	logger := log.Logger
+	t.Cleanup(func() { log.Logger = logger })
 	log.Logger = zerolog.New(buf)
 
 	main.LogVersionInfo()
@@ -183,10 +195,97 @@ func TestPrintGroups(t *testing.T) {
 
 // TestPrintRules check the behaviour of the printRules function when no rules are configured
 func TestPrintRules(t *testing.T) {
-	retval := int(main.PrintRules())
+	retval := int(main.PrintRules("", "", "json"))
 	assert.Equal(t, main.ExitStatusReadContentError, retval)
 }
 
+// TestExportContent checks that exported bundle contains parsed content,
+// groups and parse status
+func TestExportContent(t *testing.T) {
+	conf.Config.Content.ContentPath = "tests/content/ok"
+	conf.Config.Groups.ConfigPath = "groups_config.yaml"
+	defer func() {
+		conf.Config.Content.ContentPath = ""
+		conf.Config.Groups.ConfigPath = ""
+	}()
+
+	out := filepath.Join(t.TempDir(), "content.yaml")
+	retval := int(main.ExportContent("yaml", out))
+	assert.Equal(t, main.ExitStatusOK, retval)
+
+	contentBundle, err := bundle.Load(out)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, contentBundle.Manifest.Rules)
+	assert.Len(t, contentBundle.Content.Rules, 2)
+	assert.Len(t, contentBundle.Status, 2)
+	assert.NotEmpty(t, contentBundle.Groups)
+
+	// unknown format is refused and no file is written
+	out = filepath.Join(t.TempDir(), "content.xml")
+	retval = int(main.ExportContent("xml", out))
+	assert.Equal(t, main.ExitStatusOther, retval)
+	assert.NoFileExists(t, out)
+}
+
+// TestExportContentNoContent checks the export-content command when no
+// rules are configured
+func TestExportContentNoContent(t *testing.T) {
+	retval := int(main.ExportContent("json", filepath.Join(t.TempDir(), "content.json")))
+	assert.Equal(t, main.ExitStatusReadContentError, retval)
+}
+
+// TestLoadContentBundle checks that content exported by export-content
+// command can be read back
+func TestLoadContentBundle(t *testing.T) {
+	contentBundle, err := main.LoadContentBundle("")
+	assert.NoError(t, err)
+	assert.Nil(t, contentBundle)
+
+	conf.Config.Content.ContentPath = "tests/content/ok"
+	conf.Config.Groups.ConfigPath = "groups_config.yaml"
+	defer func() {
+		conf.Config.Content.ContentPath = ""
+		conf.Config.Groups.ConfigPath = ""
+	}()
+
+	out := filepath.Join(t.TempDir(), "content.gob")
+	assert.Equal(t, main.ExitStatusOK, int(main.ExportContent("gob", out)))
+
+	contentBundle, err = main.LoadContentBundle(out)
+	assert.NoError(t, err)
+	assert.Len(t, contentBundle.Content.Rules, 2)
+	assert.NotEmpty(t, contentBundle.Groups)
+
+	// bundle of unknown format version is refused
+	out = filepath.Join(t.TempDir(), "content.json")
+	assert.NoError(t, os.WriteFile(out, []byte(`{"manifest": {"format_version": 0}}`), 0o600))
+	_, err = main.LoadContentBundle(out)
+	assert.Error(t, err)
+}
+
+// TestDiffContent checks the diff-content command
+func TestDiffContent(t *testing.T) {
+	captured, err := capture.StandardOutput(func() {
+		retval := int(main.HandleCommand("diff-content", "tests/content/ok", "tests/content/missing", "--format", "json"))
+		assert.Equal(t, main.ExitStatusOK, retval)
+	})
+	checkStandardOutputStatus(t, err)
+
+	var report contentdiff.Report
+	assert.NoError(t, json.Unmarshal([]byte(captured), &report))
+	assert.Equal(t, []string{"rule1"}, report.RemovedRules)
+	assert.Equal(t, []string{"rule1|err_key"}, report.RemovedErrorKeys)
+	assert.Len(t, report.ParseRegressions, 2)
+}
+
+// TestDiffContentInvalidArguments checks the diff-content command with
+// missing or invalid arguments
+func TestDiffContentInvalidArguments(t *testing.T) {
+	assert.Equal(t, main.ExitStatusOther, int(main.DiffContent([]string{"tests/content/ok"}, "text")))
+	assert.Equal(t, main.ExitStatusOther, int(main.DiffContent([]string{"tests/content/ok", "tests/content/ok"}, "html")))
+	assert.Equal(t, main.ExitStatusReadContentError, int(main.DiffContent([]string{"tests/content/ok", "tests/content/xyzzy"}, "text")))
+}
+
 // TestFillInInfoParams test the behaviour of function fillInInfoParams
 func TestFillInInfoParams(t *testing.T) {
 	// map to be used by this unit test
@@ -209,3 +308,123 @@ func TestFillInInfoParams(t *testing.T) {
 	assert.Contains(t, m, "UtilsVersion")
 	assert.Contains(t, m, "OCPRulesVersion")
 }
+
+// validConfig returns configuration that passes all checks
+func validConfig() conf.ConfigStruct {
+	config := conf.ConfigStruct{}
+	config.Server.Address = ":8080"
+	config.Server.APIPrefix = "/api/v1/"
+	config.Server.APISpecFile = "openapi.json"
+	config.Groups.ConfigPath = "groups_config.yaml"
+	config.Content.ContentPath = "tests/content/ok"
+	return config
+}
+
+// TestValidateConfigValid checks the validate-config command for valid configuration
+func TestValidateConfigValid(t *testing.T) {
+	config := validConfig()
+
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusOK, int(main.ValidateConfig(&config, "human")))
+	})
+	checkStandardOutputStatus(t, err)
+	assert.Equal(t, "Configuration is valid\n", captured)
+}
+
+// TestValidateConfigInvalid checks the validate-config command for invalid configuration
+func TestValidateConfigInvalid(t *testing.T) {
+	config := validConfig()
+	config.Server.Address = "localhost"
+	config.Logging.LogLevel = "verbose"
+
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusConfigError, int(main.ValidateConfig(&config, "human")))
+	})
+	checkStandardOutputStatus(t, err)
+	assert.True(t, strings.HasPrefix(captured, "Configuration is not valid, 2 problem(s) found:\n"))
+	assert.Contains(t, captured, "[server] address:")
+	assert.Contains(t, captured, "[logging] log_level:")
+}
+
+// TestValidateConfigJSON checks the validate-config command with JSON output
+func TestValidateConfigJSON(t *testing.T) {
+	config := validConfig()
+	config.Server.APIPrefix = "api"
+
+	captured, err := capture.StandardOutput(func() {
+		assert.Equal(t, main.ExitStatusConfigError, int(main.ValidateConfig(&config, "json")))
+	})
+	checkStandardOutputStatus(t, err)
+
+	var report struct {
+		Valid    bool           `json:"valid"`
+		Problems []conf.Problem `json:"problems"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(captured), &report))
+	assert.False(t, report.Valid)
+	assert.Len(t, report.Problems, 1)
+	assert.Equal(t, "api_prefix", report.Problems[0].Option)
+}
+
+// TestValidateConfigUnknownFormat checks the validate-config command with unknown output format
+func TestValidateConfigUnknownFormat(t *testing.T) {
+	config := validConfig()
+	assert.Equal(t, main.ExitStatusOther, int(main.ValidateConfig(&config, "xml")))
+}
+
+// TestHandleCommandValidateConfig checks that validate-config command accepts output format
+func TestHandleCommandValidateConfig(t *testing.T) {
+	captured, err := capture.StandardOutput(func() {
+		main.HandleCommand("validate-config", "--json")
+	})
+	checkStandardOutputStatus(t, err)
+	assert.True(t, strings.HasPrefix(captured, "{"))
+}
+
+// TestReloadGroups checks that groups used by server are replaced by valid groups configuration
+func TestReloadGroups(t *testing.T) {
+	httpServer := server.New(server.Configuration{}, nil, content.RuleContentDirectory{}, nil)
+
+	conf.Config.Groups.ConfigPath = "tests/groups/hierarchy.yaml"
+	main.ReloadGroups(httpServer)
+	assert.Len(t, httpServer.Groups, 4)
+
+	// invalid configuration must not replace the current one
+	conf.Config.Groups.ConfigPath = "tests/groups/duplicate_keys.yaml"
+	main.ReloadGroups(httpServer)
+	assert.Len(t, httpServer.Groups, 4)
+
+	conf.Config.Groups.ConfigPath = "tests/this_does_not_exist.yaml"
+	main.ReloadGroups(httpServer)
+	assert.Len(t, httpServer.Groups, 4)
+}
+
+// TestLogLevel checks conversion of logging levels from configuration
+func TestLogLevel(t *testing.T) {
+	assert.Equal(t, zerolog.DebugLevel, main.LogLevel(""))
+	assert.Equal(t, zerolog.DebugLevel, main.LogLevel("debug"))
+	assert.Equal(t, zerolog.InfoLevel, main.LogLevel(" INFO "))
+	assert.Equal(t, zerolog.WarnLevel, main.LogLevel("warning"))
+	assert.Equal(t, zerolog.ErrorLevel, main.LogLevel("error"))
+	assert.Equal(t, zerolog.FatalLevel, main.LogLevel("fatal"))
+}
+
+// TestShutdown checks graceful shutdown of running server
+func TestShutdown(t *testing.T) {
+	httpServer := server.New(server.Configuration{
+		// will use any free port
+		Address:             "localhost:0",
+		APIPrefix:           "/api/test/",
+		ShutdownDrainPeriod: 10 * time.Millisecond,
+		ShutdownTimeout:     time.Second,
+	}, nil, content.RuleContentDirectory{}, nil)
+
+	serverStopped := make(chan error)
+	go func() {
+		serverStopped <- httpServer.Start()
+	}()
+
+	assert.Equal(t, main.ExitStatusShutdown, int(main.Shutdown(httpServer)))
+	assert.True(t, httpServer.IsDraining())
+	assert.NoError(t, <-serverStopped)
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/rs/zerolog/log"
+)
+
+// Fingerprint modes
+const (
+	// FingerprintStat computes fingerprint of content directory from paths,
+	// sizes and modification times of all files
+	FingerprintStat = "stat"
+	// FingerprintContent computes fingerprint of content directory from
+	// paths and SHA-256 digests of all files
+	FingerprintContent = "content"
+
+	// cacheFormatVersion needs to be increased whenever structure of parsed
+	// content changes, so entries written by older versions are not used
+	cacheFormatVersion = 1
+
+	cacheFileSuffix = ".json"
+)
+
+// CacheConfiguration represents configuration of cache of parsed content
+type CacheConfiguration struct {
+	// Dir is directory where parsed content is cached, content is not
+	// cached when not set
+	Dir string `mapstructure:"dir" toml:"dir"`
+	// Fingerprint selects how content directory is fingerprinted, either
+	// "stat" (the default) or "content"
+	Fingerprint string `mapstructure:"fingerprint" toml:"fingerprint"`
+}
+
+// cacheEntry is parsed content stored in cache. Entries are encoded as JSON,
+// because gob does not distinguish between nil and empty slices and maps,
+// so content read from cache would not be exactly the same as parsed one.
+type cacheEntry struct {
+	Format      int                                 `json:"format"`
+	Fingerprint string                              `json:"fingerprint"`
+	Content     RuleContentDirectory                `json:"content"`
+	StatusMap   map[string]ctypes.RuleContentStatus `json:"status"`
+}
+
+// ParseRuleContentDirCached function reads parsed content from cache when
+// fingerprint of content directory matches the cached one. Content is parsed
+// by ParseRuleContentDir otherwise and successfully parsed content is
+// stored in cache. Cache is not used when no cache directory is configured.
+func ParseRuleContentDirCached(contentDirPath string, cache CacheConfiguration) (
+	RuleContentDirectory, map[string]ctypes.RuleContentStatus, error) {
+	if cache.Dir == "" {
+		return ParseRuleContentDir(contentDirPath)
+	}
+
+	fingerprint, err := treeFingerprint(contentDirPath, cache.Fingerprint)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to compute fingerprint of content directory")
+		return ParseRuleContentDir(contentDirPath)
+	}
+
+	cacheFile := filepath.Join(cache.Dir, fingerprint+cacheFileSuffix)
+	entry, err := readCacheEntry(cacheFile, fingerprint)
+	if err == nil {
+		GlobalConfig = entry.Content.Config
+		log.Info().
+			Str(directoryAttribute, contentDirPath).
+			Str("fingerprint", fingerprint).
+			Int("rules", len(entry.Content.Rules)).
+			Msg("Parsed content read from cache")
+		return entry.Content, entry.StatusMap, nil
+	}
+	if !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("file", cacheFile).Msg("Invalid cache entry, content will be parsed")
+	}
+
+	contentDir, statusMap, err := ParseRuleContentDir(contentDirPath)
+	if err != nil {
+		return contentDir, statusMap, err
+	}
+
+	err = writeCacheEntry(cache.Dir, cacheFile, &cacheEntry{
+		Format:      cacheFormatVersion,
+		Fingerprint: fingerprint,
+		Content:     contentDir,
+		StatusMap:   statusMap,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("file", cacheFile).Msg("Unable to store parsed content in cache")
+	}
+
+	return contentDir, statusMap, nil
+}
+
+// readCacheEntry function reads cache entry and checks that it belongs to
+// provided fingerprint
+func readCacheEntry(cacheFile, fingerprint string) (*cacheEntry, error) {
+	data, err := os.ReadFile(filepath.Clean(cacheFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Format != cacheFormatVersion || entry.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("cache entry does not match fingerprint %s", fingerprint)
+	}
+	if entry.Content.Rules == nil || entry.StatusMap == nil {
+		return nil, fmt.Errorf("incomplete cache entry")
+	}
+
+	return &entry, nil
+}
+
+// writeCacheEntry function stores cache entry and removes all other entries
+// from cache directory. Entry is written into temporary file first, so
+// partially written entry is never read.
+func writeCacheEntry(cacheDir, cacheFile string, entry *cacheEntry) error {
+	if err := os.MkdirAll(cacheDir, 0o750); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(cacheDir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), cacheFile); err != nil {
+		return err
+	}
+
+	removeStaleCacheEntries(cacheDir, filepath.Base(cacheFile))
+	return nil
+}
+
+// removeStaleCacheEntries function removes all cache entries except the
+// current one
+func removeStaleCacheEntries(cacheDir, current string) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != current && strings.HasSuffix(name, cacheFileSuffix) {
+			if err := os.Remove(filepath.Join(cacheDir, name)); err != nil {
+				log.Warn().Err(err).Str("file", name).Msg("Unable to remove stale cache entry")
+			}
+		}
+	}
+}
+
+// treeFingerprint function computes fingerprint of all files and directories
+// in content directory
+func treeFingerprint(contentDirPath, mode string) (string, error) {
+	if mode == "" {
+		mode = FingerprintStat
+	}
+	if mode != FingerprintStat && mode != FingerprintContent {
+		return "", fmt.Errorf("unknown fingerprint mode '%s'", mode)
+	}
+
+	digest := sha256.New()
+	_, _ = fmt.Fprintf(digest, "%d\x00%s\n", cacheFormatVersion, mode)
+
+	err := filepath.WalkDir(contentDirPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(contentDirPath, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		if entry.IsDir() {
+			_, _ = fmt.Fprintf(digest, "%s/\n", name)
+			return nil
+		}
+
+		// files are read by parser even via symbolic links
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if mode == FingerprintContent && info.Mode().IsRegular() {
+			_, _ = fmt.Fprintf(digest, "%s\x00", name)
+			if err := hashFile(digest, path); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(digest)
+			return nil
+		}
+
+		_, _ = fmt.Fprintf(digest, "%s\x00%s\x00%d\x00%d\n",
+			name, info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// hashFile function writes SHA-256 digest of file content into the hash
+func hashFile(digest hash.Hash, path string) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	fileDigest := sha256.New()
+	if _, err := io.Copy(fileDigest, file); err != nil {
+		return err
+	}
+	_, err = digest.Write(fileDigest.Sum(nil))
+	return err
+}
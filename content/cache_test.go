@@ -0,0 +1,160 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// copyContentDir copies test content into temporary directory
+func copyContentDir(t *testing.T, from string) string {
+	dir := t.TempDir()
+	err := filepath.WalkDir(from, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, name), 0o750)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, name), data, 0o600)
+	})
+	assert.NoError(t, err)
+	return dir
+}
+
+// cacheEntries returns paths of all entries in cache directory
+func cacheEntries(t *testing.T, cacheDir string) []string {
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	assert.NoError(t, err)
+	return entries
+}
+
+// replaceInEntry modifies the only cache entry, so it is possible to check
+// whether content has been read from the cache
+func replaceInEntry(t *testing.T, cacheDir, old, replacement string) {
+	entries := cacheEntries(t, cacheDir)
+	assert.Len(t, entries, 1)
+
+	data, err := os.ReadFile(entries[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), old)
+	assert.NoError(t, os.WriteFile(entries[0], []byte(strings.Replace(string(data), old, replacement, 1)), 0o600))
+}
+
+// TestParseRuleContentDirCached checks that parsed content is read from
+// cache when the content directory has not been changed
+func TestParseRuleContentDirCached(t *testing.T) {
+	contentDir := copyContentDir(t, "../tests/content/ok")
+	cache := content.CacheConfiguration{Dir: t.TempDir()}
+
+	parsed, parsedStatus, err := content.ParseRuleContentDir(contentDir)
+	assert.NoError(t, err)
+
+	// the first call parses the content and stores it in cache
+	cached, cachedStatus, err := content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, cached)
+	assert.Equal(t, parsedStatus, cachedStatus)
+	assert.Len(t, cacheEntries(t, cache.Dir), 1)
+
+	// the same content is read from cache
+	cached, cachedStatus, err = content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, cached)
+	assert.Equal(t, parsedStatus, cachedStatus)
+
+	replaceInEntry(t, cache.Dir, `"Generic message"`, `"cached generic"`)
+	cached, _, err = content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached generic", cached.Rules["rule1"].ErrorKeys["err_key"].Generic)
+
+	// changed content is parsed again and the stale entry is removed
+	summary := filepath.Join(contentDir, "external/rules/rule1/summary.md")
+	assert.NoError(t, os.WriteFile(summary, []byte("new summary"), 0o600))
+
+	cached, _, err = content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, "new summary", cached.Rules["rule1"].Summary)
+	assert.Len(t, cacheEntries(t, cache.Dir), 1)
+}
+
+// TestParseRuleContentDirCachedCorrupted checks that corrupted cache entry
+// is replaced by parsed content
+func TestParseRuleContentDirCachedCorrupted(t *testing.T) {
+	contentDir := copyContentDir(t, "../tests/content/ok")
+	cache := content.CacheConfiguration{Dir: t.TempDir()}
+
+	parsed, _, err := content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+
+	entries := cacheEntries(t, cache.Dir)
+	assert.Len(t, entries, 1)
+	assert.NoError(t, os.WriteFile(entries[0], []byte(`{"format": 1, "content": `), 0o600))
+
+	cached, _, err := content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, cached)
+
+	// the entry has been written again
+	replaceInEntry(t, cache.Dir, `"Generic message"`, `"cached generic"`)
+}
+
+// TestParseRuleContentDirCachedContentFingerprint checks that content
+// fingerprint does not depend on modification times
+func TestParseRuleContentDirCachedContentFingerprint(t *testing.T) {
+	contentDir := copyContentDir(t, "../tests/content/ok")
+	cache := content.CacheConfiguration{Dir: t.TempDir(), Fingerprint: content.FingerprintContent}
+
+	_, _, err := content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	replaceInEntry(t, cache.Dir, `"Generic message"`, `"cached generic"`)
+
+	summary := filepath.Join(contentDir, "external/rules/rule1/summary.md")
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(summary, future, future))
+
+	cached, _, err := content.ParseRuleContentDirCached(contentDir, cache)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached generic", cached.Rules["rule1"].ErrorKeys["err_key"].Generic)
+}
+
+// TestParseRuleContentDirCachedError checks that content that can't be
+// parsed is not cached
+func TestParseRuleContentDirCachedError(t *testing.T) {
+	cache := content.CacheConfiguration{Dir: t.TempDir()}
+
+	_, _, err := content.ParseRuleContentDirCached("../tests/content/no_external", cache)
+	assert.Error(t, err)
+	assert.Empty(t, cacheEntries(t, cache.Dir))
+}
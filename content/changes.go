@@ -0,0 +1,281 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange describes one modified field. Nested fields are separated by
+// dots, for example metadata.impact.name.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// AddedRule contains the full content of a rule that has been added
+type AddedRule struct {
+	Name    string      `json:"name"`
+	Content RuleContent `json:"content"`
+}
+
+// AddedErrorKey contains the full content of an error key that has been
+// added to existing rule
+type AddedErrorKey struct {
+	Name    string              `json:"name"`
+	Content RuleErrorKeyContent `json:"content"`
+}
+
+// ModifiedErrorKey contains all modified fields of an error key
+type ModifiedErrorKey struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// ErrorKeyChanges contains changes in error keys of one rule
+type ErrorKeyChanges struct {
+	Added    []AddedErrorKey    `json:"added"`
+	Removed  []string           `json:"removed"`
+	Modified []ModifiedErrorKey `json:"modified"`
+}
+
+// ModifiedRule contains all modified fields and error keys of a rule
+type ModifiedRule struct {
+	Name      string          `json:"name"`
+	Fields    []FieldChange   `json:"fields"`
+	ErrorKeys ErrorKeyChanges `json:"error_keys"`
+}
+
+// Changes is a structured difference between two versions of rule content.
+// All lists are sorted by name.
+type Changes struct {
+	Config   []FieldChange  `json:"config"`
+	Added    []AddedRule    `json:"added"`
+	Removed  []string       `json:"removed"`
+	Modified []ModifiedRule `json:"modified"`
+}
+
+// IsEmpty returns true if there are no changes
+func (changes Changes) IsEmpty() bool {
+	return len(changes.Config) == 0 && len(changes.Added) == 0 &&
+		len(changes.Removed) == 0 && len(changes.Modified) == 0
+}
+
+// DetailedChanges computes structured difference between old and new rule
+// content. Unlike Compare it reports old and new values of modified fields.
+func DetailedChanges(oldContent, newContent RuleContentDirectory) Changes {
+	changes := Changes{
+		Config:   compareFields("", reflect.ValueOf(oldContent.Config), reflect.ValueOf(newContent.Config)),
+		Added:    []AddedRule{},
+		Removed:  []string{},
+		Modified: []ModifiedRule{},
+	}
+
+	for _, name := range sortedRuleNames(newContent.Rules) {
+		newRule := newContent.Rules[name]
+		oldRule, found := oldContent.Rules[name]
+		if !found {
+			changes.Added = append(changes.Added, AddedRule{Name: name, Content: newRule})
+			continue
+		}
+		if modified, changed := compareRules(name, oldRule, newRule); changed {
+			changes.Modified = append(changes.Modified, modified)
+		}
+	}
+
+	for _, name := range sortedRuleNames(oldContent.Rules) {
+		if _, found := newContent.Rules[name]; !found {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+
+	return changes
+}
+
+// compareRules returns changes in one rule and flag whether the rule has
+// been changed at all
+func compareRules(name string, oldRule, newRule RuleContent) (ModifiedRule, bool) {
+	oldErrorKeys := oldRule.ErrorKeys
+	newErrorKeys := newRule.ErrorKeys
+
+	// error keys are compared separately
+	oldRule.ErrorKeys = nil
+	newRule.ErrorKeys = nil
+
+	modified := ModifiedRule{
+		Name:   name,
+		Fields: compareFields("", reflect.ValueOf(oldRule), reflect.ValueOf(newRule)),
+		ErrorKeys: ErrorKeyChanges{
+			Added:    []AddedErrorKey{},
+			Removed:  []string{},
+			Modified: []ModifiedErrorKey{},
+		},
+	}
+
+	for _, key := range sortedErrorKeyNames(newErrorKeys) {
+		newErrorKey := newErrorKeys[key]
+		oldErrorKey, found := oldErrorKeys[key]
+		if !found {
+			modified.ErrorKeys.Added = append(modified.ErrorKeys.Added,
+				AddedErrorKey{Name: key, Content: newErrorKey})
+			continue
+		}
+		fields := compareFields("", reflect.ValueOf(oldErrorKey), reflect.ValueOf(newErrorKey))
+		if len(fields) > 0 {
+			modified.ErrorKeys.Modified = append(modified.ErrorKeys.Modified,
+				ModifiedErrorKey{Name: key, Fields: fields})
+		}
+	}
+
+	for _, key := range sortedErrorKeyNames(oldErrorKeys) {
+		if _, found := newErrorKeys[key]; !found {
+			modified.ErrorKeys.Removed = append(modified.ErrorKeys.Removed, key)
+		}
+	}
+
+	changed := len(modified.Fields) > 0 ||
+		len(modified.ErrorKeys.Added) > 0 ||
+		len(modified.ErrorKeys.Removed) > 0 ||
+		len(modified.ErrorKeys.Modified) > 0
+
+	return modified, changed
+}
+
+// compareFields compares all fields of two structures of the same type and
+// returns list of changed fields. Nested structures are compared field by
+// field, other values are compared as a whole.
+func compareFields(prefix string, oldValue, newValue reflect.Value) []FieldChange {
+	changes := []FieldChange{}
+
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		name := prefix + fieldName(field)
+		oldField := oldValue.Field(i)
+		newField := newValue.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			changes = append(changes, compareFields(name+".", oldField, newField)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			changes = append(changes, FieldChange{
+				Field: name,
+				Old:   oldField.Interface(),
+				New:   newField.Interface(),
+			})
+		}
+	}
+
+	return changes
+}
+
+// fieldName returns name of field as used in JSON
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func sortedRuleNames(rules map[string]RuleContent) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedErrorKeyNames(errorKeys map[string]RuleErrorKeyContent) []string {
+	names := make([]string, 0, len(errorKeys))
+	for name := range errorKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrorKeyIDs contains IDs of error keys affected by content change. Each ID
+// has form rule|error_key. All lists are sorted.
+type ErrorKeyIDs struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// ErrorKeyID returns ID of error key in form rule|error_key
+func ErrorKeyID(rule, errorKey string) string {
+	return rule + "|" + errorKey
+}
+
+// ChangedErrorKeyIDs returns IDs of error keys that have been added, removed
+// or modified between old and new rule content. Change in rule fields, for
+// example in its summary, modifies all error keys of the rule.
+func ChangedErrorKeyIDs(oldContent, newContent RuleContentDirectory) ErrorKeyIDs {
+	ids := ErrorKeyIDs{
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []string{},
+	}
+
+	changes := DetailedChanges(oldContent, newContent)
+
+	for _, added := range changes.Added {
+		for _, key := range sortedErrorKeyNames(added.Content.ErrorKeys) {
+			ids.Added = append(ids.Added, ErrorKeyID(added.Name, key))
+		}
+	}
+
+	for _, name := range changes.Removed {
+		for _, key := range sortedErrorKeyNames(oldContent.Rules[name].ErrorKeys) {
+			ids.Removed = append(ids.Removed, ErrorKeyID(name, key))
+		}
+	}
+
+	for _, modified := range changes.Modified {
+		for _, added := range modified.ErrorKeys.Added {
+			ids.Added = append(ids.Added, ErrorKeyID(modified.Name, added.Name))
+		}
+		for _, key := range modified.ErrorKeys.Removed {
+			ids.Removed = append(ids.Removed, ErrorKeyID(modified.Name, key))
+		}
+
+		if len(modified.Fields) > 0 {
+			// all error keys that are kept are affected
+			for _, key := range sortedErrorKeyNames(newContent.Rules[modified.Name].ErrorKeys) {
+				if _, found := oldContent.Rules[modified.Name].ErrorKeys[key]; found {
+					ids.Modified = append(ids.Modified, ErrorKeyID(modified.Name, key))
+				}
+			}
+			continue
+		}
+		for _, errorKey := range modified.ErrorKeys.Modified {
+			ids.Modified = append(ids.Modified, ErrorKeyID(modified.Name, errorKey.Name))
+		}
+	}
+
+	sort.Strings(ids.Added)
+	sort.Strings(ids.Removed)
+	sort.Strings(ids.Modified)
+
+	return ids
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// TestDetailedChangesSameContent checks that no changes are found for the same content
+func TestDetailedChangesSameContent(t *testing.T) {
+	contentDir, _, err := content.ParseRuleContentDir("../tests/content/ok/")
+	assert.NoError(t, err)
+
+	changes := content.DetailedChanges(contentDir, contentDir)
+	assert.True(t, changes.IsEmpty())
+}
+
+// TestDetailedChanges checks changes of rules and error keys
+func TestDetailedChanges(t *testing.T) {
+	oldErrorKey := content.RuleErrorKeyContent{Reason: "old reason"}
+	oldErrorKey.Metadata.Impact.Name = "Data Loss"
+	newErrorKey := content.RuleErrorKeyContent{Reason: "new reason"}
+	newErrorKey.Metadata.Impact.Name = "Application Crash"
+
+	oldContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept": {Summary: "kept"},
+			"modified": {
+				Summary: "old summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": oldErrorKey,
+					"EK_REMOVED":  {},
+				},
+			},
+			"removed": {},
+		},
+	}
+	newContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept": {Summary: "kept"},
+			"modified": {
+				Summary: "new summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": newErrorKey,
+					"EK_ADDED":    {Generic: "added"},
+				},
+			},
+			"added": {Generic: "added"},
+		},
+	}
+
+	changes := content.DetailedChanges(oldContent, newContent)
+
+	assert.Empty(t, changes.Config)
+	assert.Equal(t, []content.AddedRule{
+		{Name: "added", Content: content.RuleContent{Generic: "added"}},
+	}, changes.Added)
+	assert.Equal(t, []string{"removed"}, changes.Removed)
+	assert.Equal(t, []content.ModifiedRule{
+		{
+			Name: "modified",
+			Fields: []content.FieldChange{
+				{Field: "summary", Old: "old summary", New: "new summary"},
+			},
+			ErrorKeys: content.ErrorKeyChanges{
+				Added: []content.AddedErrorKey{
+					{Name: "EK_ADDED", Content: content.RuleErrorKeyContent{Generic: "added"}},
+				},
+				Removed: []string{"EK_REMOVED"},
+				Modified: []content.ModifiedErrorKey{
+					{
+						Name: "EK_MODIFIED",
+						Fields: []content.FieldChange{
+							{Field: "metadata.impact.name", Old: "Data Loss", New: "Application Crash"},
+							{Field: "reason", Old: "old reason", New: "new reason"},
+						},
+					},
+				},
+			},
+		},
+	}, changes.Modified)
+}
+
+// TestDetailedChangesConfig checks changes of global content configuration
+func TestDetailedChangesConfig(t *testing.T) {
+	oldContent := content.RuleContentDirectory{}
+	newContent := content.RuleContentDirectory{}
+	newContent.Config.Impact = map[string]int{"Data Loss": 4}
+
+	changes := content.DetailedChanges(oldContent, newContent)
+
+	assert.Len(t, changes.Config, 1)
+	assert.Equal(t, "impact", changes.Config[0].Field)
+	assert.False(t, changes.IsEmpty())
+}
+
+// TestChangedErrorKeyIDs checks IDs of added, removed and modified error keys
+func TestChangedErrorKeyIDs(t *testing.T) {
+	oldContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK": {Reason: "reason"},
+				},
+			},
+			"summary": {
+				Summary: "old summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK1":     {},
+					"EK2":     {},
+					"EK_GONE": {},
+				},
+			},
+			"keys": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": {Reason: "old"},
+					"EK_KEPT":     {},
+				},
+			},
+			"removed": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK": {},
+				},
+			},
+		},
+	}
+	newContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept": oldContent.Rules["kept"],
+			"summary": {
+				Summary: "new summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK1":    {},
+					"EK2":    {},
+					"EK_NEW": {},
+				},
+			},
+			"keys": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": {Reason: "new"},
+					"EK_KEPT":     {},
+				},
+			},
+			"added": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_B": {},
+					"EK_A": {},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, content.ErrorKeyIDs{
+		Added:    []string{"added|EK_A", "added|EK_B", "summary|EK_NEW"},
+		Removed:  []string{"removed|EK", "summary|EK_GONE"},
+		Modified: []string{"keys|EK_MODIFIED", "summary|EK1", "summary|EK2"},
+	}, content.ChangedErrorKeyIDs(oldContent, newContent))
+
+	assert.Equal(t, content.ErrorKeyIDs{
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []string{},
+	}, content.ChangedErrorKeyIDs(newContent, newContent))
+}
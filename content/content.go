@@ -18,6 +18,9 @@ limitations under the License.
 package content
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -506,3 +509,14 @@ func ParseRuleContentDir(contentDirPath string) (RuleContentDirectory, map[strin
 
 	return contentDir, ruleContentStatusMap, err
 }
+
+// Hash returns SHA-256 hash of provided rule content. JSON encoding is used
+// because it is stable for maps.
+func Hash(contentDir RuleContentDirectory) (string, error) {
+	encoded, err := json.Marshal(contentDir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
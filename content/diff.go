@@ -0,0 +1,91 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"reflect"
+	"sort"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+)
+
+// Diff summarizes differences between two versions of rule content. All
+// lists contain rule names sorted alphabetically.
+type Diff struct {
+	// Added contains rules that were not loaded before
+	Added []string `json:"added"`
+	// Removed contains loaded rules that are not present anymore
+	Removed []string `json:"removed"`
+	// Changed contains rules whose content has been changed
+	Changed []string `json:"changed"`
+	// NewlyFailing contains rules that failed to parse and that did not
+	// fail before
+	NewlyFailing []string `json:"newly_failing"`
+	// Dropped is number of previously loaded rules that are not loaded in
+	// the new content, either because they were removed or because they
+	// fail to parse now
+	Dropped int `json:"dropped"`
+}
+
+// Compare computes differences between old and new rule content and their
+// parsing statuses
+func Compare(oldContent RuleContentDirectory, oldStatusMap map[string]ctypes.RuleContentStatus,
+	newContent RuleContentDirectory, newStatusMap map[string]ctypes.RuleContentStatus) Diff {
+	diff := Diff{
+		Added:        []string{},
+		Removed:      []string{},
+		Changed:      []string{},
+		NewlyFailing: []string{},
+	}
+
+	for name, newRule := range newContent.Rules {
+		oldRule, found := oldContent.Rules[name]
+		switch {
+		case !found:
+			diff.Added = append(diff.Added, name)
+		case !reflect.DeepEqual(oldRule, newRule):
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+
+	for name := range oldContent.Rules {
+		if _, found := newContent.Rules[name]; found {
+			continue
+		}
+		diff.Dropped++
+		// rules that fail to parse now are reported separately
+		if status, found := newStatusMap[name]; !found || status.Loaded {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	for name, status := range newStatusMap {
+		if status.Loaded {
+			continue
+		}
+		if oldStatus, found := oldStatusMap[name]; !found || oldStatus.Loaded {
+			diff.NewlyFailing = append(diff.NewlyFailing, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.NewlyFailing)
+
+	return diff
+}
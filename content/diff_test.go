@@ -0,0 +1,88 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"testing"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// TestCompareSameContent checks that no differences are found for the same content
+func TestCompareSameContent(t *testing.T) {
+	contentDir, statusMap, err := content.ParseRuleContentDir("../tests/content/ok/")
+	assert.NoError(t, err)
+
+	diff := content.Compare(contentDir, statusMap, contentDir, statusMap)
+
+	assert.Equal(t, content.Diff{
+		Added:        []string{},
+		Removed:      []string{},
+		Changed:      []string{},
+		NewlyFailing: []string{},
+	}, diff)
+}
+
+// TestCompare checks detection of added, removed, changed and failing rules
+func TestCompare(t *testing.T) {
+	loaded := ctypes.RuleContentStatus{Loaded: true}
+	failing := ctypes.RuleContentStatus{Loaded: false, Error: "bad"}
+
+	oldContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept":    {Generic: "kept"},
+			"changed": {Generic: "before"},
+			"removed": {Generic: "removed"},
+			"broken":  {Generic: "broken"},
+		},
+	}
+	oldStatus := map[string]ctypes.RuleContentStatus{
+		"kept":        loaded,
+		"changed":     loaded,
+		"removed":     loaded,
+		"broken":      loaded,
+		"still_fails": failing,
+	}
+
+	newContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"kept":    {Generic: "kept"},
+			"changed": {Generic: "after"},
+			"added":   {Generic: "added"},
+		},
+	}
+	newStatus := map[string]ctypes.RuleContentStatus{
+		"kept":        loaded,
+		"changed":     loaded,
+		"added":       loaded,
+		"broken":      failing,
+		"still_fails": failing,
+	}
+
+	diff := content.Compare(oldContent, oldStatus, newContent, newStatus)
+
+	assert.Equal(t, content.Diff{
+		Added:        []string{"added"},
+		Removed:      []string{"removed"},
+		Changed:      []string{"changed"},
+		NewlyFailing: []string{"broken"},
+		Dropped:      2,
+	}, diff)
+}
@@ -0,0 +1,242 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+// This source file contains metrics describing the rule content that is
+// currently served. The following metrics are exposed:
+//
+// content_rules_loaded - number of successfully parsed rules per rule type
+//
+// content_rules_invalid - number of rules that failed to parse per rule type
+//
+// content_error_keys - number of error keys per impact
+//
+// content_parse_duration_seconds - time spent parsing the content
+//
+// content_snapshot_timestamp_seconds - time when the content was loaded
+//
+// content_reloads_total - number of content reloads
+//
+// content_rule_loaded - info metric for each rule, 1 when the rule has been
+// parsed successfully, 0 when it failed to parse
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Labels used by content metrics
+const (
+	ruleTypeLabel = "type"
+	impactLabel   = "impact"
+	ruleLabel     = "rule"
+)
+
+var (
+	// RulesLoaded is a gauge vector with number of loaded rules per rule type
+	RulesLoaded *prometheus.GaugeVec = newRulesLoaded("")
+
+	// RulesInvalid is a gauge vector with number of rules that failed to
+	// parse per rule type
+	RulesInvalid *prometheus.GaugeVec = newRulesInvalid("")
+
+	// ErrorKeys is a gauge vector with number of error keys per impact
+	ErrorKeys *prometheus.GaugeVec = newErrorKeys("")
+
+	// ParseDuration is a histogram with time spent parsing the content
+	ParseDuration prometheus.Histogram = newParseDuration("")
+
+	// SnapshotTimestamp is a gauge with time when the content was loaded
+	SnapshotTimestamp prometheus.Gauge = newSnapshotTimestamp("")
+
+	// Reloads is a counter of content reloads
+	Reloads prometheus.Counter = newReloads("")
+
+	// RuleLoaded is an info metric with one time series per rule
+	RuleLoaded *prometheus.GaugeVec = newRuleLoaded("")
+)
+
+// ruleLabels are label values of RuleLoaded time series
+type ruleLabels struct {
+	name     string
+	ruleType string
+}
+
+// Values set by the last update of metrics, so time series of label values
+// that are not present in new content can be deleted
+var (
+	metricsMutex       sync.Mutex
+	reportedLoaded     map[string]float64
+	reportedInvalid    map[string]float64
+	reportedErrorKeys  map[string]float64
+	reportedRuleLoaded map[ruleLabels]float64
+)
+
+func newRulesLoaded(namespace string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "content_rules_loaded",
+		Help:      "The number of rules that have been parsed successfully",
+	}, []string{ruleTypeLabel})
+}
+
+func newRulesInvalid(namespace string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "content_rules_invalid",
+		Help:      "The number of rules that failed to parse",
+	}, []string{ruleTypeLabel})
+}
+
+func newErrorKeys(namespace string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "content_error_keys",
+		Help:      "The number of error keys per impact",
+	}, []string{impactLabel})
+}
+
+func newParseDuration(namespace string) prometheus.Histogram {
+	return promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "content_parse_duration_seconds",
+		Help:      "Time spent parsing the rule content",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	})
+}
+
+func newSnapshotTimestamp(namespace string) prometheus.Gauge {
+	return promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "content_snapshot_timestamp_seconds",
+		Help:      "Unix time when the served content has been loaded",
+	})
+}
+
+func newReloads(namespace string) prometheus.Counter {
+	return promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "content_reloads_total",
+		Help:      "The total number of content reloads",
+	})
+}
+
+func newRuleLoaded(namespace string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "content_rule_loaded",
+		Help:      "Set to 1 for rules that have been parsed successfully, 0 for rules that failed to parse",
+	}, []string{ruleLabel, ruleTypeLabel})
+}
+
+// AddMetricsWithNamespace overwrite the content metrics with namespaced
+// version of them
+func AddMetricsWithNamespace(namespace string) {
+	prometheus.Unregister(RulesLoaded)
+	prometheus.Unregister(RulesInvalid)
+	prometheus.Unregister(ErrorKeys)
+	prometheus.Unregister(ParseDuration)
+	prometheus.Unregister(SnapshotTimestamp)
+	prometheus.Unregister(Reloads)
+	prometheus.Unregister(RuleLoaded)
+
+	RulesLoaded = newRulesLoaded(namespace)
+	RulesInvalid = newRulesInvalid(namespace)
+	ErrorKeys = newErrorKeys(namespace)
+	ParseDuration = newParseDuration(namespace)
+	SnapshotTimestamp = newSnapshotTimestamp(namespace)
+	Reloads = newReloads(namespace)
+	RuleLoaded = newRuleLoaded(namespace)
+}
+
+// UpdateMetrics sets all content metrics to describe the provided content.
+// Gauges are set directly, so scrapes never see them partially updated, and
+// just time series of label values missing in the provided content are
+// deleted, so rules that disappeared from the content are not reported
+// anymore.
+func UpdateMetrics(contentDir RuleContentDirectory,
+	ruleContentStatusMap map[string]ctypes.RuleContentStatus,
+	parseDuration time.Duration, loadedAt time.Time) {
+	// make sure both rule types are always reported
+	rulesLoaded := map[string]float64{ExternalRulesGroup: 0, InternalRulesGroup: 0}
+	rulesInvalid := map[string]float64{ExternalRulesGroup: 0, InternalRulesGroup: 0}
+	ruleLoaded := make(map[ruleLabels]float64, len(ruleContentStatusMap))
+
+	for name, status := range ruleContentStatusMap {
+		ruleType := string(status.RuleType)
+		labels := ruleLabels{name: name, ruleType: ruleType}
+		// both gauges are reported for each rule type
+		if _, found := rulesLoaded[ruleType]; !found {
+			rulesLoaded[ruleType] = 0
+			rulesInvalid[ruleType] = 0
+		}
+		if status.Loaded {
+			rulesLoaded[ruleType]++
+			ruleLoaded[labels] = 1
+		} else {
+			rulesInvalid[ruleType]++
+			ruleLoaded[labels] = 0
+		}
+	}
+
+	errorKeys := map[string]float64{}
+	for _, ruleContent := range contentDir.Rules {
+		for _, errorKey := range ruleContent.ErrorKeys {
+			errorKeys[strconv.Itoa(errorKey.Metadata.Impact.Impact)]++
+		}
+	}
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	setGauges(RulesLoaded, rulesLoaded, reportedLoaded)
+	setGauges(RulesInvalid, rulesInvalid, reportedInvalid)
+	setGauges(ErrorKeys, errorKeys, reportedErrorKeys)
+	reportedLoaded, reportedInvalid, reportedErrorKeys = rulesLoaded, rulesInvalid, errorKeys
+
+	for labels, value := range ruleLoaded {
+		RuleLoaded.WithLabelValues(labels.name, labels.ruleType).Set(value)
+	}
+	for labels := range reportedRuleLoaded {
+		if _, found := ruleLoaded[labels]; !found {
+			RuleLoaded.DeleteLabelValues(labels.name, labels.ruleType)
+		}
+	}
+	reportedRuleLoaded = ruleLoaded
+
+	ParseDuration.Observe(parseDuration.Seconds())
+	SnapshotTimestamp.Set(float64(loadedAt.Unix()))
+}
+
+// setGauges sets gauges with one label to provided values and deletes time
+// series of label values set by the previous update that are not provided
+// anymore
+func setGauges(gauges *prometheus.GaugeVec, values, previous map[string]float64) {
+	for label, value := range values {
+		gauges.WithLabelValues(label).Set(value)
+	}
+	for label := range previous {
+		if _, found := values[label]; !found {
+			gauges.DeleteLabelValues(label)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2020, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content_test
+
+import (
+	"testing"
+	"time"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// errorKeyWithImpact constructs error key content with given impact
+func errorKeyWithImpact(impact int) content.RuleErrorKeyContent {
+	errorKey := content.RuleErrorKeyContent{}
+	errorKey.Metadata.Impact.Impact = impact
+	return errorKey
+}
+
+// TestUpdateMetrics checks that content metrics describe provided content
+func TestUpdateMetrics(t *testing.T) {
+	contentDir := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"rule1": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithImpact(2),
+					"ek2": errorKeyWithImpact(4),
+				},
+			},
+			"rule2": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithImpact(2),
+				},
+			},
+		},
+	}
+	statusMap := map[string]ctypes.RuleContentStatus{
+		"rule1": {RuleType: content.ExternalRulesGroup, Loaded: true},
+		"rule2": {RuleType: content.InternalRulesGroup, Loaded: true},
+		"rule3": {RuleType: content.InternalRulesGroup, Loaded: false, Error: "bad"},
+	}
+	loadedAt := time.Unix(1600000000, 0)
+
+	content.UpdateMetrics(contentDir, statusMap, 2*time.Second, loadedAt)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.RulesLoaded.WithLabelValues(content.ExternalRulesGroup)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.RulesLoaded.WithLabelValues(content.InternalRulesGroup)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(content.RulesInvalid.WithLabelValues(content.ExternalRulesGroup)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.RulesInvalid.WithLabelValues(content.InternalRulesGroup)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(content.ErrorKeys.WithLabelValues("2")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.ErrorKeys.WithLabelValues("4")))
+	assert.Equal(t, 1600000000.0, testutil.ToFloat64(content.SnapshotTimestamp))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.RuleLoaded.WithLabelValues("rule1", content.ExternalRulesGroup)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(content.RuleLoaded.WithLabelValues("rule3", content.InternalRulesGroup)))
+	assert.Equal(t, 3, testutil.CollectAndCount(content.RuleLoaded))
+}
+
+// TestUpdateMetricsRuleDisappeared checks that rules missing in new content
+// are not reported anymore
+func TestUpdateMetricsRuleDisappeared(t *testing.T) {
+	statusMap := map[string]ctypes.RuleContentStatus{
+		"rule1": {RuleType: content.ExternalRulesGroup, Loaded: true},
+		"rule2": {RuleType: content.ExternalRulesGroup, Loaded: true},
+	}
+	content.UpdateMetrics(content.RuleContentDirectory{}, statusMap, time.Second, time.Now())
+	assert.Equal(t, 2, testutil.CollectAndCount(content.RuleLoaded))
+
+	delete(statusMap, "rule2")
+	content.UpdateMetrics(content.RuleContentDirectory{}, statusMap, time.Second, time.Now())
+	assert.Equal(t, 1, testutil.CollectAndCount(content.RuleLoaded))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.RulesLoaded.WithLabelValues(content.ExternalRulesGroup)))
+}
+
+// TestUpdateMetricsImpactDisappeared checks that error keys of impact
+// missing in new content are not reported anymore while the other ones are
+// kept
+func TestUpdateMetricsImpactDisappeared(t *testing.T) {
+	contentDir := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"rule1": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithImpact(1),
+					"ek2": errorKeyWithImpact(3),
+				},
+			},
+		},
+	}
+	content.UpdateMetrics(contentDir, nil, time.Second, time.Now())
+	assert.Equal(t, 2, testutil.CollectAndCount(content.ErrorKeys))
+
+	delete(contentDir.Rules["rule1"].ErrorKeys, "ek2")
+	content.UpdateMetrics(contentDir, nil, time.Second, time.Now())
+	assert.Equal(t, 1, testutil.CollectAndCount(content.ErrorKeys))
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.ErrorKeys.WithLabelValues("1")))
+}
+
+// TestAddMetricsWithNamespace checks that namespaced metrics can be used
+func TestAddMetricsWithNamespace(t *testing.T) {
+	content.AddMetricsWithNamespace("content_test")
+	content.Reloads.Inc()
+	assert.Equal(t, 1.0, testutil.ToFloat64(content.Reloads))
+	assert.Contains(t, content.Reloads.Desc().String(), `"content_test_content_reloads_total"`)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contentdiff
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// Report formats
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Write method renders the report in selected format
+func (report *Report) Write(writer io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return report.WriteText(writer)
+	case FormatJSON:
+		return report.WriteJSON(writer)
+	case FormatMarkdown:
+		return report.WriteMarkdown(writer)
+	default:
+		return fmt.Errorf("unknown report format '%s'", format)
+	}
+}
+
+// WriteJSON method renders the report as indented JSON
+func (report *Report) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(report)
+}
+
+// WriteText method renders the report as plain text
+func (report *Report) WriteText(writer io.Writer) error {
+	out := bufio.NewWriter(writer)
+
+	fmt.Fprintf(out, "Content diff %s -> %s\n", report.Old, report.New)
+	if report.IsEmpty() {
+		fmt.Fprintln(out, "\nNo changes")
+		return out.Flush()
+	}
+
+	writeTextSection(out, "Changed global configuration", len(report.Config), func() {
+		for _, change := range report.Config {
+			fmt.Fprintf(out, "    %s: %s -> %s\n", change.Field, formatValue(change.Old), formatValue(change.New))
+		}
+	})
+	writeTextList(out, "Added rules", report.AddedRules)
+	writeTextList(out, "Removed rules", report.RemovedRules)
+	writeTextList(out, "Added error keys", report.AddedErrorKeys)
+	writeTextList(out, "Removed error keys", report.RemovedErrorKeys)
+	writeTextSection(out, "Changed metadata", len(report.Metadata), func() {
+		for _, change := range report.Metadata {
+			fmt.Fprintf(out, "    %s %s: %s -> %s\n", changeID(change.Rule, change.ErrorKey),
+				change.Field, formatValue(change.Old), formatValue(change.New))
+		}
+	})
+	writeTextSection(out, "Changed markdown", len(report.Markdown), func() {
+		for _, change := range report.Markdown {
+			fmt.Fprint(out, indent(change.Diff, "    "))
+		}
+	})
+	writeTextSection(out, "Parse status regressions", len(report.ParseRegressions), func() {
+		for _, regression := range report.ParseRegressions {
+			fmt.Fprintf(out, "    %s (%s): %s\n", regression.Rule, regression.Type, regression.Error)
+		}
+	})
+
+	return out.Flush()
+}
+
+// WriteMarkdown method renders the report as markdown, so it can be pasted
+// into merge requests
+func (report *Report) WriteMarkdown(writer io.Writer) error {
+	out := bufio.NewWriter(writer)
+
+	fmt.Fprintf(out, "## Content diff `%s` → `%s`\n", report.Old, report.New)
+	if report.IsEmpty() {
+		fmt.Fprintln(out, "\nNo changes")
+		return out.Flush()
+	}
+
+	writeMarkdownTable(out, "Changed global configuration", []string{"Field", "Old", "New"},
+		len(report.Config), func(i int) []string {
+			change := report.Config[i]
+			return []string{change.Field, formatValue(change.Old), formatValue(change.New)}
+		})
+	writeMarkdownList(out, "Added rules", report.AddedRules)
+	writeMarkdownList(out, "Removed rules", report.RemovedRules)
+	writeMarkdownList(out, "Added error keys", report.AddedErrorKeys)
+	writeMarkdownList(out, "Removed error keys", report.RemovedErrorKeys)
+	writeMarkdownTable(out, "Changed metadata", []string{"Rule", "Error key", "Field", "Old", "New"},
+		len(report.Metadata), func(i int) []string {
+			change := report.Metadata[i]
+			return []string{change.Rule, change.ErrorKey, change.Field, formatValue(change.Old), formatValue(change.New)}
+		})
+	if len(report.Markdown) > 0 {
+		fmt.Fprintf(out, "\n### Changed markdown (%d)\n", len(report.Markdown))
+		for _, change := range report.Markdown {
+			fmt.Fprintf(out, "\n```diff\n%s```\n", change.Diff)
+		}
+	}
+	writeMarkdownTable(out, "Parse status regressions", []string{"Rule", "Type", "Error"},
+		len(report.ParseRegressions), func(i int) []string {
+			regression := report.ParseRegressions[i]
+			return []string{regression.Rule, string(regression.Type), regression.Error}
+		})
+
+	return out.Flush()
+}
+
+// writeTextSection function writes section header followed by section items
+// written by provided function, empty sections are skipped
+func writeTextSection(out io.Writer, title string, count int, writeItems func()) {
+	if count == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n%s (%d):\n", title, count)
+	writeItems()
+}
+
+// writeTextList function writes section containing list of names
+func writeTextList(out io.Writer, title string, names []string) {
+	writeTextSection(out, title, len(names), func() {
+		for _, name := range names {
+			fmt.Fprintf(out, "    %s\n", name)
+		}
+	})
+}
+
+// writeMarkdownList function writes markdown section containing list of
+// names, empty sections are skipped
+func writeMarkdownList(out io.Writer, title string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n### %s (%d)\n\n", title, len(names))
+	for _, name := range names {
+		fmt.Fprintf(out, "* `%s`\n", name)
+	}
+}
+
+// writeMarkdownTable function writes markdown section containing table with
+// provided columns, empty sections are skipped
+func writeMarkdownTable(out io.Writer, title string, columns []string, count int, row func(int) []string) {
+	if count == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n### %s (%d)\n\n", title, count)
+	fmt.Fprintf(out, "| %s |\n", strings.Join(columns, " | "))
+	fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", len(columns)))
+	for i := 0; i < count; i++ {
+		cells := row(i)
+		for j, cell := range cells {
+			cells[j] = escapeMarkdownCell(cell)
+		}
+		fmt.Fprintf(out, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// escapeMarkdownCell function escapes characters that would break markdown
+// table
+func escapeMarkdownCell(cell string) string {
+	cell = strings.ReplaceAll(cell, "|", "\\|")
+	return strings.ReplaceAll(cell, "\n", "<br>")
+}
+
+// changeID function returns ID of changed rule or error key
+func changeID(rule, errorKey string) string {
+	if errorKey == "" {
+		return rule
+	}
+	return content.ErrorKeyID(rule, errorKey)
+}
+
+// formatValue function returns value of changed field encoded as JSON, so
+// strings, numbers and lists are easy to distinguish
+func formatValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// indent function prefixes all lines of text
+func indent(text, prefix string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
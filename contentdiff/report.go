@@ -0,0 +1,224 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package contentdiff contains implementation of report describing
+// differences between two rule content trees as seen by customers: added
+// and removed rules and error keys, changed metadata, changed markdown texts
+// and rules that fail to parse in the new content. The report can be
+// rendered as plain text, JSON or markdown.
+package contentdiff
+
+import (
+	"sort"
+	"strings"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// number of unchanged lines shown around each change in unified diffs
+const diffContext = 3
+
+// markdownFields contains fields of rules and error keys read from markdown
+// files
+var markdownFields = map[string]bool{
+	"generic":    true,
+	"summary":    true,
+	"resolution": true,
+	"more_info":  true,
+	"reason":     true,
+}
+
+// MetadataChange describes one changed metadata field of rule or error key.
+// ErrorKey is empty for fields of the rule itself.
+type MetadataChange struct {
+	Rule     string      `json:"rule"`
+	ErrorKey string      `json:"error_key,omitempty"`
+	Field    string      `json:"field"`
+	Old      interface{} `json:"old"`
+	New      interface{} `json:"new"`
+}
+
+// MarkdownChange describes one changed markdown text of rule or error key
+// together with unified diff of the text. ErrorKey is empty for texts of the
+// rule itself.
+type MarkdownChange struct {
+	Rule     string `json:"rule"`
+	ErrorKey string `json:"error_key,omitempty"`
+	Field    string `json:"field"`
+	Diff     string `json:"diff"`
+}
+
+// ParseRegression describes rule that fails to parse in the new content
+// while it has been parsed successfully in the old one or did not exist
+type ParseRegression struct {
+	Rule  string          `json:"rule"`
+	Type  ctypes.RuleType `json:"type"`
+	Error string          `json:"error"`
+}
+
+// Report describes differences between old and new rule content. All lists
+// are sorted, error keys are identified by rule|error_key IDs.
+type Report struct {
+	Old              string                `json:"old"`
+	New              string                `json:"new"`
+	Config           []content.FieldChange `json:"config"`
+	AddedRules       []string              `json:"added_rules"`
+	RemovedRules     []string              `json:"removed_rules"`
+	AddedErrorKeys   []string              `json:"added_error_keys"`
+	RemovedErrorKeys []string              `json:"removed_error_keys"`
+	Metadata         []MetadataChange      `json:"metadata"`
+	Markdown         []MarkdownChange      `json:"markdown"`
+	ParseRegressions []ParseRegression     `json:"parse_regressions"`
+}
+
+// IsEmpty returns true if the report contains no difference
+func (report *Report) IsEmpty() bool {
+	return len(report.Config) == 0 &&
+		len(report.AddedRules) == 0 && len(report.RemovedRules) == 0 &&
+		len(report.AddedErrorKeys) == 0 && len(report.RemovedErrorKeys) == 0 &&
+		len(report.Metadata) == 0 && len(report.Markdown) == 0 &&
+		len(report.ParseRegressions) == 0
+}
+
+// New function computes report of differences between old and new rule
+// content. Paths are used only as labels in the report.
+func New(oldPath string, oldContent content.RuleContentDirectory, oldStatusMap map[string]ctypes.RuleContentStatus,
+	newPath string, newContent content.RuleContentDirectory, newStatusMap map[string]ctypes.RuleContentStatus) *Report {
+	report := &Report{
+		Old:              oldPath,
+		New:              newPath,
+		AddedRules:       []string{},
+		RemovedRules:     []string{},
+		AddedErrorKeys:   []string{},
+		RemovedErrorKeys: []string{},
+		Metadata:         []MetadataChange{},
+		Markdown:         []MarkdownChange{},
+		ParseRegressions: []ParseRegression{},
+	}
+
+	diff := content.Compare(oldContent, oldStatusMap, newContent, newStatusMap)
+	changes := content.DetailedChanges(oldContent, newContent)
+	report.Config = changes.Config
+
+	for _, added := range changes.Added {
+		report.AddedRules = append(report.AddedRules, added.Name)
+		for key := range added.Content.ErrorKeys {
+			report.AddedErrorKeys = append(report.AddedErrorKeys, content.ErrorKeyID(added.Name, key))
+		}
+	}
+
+	// rules that fail to parse now are reported as parse regressions only
+	report.RemovedRules = append(report.RemovedRules, diff.Removed...)
+	for _, name := range diff.Removed {
+		for key := range oldContent.Rules[name].ErrorKeys {
+			report.RemovedErrorKeys = append(report.RemovedErrorKeys, content.ErrorKeyID(name, key))
+		}
+	}
+
+	for _, modified := range changes.Modified {
+		for _, added := range modified.ErrorKeys.Added {
+			report.AddedErrorKeys = append(report.AddedErrorKeys, content.ErrorKeyID(modified.Name, added.Name))
+		}
+		for _, key := range modified.ErrorKeys.Removed {
+			report.RemovedErrorKeys = append(report.RemovedErrorKeys, content.ErrorKeyID(modified.Name, key))
+		}
+		report.addFieldChanges(modified.Name, "", modified.Fields)
+		for _, errorKey := range modified.ErrorKeys.Modified {
+			report.addFieldChanges(modified.Name, errorKey.Name, errorKey.Fields)
+		}
+	}
+
+	for _, name := range diff.NewlyFailing {
+		status := newStatusMap[name]
+		report.ParseRegressions = append(report.ParseRegressions, ParseRegression{
+			Rule:  name,
+			Type:  status.RuleType,
+			Error: string(status.Error),
+		})
+	}
+
+	sort.Strings(report.AddedErrorKeys)
+	sort.Strings(report.RemovedErrorKeys)
+
+	return report
+}
+
+// addFieldChanges method sorts changed fields into metadata and markdown
+// changes
+func (report *Report) addFieldChanges(rule, errorKey string, fields []content.FieldChange) {
+	for _, field := range fields {
+		oldText, oldIsText := field.Old.(string)
+		newText, newIsText := field.New.(string)
+
+		if markdownFields[field.Field] && oldIsText && newIsText {
+			report.Markdown = append(report.Markdown, MarkdownChange{
+				Rule:     rule,
+				ErrorKey: errorKey,
+				Field:    field.Field,
+				Diff:     unifiedDiff(markdownPath(rule, errorKey, field.Field), oldText, newText),
+			})
+			continue
+		}
+
+		report.Metadata = append(report.Metadata, MetadataChange{
+			Rule:     rule,
+			ErrorKey: errorKey,
+			Field:    field.Field,
+			Old:      field.Old,
+			New:      field.New,
+		})
+	}
+}
+
+// markdownPath function returns path of markdown file relative to rule
+// directory, it is used as file name in unified diff
+func markdownPath(rule, errorKey, field string) string {
+	if errorKey == "" {
+		return rule + "/" + field + ".md"
+	}
+	return rule + "/" + errorKey + "/" + field + ".md"
+}
+
+// unifiedDiff function returns unified diff of old and new text
+func unifiedDiff(path, oldText, newText string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldText),
+		B:        splitLines(newText),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  diffContext,
+	})
+	if err != nil {
+		// the diff is written into memory buffer, so it never fails
+		return ""
+	}
+	return diff
+}
+
+// splitLines function splits text into lines, each line ends with newline
+// character even if the text does not
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	last := len(lines) - 1
+	if lines[last] == "" {
+		return lines[:last]
+	}
+	lines[last] += "\n"
+	return lines
+}
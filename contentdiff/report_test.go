@@ -0,0 +1,183 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contentdiff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/contentdiff"
+)
+
+// testReport returns report of differences between two versions of test
+// content
+func testReport() *contentdiff.Report {
+	oldErrorKey := content.RuleErrorKeyContent{Generic: "line 1\nline 2\n"}
+	oldErrorKey.Metadata.Impact.Name = "Data Loss"
+	oldErrorKey.Metadata.Tags = []string{"security"}
+	newErrorKey := content.RuleErrorKeyContent{Generic: "line 1\nline 2 | changed\n"}
+	newErrorKey.Metadata.Impact.Name = "Application Crash"
+	newErrorKey.Metadata.Tags = []string{"security", "networking"}
+
+	oldContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"modified": {
+				Summary: "summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": oldErrorKey,
+					"EK_REMOVED":  {},
+				},
+			},
+			"removed": {ErrorKeys: map[string]content.RuleErrorKeyContent{"EK": {}}},
+			"failing": {ErrorKeys: map[string]content.RuleErrorKeyContent{"EK": {}}},
+		},
+	}
+	newContent := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"modified": {
+				Summary: "summary",
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"EK_MODIFIED": newErrorKey,
+					"EK_ADDED":    {},
+				},
+			},
+			"added": {ErrorKeys: map[string]content.RuleErrorKeyContent{"EK": {}}},
+		},
+	}
+
+	loaded := ctypes.RuleContentStatus{RuleType: "external", Loaded: true}
+	oldStatusMap := map[string]ctypes.RuleContentStatus{
+		"modified": loaded,
+		"removed":  loaded,
+		"failing":  loaded,
+	}
+	newStatusMap := map[string]ctypes.RuleContentStatus{
+		"modified": loaded,
+		"added":    loaded,
+		"failing":  {RuleType: "external", Loaded: false, Error: "missing summary.md"},
+	}
+
+	return contentdiff.New("old", oldContent, oldStatusMap, "new", newContent, newStatusMap)
+}
+
+// TestNewSameContent checks that report of the same content is empty
+func TestNewSameContent(t *testing.T) {
+	contentDir, statusMap, err := content.ParseRuleContentDir("../tests/content/ok")
+	assert.NoError(t, err)
+
+	report := contentdiff.New("old", contentDir, statusMap, "new", contentDir, statusMap)
+	assert.True(t, report.IsEmpty())
+
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, report.Write(buffer, contentdiff.FormatText))
+	assert.Equal(t, "Content diff old -> new\n\nNo changes\n", buffer.String())
+}
+
+// TestNew checks all parts of the report
+func TestNew(t *testing.T) {
+	report := testReport()
+
+	assert.False(t, report.IsEmpty())
+	assert.Empty(t, report.Config)
+	assert.Equal(t, []string{"added"}, report.AddedRules)
+	assert.Equal(t, []string{"removed"}, report.RemovedRules)
+	assert.Equal(t, []string{"added|EK", "modified|EK_ADDED"}, report.AddedErrorKeys)
+	assert.Equal(t, []string{"modified|EK_REMOVED", "removed|EK"}, report.RemovedErrorKeys)
+	assert.Equal(t, []contentdiff.MetadataChange{
+		{
+			Rule:     "modified",
+			ErrorKey: "EK_MODIFIED",
+			Field:    "metadata.impact.name",
+			Old:      "Data Loss",
+			New:      "Application Crash",
+		},
+		{
+			Rule:     "modified",
+			ErrorKey: "EK_MODIFIED",
+			Field:    "metadata.tags",
+			Old:      []string{"security"},
+			New:      []string{"security", "networking"},
+		},
+	}, report.Metadata)
+	assert.Equal(t, []contentdiff.MarkdownChange{
+		{
+			Rule:     "modified",
+			ErrorKey: "EK_MODIFIED",
+			Field:    "generic",
+			Diff: "--- a/modified/EK_MODIFIED/generic.md\n" +
+				"+++ b/modified/EK_MODIFIED/generic.md\n" +
+				"@@ -1,2 +1,2 @@\n" +
+				" line 1\n" +
+				"-line 2\n" +
+				"+line 2 | changed\n",
+		},
+	}, report.Markdown)
+	assert.Equal(t, []contentdiff.ParseRegression{
+		{Rule: "failing", Type: "external", Error: "missing summary.md"},
+	}, report.ParseRegressions)
+}
+
+// TestWriteText checks the plain text rendering
+func TestWriteText(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, testReport().Write(buffer, contentdiff.FormatText))
+
+	output := buffer.String()
+	assert.Contains(t, output, "Content diff old -> new\n")
+	assert.Contains(t, output, "\nAdded rules (1):\n    added\n")
+	assert.Contains(t, output, "\nRemoved error keys (2):\n    modified|EK_REMOVED\n    removed|EK\n")
+	assert.Contains(t, output, `    modified|EK_MODIFIED metadata.impact.name: "Data Loss" -> "Application Crash"`)
+	assert.Contains(t, output, "    -line 2\n    +line 2 | changed\n")
+	assert.Contains(t, output, "\nParse status regressions (1):\n    failing (external): missing summary.md\n")
+	assert.NotContains(t, output, "global configuration")
+}
+
+// TestWriteMarkdown checks the markdown rendering
+func TestWriteMarkdown(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, testReport().Write(buffer, contentdiff.FormatMarkdown))
+
+	output := buffer.String()
+	assert.Contains(t, output, "## Content diff `old` → `new`\n")
+	assert.Contains(t, output, "\n### Added rules (1)\n\n* `added`\n")
+	assert.Contains(t, output, "| Rule | Error key | Field | Old | New |\n| --- | --- | --- | --- | --- |\n")
+	assert.Contains(t, output, `| modified | EK_MODIFIED | metadata.tags | ["security"] | ["security","networking"] |`)
+	assert.Contains(t, output, "```diff\n--- a/modified/EK_MODIFIED/generic.md\n")
+	assert.Contains(t, output, "| failing | external | missing summary.md |\n")
+}
+
+// TestWriteJSON checks the JSON rendering
+func TestWriteJSON(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, testReport().Write(buffer, contentdiff.FormatJSON))
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	assert.Equal(t, []interface{}{"added"}, decoded["added_rules"])
+	assert.Len(t, decoded["metadata"], 2)
+	assert.Len(t, decoded["parse_regressions"], 1)
+}
+
+// TestWriteUnknownFormat checks that unknown format is refused
+func TestWriteUnknownFormat(t *testing.T) {
+	assert.Error(t, testReport().Write(new(bytes.Buffer), "html"))
+}
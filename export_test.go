@@ -27,15 +27,24 @@ package main
 // to see why this trick is needed for using package internal
 // symbols (externally invisible) in unit tests.
 var (
-	PrintVersionInfo = printVersionInfo
-	PrintHelp        = printHelp
-	PrintConfig      = printConfig
-	HandleCommand    = handleCommand
-	StartService     = startService
-	PrintInfo        = printInfo
-	InitInfoLog      = initInfoLog
-	LogVersionInfo   = logVersionInfo
-	PrintGroups      = printGroups
-	PrintRules       = printRules
-	FillInInfoParams = fillInInfoParams
+	PrintVersionInfo  = printVersionInfo
+	PrintHelp         = printHelp
+	PrintConfig       = printConfig
+	HandleCommand     = handleCommand
+	StartService      = startService
+	PrintInfo         = printInfo
+	InitInfoLog       = initInfoLog
+	LogVersionInfo    = logVersionInfo
+	PrintGroups       = printGroups
+	PrintRules        = printRules
+	ExportContent     = exportContent
+	LoadContentBundle = loadContentBundle
+	DiffContent       = diffContent
+	PrintParseStatus  = printParseStatus
+	CommandConfigFile = commandConfigFile
+	ValidateConfig    = validateConfig
+	FillInInfoParams  = fillInInfoParams
+	ReloadGroups      = reloadGroups
+	LogLevel          = logLevel
+	Shutdown          = shutdown
 )
@@ -0,0 +1,42 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownParentError is an error raised when a group refers to a parent group
+// that is not defined in the groups configuration file
+type UnknownParentError struct {
+	Group  string
+	Parent string
+}
+
+// CycleError is an error raised when parent references of groups form a cycle
+type CycleError struct {
+	Groups []string
+}
+
+func (err UnknownParentError) Error() string {
+	return fmt.Sprintf("Group `%s` refers to unknown parent group `%s`", err.Group, err.Parent)
+}
+
+func (err CycleError) Error() string {
+	return fmt.Sprintf("Cycle in group hierarchy: %s", strings.Join(err.Groups, " -> "))
+}
@@ -24,11 +24,14 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// Group represent the relative information about a group
+// Group represent the relative information about a group. Parent contains
+// key of the parent group for nested groups.
 type Group struct {
+	ID          string   `yaml:"-" json:"id,omitempty"`
 	Name        string   `yaml:"name" json:"title"`
 	Description string   `yaml:"description" json:"description"`
 	Tags        []string `yaml:"tags" json:"tags"`
+	Parent      string   `yaml:"parent" json:"parent,omitempty"`
 }
 
 // ParseGroupConfigFile parses the groups configuration file and return the read groups
@@ -48,5 +51,17 @@ func ParseGroupConfigFile(groupConfigPath string) (map[string]Group, error) {
 		return nil, err
 	}
 
+	// keys are used to refer to parent groups
+	for key, group := range groups {
+		group.ID = key
+		groups[key] = group
+	}
+
+	err = checkHierarchy(groups)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid groups hierarchy")
+		return nil, err
+	}
+
 	return groups, nil
 }
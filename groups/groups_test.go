@@ -19,6 +19,8 @@ package groups_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/RedHatInsights/insights-content-service/groups"
 )
 
@@ -52,3 +54,64 @@ func TestParseGroupConfigFileProperYamlFile(t *testing.T) {
 	}
 	// TODO: more checks will need test_config.yaml
 }
+
+// TestParseGroupConfigFileHierarchy checks that nested groups are read properly
+func TestParseGroupConfigFileHierarchy(t *testing.T) {
+	groupsMap, err := groups.ParseGroupConfigFile("../tests/groups/hierarchy.yaml")
+	if err != nil {
+		t.Fatal("Error should not be returned for proper hierarchy", err)
+	}
+
+	assert.Len(t, groupsMap, 4)
+	assert.Equal(t, "certificates", groupsMap["certificates"].ID)
+	assert.Equal(t, "security", groupsMap["certificates"].Parent)
+	assert.Equal(t, "", groupsMap["security"].Parent)
+}
+
+// TestParseGroupConfigFileUnknownParent checks that reference to unknown parent group is detected
+func TestParseGroupConfigFileUnknownParent(t *testing.T) {
+	_, err := groups.ParseGroupConfigFile("../tests/groups/unknown_parent.yaml")
+	assert.Equal(t, &groups.UnknownParentError{Group: "certificates", Parent: "security"}, err)
+}
+
+// TestParseGroupConfigFileCycle checks that cycle in group hierarchy is detected
+func TestParseGroupConfigFileCycle(t *testing.T) {
+	_, err := groups.ParseGroupConfigFile("../tests/groups/cycle.yaml")
+	assert.Equal(t, &groups.CycleError{Groups: []string{"certificates", "security", "rbac", "certificates"}}, err)
+	assert.EqualError(t, err, "Cycle in group hierarchy: certificates -> security -> rbac -> certificates")
+}
+
+// TestEffectiveTags checks that tags of child groups roll up to their parents
+func TestEffectiveTags(t *testing.T) {
+	groupsMap, err := groups.ParseGroupConfigFile("../tests/groups/hierarchy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"security", "certificates", "rbac"}, groups.EffectiveTags(groupsMap, "security"))
+	assert.Equal(t, []string{"rbac", "security"}, groups.EffectiveTags(groupsMap, "rbac"))
+	assert.Equal(t, []string{"performance"}, groups.EffectiveTags(groupsMap, "performance"))
+
+	rolledUp := groups.RollUp(groupsMap)
+	assert.Equal(t, []string{"security", "certificates", "rbac"}, rolledUp["security"].Tags)
+	// original map must not be changed
+	assert.Equal(t, []string{"security"}, groupsMap["security"].Tags)
+}
+
+// TestTree checks construction of tree of groups
+func TestTree(t *testing.T) {
+	groupsMap, err := groups.ParseGroupConfigFile("../tests/groups/hierarchy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := groups.Tree(groupsMap)
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "performance", tree[0].ID)
+	assert.Empty(t, tree[0].Children)
+	assert.Equal(t, "security", tree[1].ID)
+	assert.Len(t, tree[1].Children, 2)
+	assert.Equal(t, "certificates", tree[1].Children[0].ID)
+	assert.Equal(t, "rbac", tree[1].Children[1].ID)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups
+
+import (
+	"sort"
+)
+
+// GroupNode represents one group in the tree of groups together with all its
+// child groups
+type GroupNode struct {
+	Group
+	Children []GroupNode `json:"children"`
+}
+
+// checkHierarchy checks that all parent references point to existing groups
+// and that parent references do not form a cycle
+func checkHierarchy(groupsMap map[string]Group) error {
+	for _, key := range sortedKeys(groupsMap) {
+		parent := groupsMap[key].Parent
+		if parent == "" {
+			continue
+		}
+		if _, found := groupsMap[parent]; !found {
+			return &UnknownParentError{Group: key, Parent: parent}
+		}
+	}
+
+	for _, key := range sortedKeys(groupsMap) {
+		// walk up the hierarchy and remember all visited groups
+		visited := map[string]struct{}{}
+		path := []string{}
+		for current := key; current != ""; current = groupsMap[current].Parent {
+			if _, found := visited[current]; found {
+				return &CycleError{Groups: append(path, current)}
+			}
+			visited[current] = struct{}{}
+			path = append(path, current)
+		}
+	}
+
+	return nil
+}
+
+// childrenMap returns keys of child groups for all groups that have at least
+// one child group
+func childrenMap(groupsMap map[string]Group) map[string][]string {
+	children := make(map[string][]string)
+	for _, key := range sortedKeys(groupsMap) {
+		parent := groupsMap[key].Parent
+		if parent != "" {
+			children[parent] = append(children[parent], key)
+		}
+	}
+	return children
+}
+
+// EffectiveTags returns tags of the selected group together with tags of all
+// its descendants, so a rule in a child group counts under its parent too.
+// Each tag is listed just once.
+func EffectiveTags(groupsMap map[string]Group, key string) []string {
+	children := childrenMap(groupsMap)
+
+	tags := []string{}
+	seenTags := map[string]struct{}{}
+	seenGroups := map[string]struct{}{}
+
+	var collect func(key string)
+	collect = func(key string) {
+		// guard against cycles in configuration that has not been checked
+		if _, found := seenGroups[key]; found {
+			return
+		}
+		seenGroups[key] = struct{}{}
+
+		for _, tag := range groupsMap[key].Tags {
+			if _, found := seenTags[tag]; !found {
+				seenTags[tag] = struct{}{}
+				tags = append(tags, tag)
+			}
+		}
+		for _, child := range children[key] {
+			collect(child)
+		}
+	}
+	collect(key)
+
+	return tags
+}
+
+// RollUp returns copy of groups map where tags of each group are replaced by
+// its effective tags (see EffectiveTags)
+func RollUp(groupsMap map[string]Group) map[string]Group {
+	rolledUp := make(map[string]Group, len(groupsMap))
+	for key, group := range groupsMap {
+		group.Tags = EffectiveTags(groupsMap, key)
+		rolledUp[key] = group
+	}
+	return rolledUp
+}
+
+// Tree returns top level groups together with all their descendants. Groups
+// on each level are sorted by their keys. Tags of groups are not rolled up.
+func Tree(groupsMap map[string]Group) []GroupNode {
+	children := childrenMap(groupsMap)
+
+	var build func(key string) GroupNode
+	build = func(key string) GroupNode {
+		node := GroupNode{
+			Group:    groupsMap[key],
+			Children: make([]GroupNode, 0, len(children[key])),
+		}
+		for _, child := range children[key] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	roots := []GroupNode{}
+	for _, key := range sortedKeys(groupsMap) {
+		if groupsMap[key].Parent == "" {
+			roots = append(roots, build(key))
+		}
+	}
+	return roots
+}
+
+// sortedKeys returns keys of groups map in sorted order
+func sortedKeys(groupsMap map[string]Group) []string {
+	keys := make([]string, 0, len(groupsMap))
+	for key := range groupsMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups
+
+import (
+	"sort"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// Reasons why a tag is considered to be orphaned
+const (
+	// OrphanNotInGroup is used for tags referenced by content that are not
+	// included in any group
+	OrphanNotInGroup = "not_in_group"
+	// OrphanUnused is used for tags declared in a group but not referenced
+	// by any error key
+	OrphanUnused = "unused"
+)
+
+// Tag represents usage information about one tag
+type Tag struct {
+	Name         string   `json:"name"`
+	ErrorKeys    int      `json:"error_keys"`
+	Groups       []string `json:"groups"`
+	Orphaned     bool     `json:"orphaned"`
+	OrphanReason string   `json:"orphan_reason,omitempty"`
+}
+
+// TagsCatalog returns a list of all tags used either in content metadata or
+// in groups configuration, sorted by tag name. For each tag the number of
+// error keys using it and names of groups that include it are computed. Tags
+// of child groups are included in their parent groups too.
+func TagsCatalog(groupsMap map[string]Group, contentDir content.RuleContentDirectory) []Tag {
+	tags := make(map[string]*Tag)
+
+	getTag := func(name string) *Tag {
+		tag, found := tags[name]
+		if !found {
+			tag = &Tag{Name: name, Groups: []string{}}
+			tags[name] = tag
+		}
+		return tag
+	}
+
+	for _, ruleContent := range contentDir.Rules {
+		for _, errorKey := range ruleContent.ErrorKeys {
+			// the same tag might be listed more times in one error key
+			seen := make(map[string]struct{})
+			for _, tagName := range errorKey.Metadata.Tags {
+				if _, found := seen[tagName]; found {
+					continue
+				}
+				seen[tagName] = struct{}{}
+				getTag(tagName).ErrorKeys++
+			}
+		}
+	}
+
+	for _, group := range RollUp(groupsMap) {
+		// the same tag might be listed more times in one group
+		seen := make(map[string]struct{})
+		for _, tagName := range group.Tags {
+			if _, found := seen[tagName]; found {
+				continue
+			}
+			seen[tagName] = struct{}{}
+			tag := getTag(tagName)
+			tag.Groups = append(tag.Groups, group.Name)
+		}
+	}
+
+	catalog := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		switch {
+		case len(tag.Groups) == 0:
+			tag.Orphaned = true
+			tag.OrphanReason = OrphanNotInGroup
+		case tag.ErrorKeys == 0:
+			tag.Orphaned = true
+			tag.OrphanReason = OrphanUnused
+		}
+		sort.Strings(tag.Groups)
+		catalog = append(catalog, *tag)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Name < catalog[j].Name
+	})
+
+	return catalog
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/groups"
+)
+
+// errorKeyWithTags constructs error key content with given tags
+func errorKeyWithTags(tags ...string) content.RuleErrorKeyContent {
+	errorKey := content.RuleErrorKeyContent{}
+	errorKey.Metadata.Tags = tags
+	return errorKey
+}
+
+// TestTagsCatalogEmpty checks the tags catalog for empty input
+func TestTagsCatalogEmpty(t *testing.T) {
+	catalog := groups.TagsCatalog(nil, content.RuleContentDirectory{})
+	assert.Empty(t, catalog)
+}
+
+// TestTagsCatalog checks usage counts, groups and orphan detection
+func TestTagsCatalog(t *testing.T) {
+	groupsMap := map[string]groups.Group{
+		"security": {
+			Name: "Security",
+			Tags: []string{"security", "openshift"},
+		},
+		"performance": {
+			Name: "Performance",
+			Tags: []string{"performance", "openshift", "performance"},
+		},
+	}
+
+	contentDir := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"rule1": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithTags("security", "openshift"),
+					"ek2": errorKeyWithTags("openshift", "openshift", "networking"),
+				},
+			},
+			"rule2": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithTags("security"),
+				},
+			},
+		},
+	}
+
+	catalog := groups.TagsCatalog(groupsMap, contentDir)
+
+	expected := []groups.Tag{
+		{
+			Name:         "networking",
+			ErrorKeys:    1,
+			Groups:       []string{},
+			Orphaned:     true,
+			OrphanReason: groups.OrphanNotInGroup,
+		},
+		{
+			Name:      "openshift",
+			ErrorKeys: 2,
+			Groups:    []string{"Performance", "Security"},
+		},
+		{
+			Name:         "performance",
+			ErrorKeys:    0,
+			Groups:       []string{"Performance"},
+			Orphaned:     true,
+			OrphanReason: groups.OrphanUnused,
+		},
+		{
+			Name:      "security",
+			ErrorKeys: 2,
+			Groups:    []string{"Security"},
+		},
+	}
+
+	assert.Equal(t, expected, catalog)
+}
+
+// TestTagsCatalogHierarchy checks that tags of child groups count under their parents
+func TestTagsCatalogHierarchy(t *testing.T) {
+	groupsMap := map[string]groups.Group{
+		"security": {
+			Name: "Security",
+			Tags: []string{"security"},
+		},
+		"rbac": {
+			Name:   "RBAC",
+			Tags:   []string{"rbac"},
+			Parent: "security",
+		},
+	}
+
+	contentDir := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{
+			"rule1": {
+				ErrorKeys: map[string]content.RuleErrorKeyContent{
+					"ek1": errorKeyWithTags("rbac"),
+				},
+			},
+		},
+	}
+
+	catalog := groups.TagsCatalog(groupsMap, contentDir)
+
+	assert.Len(t, catalog, 2)
+	assert.Equal(t, "rbac", catalog[0].Name)
+	assert.Equal(t, []string{"RBAC", "Security"}, catalog[0].Groups)
+	assert.False(t, catalog[0].Orphaned)
+}
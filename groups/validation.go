@@ -0,0 +1,186 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/rs/zerolog/log"
+)
+
+// Severity represents how serious a finding about groups configuration is
+type Severity string
+
+const (
+	// SeverityFatal is used for findings that make groups configuration
+	// unusable
+	SeverityFatal Severity = "fatal"
+	// SeverityWarning is used for findings that should be fixed, but that
+	// do not prevent groups configuration to be used
+	SeverityWarning Severity = "warning"
+)
+
+// Finding represents one problem found in groups configuration
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Group    string   `json:"group,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Findings is a list of problems found in groups configuration
+type Findings []Finding
+
+// HasFatal returns true if at least one finding is fatal
+func (findings Findings) HasFatal() bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityFatal {
+			return true
+		}
+	}
+	return false
+}
+
+// Warnings returns just findings with warning severity
+func (findings Findings) Warnings() Findings {
+	warnings := Findings{}
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			warnings = append(warnings, finding)
+		}
+	}
+	return warnings
+}
+
+// LogFindings logs all provided findings, fatal findings are logged as
+// errors and the others as warnings
+func LogFindings(findings Findings) {
+	for _, finding := range findings {
+		event := log.Warn()
+		if finding.Severity == SeverityFatal {
+			event = log.Error()
+		}
+		event.Str("group", finding.Group).Msg(finding.Message)
+	}
+}
+
+func (findings *Findings) add(severity Severity, group, format string, args ...interface{}) {
+	*findings = append(*findings, Finding{
+		Severity: severity,
+		Group:    group,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate performs checks of parsed groups configuration and returns all
+// problems found. Groups without name are reported as fatal findings,
+// duplicate names, duplicate or empty tags and groups without tags are
+// reported as warnings.
+func Validate(groupsMap map[string]Group) Findings {
+	findings := Findings{}
+
+	// no two groups should have the same name property
+	uniqueGroups := map[string]string{}
+
+	for _, groupKey := range sortedKeys(groupsMap) {
+		group := groupsMap[groupKey]
+
+		if strings.TrimSpace(group.Name) == "" {
+			findings.add(SeverityFatal, groupKey, "group has empty name")
+		} else if firstGroupKey, exists := uniqueGroups[group.Name]; exists {
+			findings.add(SeverityWarning, groupKey,
+				"multiple groups with the name '%s' (first with key '%s')", group.Name, firstGroupKey)
+		} else {
+			uniqueGroups[group.Name] = groupKey
+		}
+
+		if len(group.Tags) == 0 {
+			findings.add(SeverityWarning, groupKey, "group has no tags")
+		}
+
+		// the same tag being used by multiple groups is allowed
+		uniqueTags := map[string]struct{}{}
+
+		for _, tag := range group.Tags {
+			if strings.TrimSpace(tag) == "" {
+				findings.add(SeverityWarning, groupKey, "empty tag in group")
+				continue
+			}
+			if _, exists := uniqueTags[tag]; exists {
+				findings.add(SeverityWarning, groupKey, "duplicate tag reference '%s' in group", tag)
+			} else {
+				uniqueTags[tag] = struct{}{}
+			}
+		}
+	}
+
+	return findings
+}
+
+// ValidateConfigFile parses the groups configuration file and performs all
+// checks on it. Beside checks done by Validate, duplicate and empty group
+// keys are reported as fatal findings because the YAML parser silently
+// accepts them. Error is returned only if the file can't be read or parsed.
+func ValidateConfigFile(groupConfigPath string) (map[string]Group, Findings, error) {
+	configBytes, err := os.ReadFile(filepath.Clean(groupConfigPath))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings := checkKeys(configBytes)
+
+	groupsMap, err := ParseGroupConfigFile(groupConfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings = append(findings, Validate(groupsMap)...)
+
+	return groupsMap, findings, nil
+}
+
+// checkKeys checks keys of groups in raw groups configuration
+func checkKeys(configBytes []byte) Findings {
+	findings := Findings{}
+
+	var items yaml.MapSlice
+
+	// errors are reported by the proper parser
+	if err := yaml.Unmarshal(configBytes, &items); err != nil {
+		return findings
+	}
+
+	uniqueKeys := map[string]struct{}{}
+
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		if item.Key == nil || strings.TrimSpace(key) == "" {
+			findings.add(SeverityFatal, "", "group with empty key")
+			continue
+		}
+		if _, exists := uniqueKeys[key]; exists {
+			findings.add(SeverityFatal, key, "duplicate group key")
+		} else {
+			uniqueKeys[key] = struct{}{}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groups_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/groups"
+)
+
+// TestValidateProperConfig checks that no findings are reported for proper groups configuration
+func TestValidateProperConfig(t *testing.T) {
+	_, findings, err := groups.ValidateConfigFile("../groups_config.yaml")
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+	assert.False(t, findings.HasFatal())
+}
+
+// TestValidateConfigFileNonExistingFile checks that error is returned for non existing file
+func TestValidateConfigFileNonExistingFile(t *testing.T) {
+	_, _, err := groups.ValidateConfigFile("this does not exist")
+	assert.Error(t, err)
+}
+
+// TestValidateConfigFileDuplicateKeys checks that duplicate group keys are reported as fatal
+func TestValidateConfigFileDuplicateKeys(t *testing.T) {
+	_, findings, err := groups.ValidateConfigFile("../tests/groups/duplicate_keys.yaml")
+	assert.NoError(t, err)
+	assert.True(t, findings.HasFatal())
+	assert.Contains(t, findings, groups.Finding{
+		Severity: groups.SeverityFatal,
+		Group:    "security",
+		Message:  "duplicate group key",
+	})
+}
+
+// TestValidateConfigFileWarnings checks that non-fatal problems are reported as warnings
+func TestValidateConfigFileWarnings(t *testing.T) {
+	_, findings, err := groups.ValidateConfigFile("../tests/groups/warnings.yaml")
+	assert.NoError(t, err)
+	assert.False(t, findings.HasFatal())
+
+	expected := groups.Findings{
+		{
+			Severity: groups.SeverityWarning,
+			Group:    "certificates",
+			Message:  "empty tag in group",
+		},
+		{
+			Severity: groups.SeverityWarning,
+			Group:    "performance",
+			Message:  "group has no tags",
+		},
+		{
+			Severity: groups.SeverityWarning,
+			Group:    "security",
+			Message:  "multiple groups with the name 'Security' (first with key 'certificates')",
+		},
+		{
+			Severity: groups.SeverityWarning,
+			Group:    "security",
+			Message:  "duplicate tag reference 'security' in group",
+		},
+	}
+	assert.Equal(t, expected, findings)
+	assert.Equal(t, expected, findings.Warnings())
+}
+
+// TestValidateEmptyName checks that group without name is reported as fatal
+func TestValidateEmptyName(t *testing.T) {
+	findings := groups.Validate(map[string]groups.Group{
+		"foo": {Name: " ", Tags: []string{"foo"}},
+	})
+	assert.Equal(t, groups.Findings{{
+		Severity: groups.SeverityFatal,
+		Group:    "foo",
+		Message:  "group has empty name",
+	}}, findings)
+	assert.True(t, findings.HasFatal())
+	assert.Empty(t, findings.Warnings())
+}
+
+// TestLogFindings checks that fatal findings are logged as errors and the
+// others as warnings
+func TestLogFindings(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.Logger
+	t.Cleanup(func() { log.Logger = logger })
+	log.Logger = zerolog.New(buf)
+
+	groups.LogFindings(groups.Findings{
+		{Severity: groups.SeverityFatal, Group: "first", Message: "fatal problem"},
+		{Severity: groups.SeverityWarning, Group: "second", Message: "minor problem"},
+	})
+
+	assert.Equal(t,
+		`{"level":"error","group":"first","message":"fatal problem"}`+"\n"+
+			`{"level":"warn","group":"second","message":"minor problem"}`+"\n",
+		buf.String())
+}
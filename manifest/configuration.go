@@ -0,0 +1,30 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+// Configuration represents configuration of content manifest verification
+type Configuration struct {
+	// PublicKeys contains Ed25519 public keys trusted to sign the manifest
+	// in format name:base64-key, the name is optional. Manifest is not
+	// verified when no key is configured.
+	PublicKeys []string `mapstructure:"public_keys" toml:"public_keys"`
+}
+
+// Enabled returns true if at least one public key is configured
+func (configuration Configuration) Enabled() bool {
+	return len(configuration.PublicKeys) > 0
+}
@@ -0,0 +1,304 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifest contains implementation of signed manifest of rule content.
+// The manifest lists SHA-256 digest of every file in content directory and it
+// is signed by Ed25519 key of the rules team. Content is verified against the
+// manifest before it is parsed, so only released content is served.
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FileName is name of manifest file in root of content directory
+	FileName = "manifest.json"
+	// SignatureFileName is name of file with base64 encoded signature of
+	// the manifest file
+	SignatureFileName = "manifest.json.sig"
+
+	// fingerprintLength is number of hex digits of key fingerprint
+	fingerprintLength = 16
+)
+
+// ErrInvalidSignature is returned when manifest is not signed by any of
+// trusted keys
+var ErrInvalidSignature = errors.New("manifest is not signed by any trusted key")
+
+// Manifest lists all files of rule content together with their digests
+type Manifest struct {
+	// Version is version of released content, e.g. ccx-rules-ocp tag
+	Version string `json:"version,omitempty"`
+	// CreatedAt is time the manifest has been generated
+	CreatedAt time.Time `json:"created_at"`
+	// Files maps path of every file relative to content directory to hex
+	// encoded SHA-256 digest of the file
+	Files map[string]string `json:"files"`
+}
+
+// Verified represents manifest that has been verified together with content
+// directory
+type Verified struct {
+	Manifest Manifest `json:"manifest"`
+	// Signer is name of the key the manifest is signed with, fingerprint of
+	// the key is used for keys without name
+	Signer string `json:"signer"`
+	// KeyFingerprint identifies the key the manifest is signed with
+	KeyFingerprint string `json:"key_fingerprint"`
+	// Digest is hex encoded SHA-256 digest of the manifest file
+	Digest string `json:"digest"`
+	// VerifiedAt is time the content has been verified
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// PublicKey is trusted Ed25519 key together with its name
+type PublicKey struct {
+	Name string
+	Key  ed25519.PublicKey
+}
+
+// Fingerprint returns short identifier of the key
+func (key PublicKey) Fingerprint() string {
+	sum := sha256.Sum256(key.Key)
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// signer returns name of the key or its fingerprint when the key has no name
+func (key PublicKey) signer() string {
+	if key.Name != "" {
+		return key.Name
+	}
+	return key.Fingerprint()
+}
+
+// ParsePublicKey function parses key in format name:base64-key, the name is
+// optional
+func ParsePublicKey(value string) (PublicKey, error) {
+	var key PublicKey
+
+	encoded := value
+	if separator := strings.LastIndex(value, ":"); separator >= 0 {
+		key.Name = strings.TrimSpace(value[:separator])
+		encoded = value[separator+1:]
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return key, fmt.Errorf("invalid public key encoding: %v", err)
+	}
+	if len(decoded) != ed25519.PublicKeySize {
+		return key, fmt.Errorf("invalid public key size %d, expected %d", len(decoded), ed25519.PublicKeySize)
+	}
+
+	key.Key = ed25519.PublicKey(decoded)
+	return key, nil
+}
+
+// ParsePublicKeys function parses all configured keys
+func ParsePublicKeys(values []string) ([]PublicKey, error) {
+	keys := make([]PublicKey, 0, len(values))
+	for _, value := range values {
+		key, err := ParsePublicKey(value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Generate function computes manifest of all files in content directory
+func Generate(dir, version string) (Manifest, error) {
+	files, err := digests(dir)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return Manifest{
+		Version:   version,
+		CreatedAt: time.Now().UTC(),
+		Files:     files,
+	}, nil
+}
+
+// Write function stores the manifest and its signature into content
+// directory
+func Write(dir string, manifest Manifest, privateKey ed25519.PrivateKey) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, data))
+
+	if err := os.WriteFile(filepath.Join(dir, FileName), data, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, SignatureFileName), []byte(signature+"\n"), 0o600)
+}
+
+// Verify function checks that manifest in content directory is signed by one
+// of trusted keys and that the directory contains exactly the files listed
+// in the manifest with the same digests
+func Verify(dir string, keys []PublicKey) (*Verified, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest: %w", err)
+	}
+
+	encodedSignature, err := os.ReadFile(filepath.Join(dir, SignatureFileName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest signature: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encodedSignature)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest signature encoding: %v", err)
+	}
+
+	signer, err := findSigner(data, signature, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %v", err)
+	}
+
+	files, err := digests(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := compare(manifest.Files, files); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	return &Verified{
+		Manifest:       manifest,
+		Signer:         signer.signer(),
+		KeyFingerprint: signer.Fingerprint(),
+		Digest:         hex.EncodeToString(sum[:]),
+		VerifiedAt:     time.Now().UTC(),
+	}, nil
+}
+
+// findSigner returns the key the manifest is signed with
+func findSigner(data, signature []byte, keys []PublicKey) (PublicKey, error) {
+	if len(signature) == ed25519.SignatureSize {
+		for _, key := range keys {
+			if ed25519.Verify(key.Key, data, signature) {
+				return key, nil
+			}
+		}
+	}
+	return PublicKey{}, ErrInvalidSignature
+}
+
+// compare function checks digests of files found in content directory
+// against the manifest and reports all differences
+func compare(expected, found map[string]string) error {
+	var problems []string
+
+	for name, digest := range found {
+		expectedDigest, listed := expected[name]
+		switch {
+		case !listed:
+			problems = append(problems, fmt.Sprintf("file %s is not listed in manifest", name))
+		case !strings.EqualFold(expectedDigest, digest):
+			problems = append(problems, fmt.Sprintf("file %s has been modified", name))
+		}
+	}
+	for name := range expected {
+		if _, exists := found[name]; !exists {
+			problems = append(problems, fmt.Sprintf("file %s is missing", name))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("content does not match manifest: %s", strings.Join(problems, "; "))
+}
+
+// digests function computes SHA-256 digest of all files in content
+// directory except the manifest itself. Only regular files are allowed.
+func digests(dir string) (map[string]string, error) {
+	files := map[string]string{}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		switch {
+		case entry.IsDir():
+			return nil
+		case name == FileName || name == SignatureFileName:
+			return nil
+		case !entry.Type().IsRegular():
+			return fmt.Errorf("file %s is not a regular file", name)
+		}
+
+		digest, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		files[name] = digest
+		return nil
+	})
+
+	return files, err
+}
+
+// fileDigest returns hex encoded SHA-256 digest of file content
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/manifest"
+)
+
+// copyContent copies test content into temporary directory
+func copyContent(t *testing.T) string {
+	dir := t.TempDir()
+	err := filepath.WalkDir("../tests/content/ok", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel("../tests/content/ok", path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, name), 0o750)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, name), data, 0o600)
+	})
+	assert.NoError(t, err)
+	return dir
+}
+
+// signedContent returns content directory signed by new key together with
+// the key
+func signedContent(t *testing.T) (string, manifest.PublicKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	dir := copyContent(t)
+	contentManifest, err := manifest.Generate(dir, "1.0.0")
+	assert.NoError(t, err)
+	assert.NoError(t, manifest.Write(dir, contentManifest, privateKey))
+
+	return dir, manifest.PublicKey{Name: "rules-team", Key: publicKey}
+}
+
+// TestVerify checks that signed content is verified
+func TestVerify(t *testing.T) {
+	dir, key := signedContent(t)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	verified, err := manifest.Verify(dir, []manifest.PublicKey{{Key: otherKey}, key})
+	assert.NoError(t, err)
+	assert.Equal(t, "rules-team", verified.Signer)
+	assert.Equal(t, key.Fingerprint(), verified.KeyFingerprint)
+	assert.Equal(t, "1.0.0", verified.Manifest.Version)
+	assert.Contains(t, verified.Manifest.Files, "config.yaml")
+	assert.Contains(t, verified.Manifest.Files, "external/rules/rule1/summary.md")
+	assert.NotContains(t, verified.Manifest.Files, manifest.FileName)
+	assert.Len(t, verified.Digest, 64)
+}
+
+// TestVerifyUnknownKey checks that manifest signed by untrusted key is
+// refused
+func TestVerifyUnknownKey(t *testing.T) {
+	dir, _ := signedContent(t)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	_, err = manifest.Verify(dir, []manifest.PublicKey{{Key: otherKey}})
+	assert.ErrorIs(t, err, manifest.ErrInvalidSignature)
+}
+
+// TestVerifyModifiedManifest checks that modified manifest is refused
+func TestVerifyModifiedManifest(t *testing.T) {
+	dir, key := signedContent(t)
+
+	path := filepath.Join(dir, manifest.FileName)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, append(data, ' '), 0o600))
+
+	_, err = manifest.Verify(dir, []manifest.PublicKey{key})
+	assert.ErrorIs(t, err, manifest.ErrInvalidSignature)
+}
+
+// TestVerifyChangedFiles checks that modified, unlisted and missing files
+// are reported
+func TestVerifyChangedFiles(t *testing.T) {
+	dir, key := signedContent(t)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "external/rules/rule1/summary.md"), []byte("changed"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "external/rules/rule1/extra.md"), []byte("extra"), 0o600))
+	assert.NoError(t, os.Remove(filepath.Join(dir, "external/rules/rule1/reason.md")))
+
+	_, err := manifest.Verify(dir, []manifest.PublicKey{key})
+	assert.EqualError(t, err, "content does not match manifest: "+
+		"file external/rules/rule1/extra.md is not listed in manifest; "+
+		"file external/rules/rule1/reason.md is missing; "+
+		"file external/rules/rule1/summary.md has been modified")
+}
+
+// TestVerifyMissingManifest checks that content without manifest is refused
+func TestVerifyMissingManifest(t *testing.T) {
+	_, key := signedContent(t)
+
+	_, err := manifest.Verify(copyContent(t), []manifest.PublicKey{key})
+	assert.Error(t, err)
+}
+
+// TestParsePublicKey checks parsing of configured keys
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	encoded := base64.StdEncoding.EncodeToString(publicKey)
+
+	key, err := manifest.ParsePublicKey("rules-team:" + encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "rules-team", key.Name)
+	assert.Equal(t, publicKey, key.Key)
+
+	key, err = manifest.ParsePublicKey(encoded)
+	assert.NoError(t, err)
+	assert.Empty(t, key.Name)
+
+	_, err = manifest.ParsePublicKey("rules-team:not-base64")
+	assert.Error(t, err)
+
+	_, err = manifest.ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+
+	keys, err := manifest.ParsePublicKeys([]string{encoded, "other:" + encoded})
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/ghodss/yaml"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// ruleRow contains information about one rule shown in table and tree
+// outputs
+type ruleRow struct {
+	name      string
+	ruleType  string
+	loaded    bool
+	errorKeys []string
+	err       string
+}
+
+// printRuleOutput function prints value in selected output format. Table
+// and tree outputs are constructed from parse status and parsed content of
+// selected rules instead of the value.
+func printRuleOutput(value interface{}, contentDir content.RuleContentDirectory,
+	parseStatus map[string]ctypes.RuleContentStatus, format string) error {
+	switch format {
+	case outputFormatTable:
+		return writeRulesTable(os.Stdout, ruleRows(contentDir, parseStatus))
+	case outputFormatTree:
+		return writeRulesTree(os.Stdout, ruleRows(contentDir, parseStatus))
+	default:
+		return printOutput(value, format)
+	}
+}
+
+// printOutput function prints value in selected output format. JSON output
+// is indented and all object keys are sorted, so outputs can be compared.
+func printOutput(value interface{}, format string) error {
+	switch format {
+	case outputFormatJSON:
+		encoded, err := sortedJSON(value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	case outputFormatYAML:
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(encoded))
+	default:
+		return fmt.Errorf("unknown output format '%s'", format)
+	}
+	return nil
+}
+
+// sortedJSON function encodes value as indented JSON with keys of all
+// objects sorted. Keys of maps are sorted by encoding/json already, but
+// structure fields are encoded in order of their declaration.
+func sortedJSON(value interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(generic, "", "    ")
+}
+
+// ruleRows function returns information about all rules in parse status
+// sorted by rule type and name
+func ruleRows(contentDir content.RuleContentDirectory, parseStatus map[string]ctypes.RuleContentStatus) []ruleRow {
+	rows := make([]ruleRow, 0, len(parseStatus))
+	for name, status := range parseStatus {
+		errorKeys := []string{}
+		for errorKey := range contentDir.Rules[name].ErrorKeys {
+			errorKeys = append(errorKeys, errorKey)
+		}
+		sort.Strings(errorKeys)
+
+		rows = append(rows, ruleRow{
+			name:      name,
+			ruleType:  string(status.RuleType),
+			loaded:    status.Loaded,
+			errorKeys: errorKeys,
+			err:       string(status.Error),
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ruleType != rows[j].ruleType {
+			return rows[i].ruleType < rows[j].ruleType
+		}
+		return rows[i].name < rows[j].name
+	})
+	return rows
+}
+
+// writeRulesTable function writes one line for each rule followed by
+// summary
+func writeRulesTable(out io.Writer, rows []ruleRow) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "RULE\tTYPE\tLOADED\tERROR KEYS\tERROR")
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%s\n",
+			row.name, row.ruleType, yesNo(row.loaded), len(row.errorKeys), row.err)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprint(out, rulesSummary(rows))
+	return err
+}
+
+// writeRulesTree function writes rules grouped by their type together with
+// their error keys followed by summary
+func writeRulesTree(out io.Writer, rows []ruleRow) error {
+	for i, row := range rows {
+		if i == 0 || rows[i-1].ruleType != row.ruleType {
+			fmt.Fprintln(out, row.ruleType)
+		}
+		lastInType := i == len(rows)-1 || rows[i+1].ruleType != row.ruleType
+
+		branch, indent := "├── ", "│   "
+		if lastInType {
+			branch, indent = "└── ", "    "
+		}
+
+		if row.loaded {
+			fmt.Fprintf(out, "%s%s (%d error keys)\n", branch, row.name, len(row.errorKeys))
+		} else {
+			fmt.Fprintf(out, "%s%s (failed: %s)\n", branch, row.name, row.err)
+		}
+
+		for j, errorKey := range row.errorKeys {
+			if j == len(row.errorKeys)-1 {
+				fmt.Fprintf(out, "%s└── %s\n", indent, errorKey)
+			} else {
+				fmt.Fprintf(out, "%s├── %s\n", indent, errorKey)
+			}
+		}
+	}
+
+	_, err := fmt.Fprint(out, rulesSummary(rows))
+	return err
+}
+
+// rulesSummary function returns footer of table and tree outputs
+func rulesSummary(rows []ruleRow) string {
+	loaded := 0
+	for _, row := range rows {
+		if row.loaded {
+			loaded++
+		}
+	}
+	return fmt.Sprintf("\n%d rules, %d loaded, %d failed\n", len(rows), loaded, len(rows)-loaded)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import "time"
+
+// Configuration represents configuration of Kafka producer
+type Configuration struct {
+	// Enabled turns publishing of content change messages on
+	Enabled bool `mapstructure:"enabled" toml:"enabled"`
+	// Address is address of Kafka broker in host:port form
+	Address string `mapstructure:"address" toml:"address"`
+	// Topic is name of topic the messages are published to
+	Topic string `mapstructure:"topic" toml:"topic"`
+	// PublishOnStart turns publishing of the current snapshot on when the
+	// service starts
+	PublishOnStart bool `mapstructure:"publish_on_start" toml:"publish_on_start"`
+	// Timeout is timeout of one attempt to publish a message
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+	// Retries is number of retries performed by Kafka client before the
+	// attempt is considered failed
+	Retries int `mapstructure:"retries" toml:"retries"`
+	// RetryBackoff is time to wait before failed attempt is repeated, it is
+	// doubled for each subsequent attempt
+	RetryBackoff time.Duration `mapstructure:"retry_backoff" toml:"retry_backoff"`
+	// MaxRetryBackoff limits time to wait between attempts
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff" toml:"max_retry_backoff"`
+}
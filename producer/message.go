@@ -0,0 +1,80 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+const (
+	// MessageVersion is version of message schema. It is increased
+	// whenever the schema changes in incompatible way.
+	MessageVersion = 1
+	// MessageType is type of message announcing installed content snapshot
+	MessageType = "content_snapshot"
+)
+
+// SnapshotInfo identifies content snapshot
+type SnapshotInfo struct {
+	ID           string    `json:"id"`
+	Hash         string    `json:"hash"`
+	RulesVersion string    `json:"rules_version"`
+	LoadedAt     time.Time `json:"loaded_at"`
+}
+
+// Message is published for each installed content snapshot. The schema is
+// documented in docs/kafka.md.
+type Message struct {
+	Version   int       `json:"version"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// Snapshot is the installed snapshot
+	Snapshot SnapshotInfo `json:"snapshot"`
+	// Previous is snapshot the changes are relative to, it is nil when the
+	// previous snapshot is not known
+	Previous *SnapshotInfo `json:"previous"`
+	// Full is set when the previous snapshot is not known, all error keys
+	// are listed as added in such case
+	Full bool `json:"full"`
+	// ConfigChanged is set when the global content configuration changed
+	ConfigChanged bool `json:"config_changed"`
+	// Changes contains rule|error_key IDs of added, removed and modified
+	// error keys
+	Changes content.ErrorKeyIDs `json:"changes"`
+}
+
+// NewMessage constructs message announcing installed snapshot
+func NewMessage(previous *SnapshotInfo, current SnapshotInfo,
+	previousContent, currentContent content.RuleContentDirectory) Message {
+	if previous == nil {
+		previousContent = content.RuleContentDirectory{}
+	}
+
+	return Message{
+		Version:       MessageVersion,
+		Type:          MessageType,
+		Timestamp:     time.Now().UTC(),
+		Snapshot:      current,
+		Previous:      previous,
+		Full:          previous == nil,
+		ConfigChanged: previous != nil && !reflect.DeepEqual(previousContent.Config, currentContent.Config),
+		Changes:       content.ChangedErrorKeyIDs(previousContent, currentContent),
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package producer contains implementation of Kafka producer that publishes
+// messages announcing installed content snapshots. Messages are published
+// in the order of installation with at-least-once delivery guarantee while
+// the service is running: failed attempts are repeated until the broker
+// acknowledges the message by all in-sync replicas.
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// Default values used when they are not configured
+const (
+	DefaultTimeout         = 10 * time.Second
+	DefaultRetryBackoff    = 1 * time.Second
+	DefaultMaxRetryBackoff = 1 * time.Minute
+)
+
+const (
+	// queueSize is number of messages waiting for publishing, messages
+	// are dropped when the queue is full
+	queueSize = 100
+
+	// messageKey is used for all messages, so they are stored in the same
+	// partition and consumers receive them in order
+	messageKey = "content"
+)
+
+// ErrNotDelivered is returned by Close when some messages have not been
+// delivered before the context expired
+var ErrNotDelivered = errors.New("some messages have not been delivered")
+
+// snapshotChange is installed snapshot waiting for publishing
+type snapshotChange struct {
+	previous        *SnapshotInfo
+	current         SnapshotInfo
+	previousContent content.RuleContentDirectory
+	currentContent  content.RuleContentDirectory
+}
+
+// Publisher publishes messages announcing installed content snapshots
+type Publisher struct {
+	config   Configuration
+	producer sarama.SyncProducer
+	queue    chan snapshotChange
+
+	mutex  sync.Mutex
+	closed bool
+
+	// stop cancels retries of message that can't be delivered
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New constructs publisher connected to configured Kafka broker
+func New(config Configuration) (*Publisher, error) {
+	producer, err := sarama.NewSyncProducer([]string{config.Address}, saramaConfig(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return newWithProducer(config, producer), nil
+}
+
+// newWithProducer constructs publisher that uses provided producer
+func newWithProducer(config Configuration, producer sarama.SyncProducer) *Publisher {
+	publisher := &Publisher{
+		config:   config,
+		producer: producer,
+		queue:    make(chan snapshotChange, queueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go publisher.run()
+
+	return publisher
+}
+
+// saramaConfig returns Kafka client configuration that waits for message
+// acknowledgement by all in-sync replicas
+func saramaConfig(config Configuration) *sarama.Config {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Timeout = durationOrDefault(config.Timeout, DefaultTimeout)
+	saramaConfig.Producer.Retry.Max = config.Retries
+	saramaConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	return saramaConfig
+}
+
+// Publish enqueues message announcing installed snapshot. The message is
+// published asynchronously, so this method never blocks.
+func (publisher *Publisher) Publish(previous *SnapshotInfo, current SnapshotInfo,
+	previousContent, currentContent content.RuleContentDirectory) {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	if publisher.closed {
+		log.Error().Str("snapshot", current.ID).Msg("Kafka publisher is closed, message dropped")
+		return
+	}
+
+	change := snapshotChange{
+		previous:        previous,
+		current:         current,
+		previousContent: previousContent,
+		currentContent:  currentContent,
+	}
+
+	select {
+	case publisher.queue <- change:
+	default:
+		log.Error().Str("snapshot", current.ID).Msg("Kafka publisher queue is full, message dropped")
+	}
+}
+
+// Close waits until all enqueued messages are delivered or until the
+// context expires and then closes the connection to Kafka broker
+func (publisher *Publisher) Close(ctx context.Context) error {
+	publisher.mutex.Lock()
+	if !publisher.closed {
+		publisher.closed = true
+		close(publisher.queue)
+	}
+	publisher.mutex.Unlock()
+
+	var result error
+	select {
+	case <-publisher.done:
+	case <-ctx.Done():
+		close(publisher.stop)
+		<-publisher.done
+		result = ErrNotDelivered
+	}
+
+	if err := publisher.producer.Close(); err != nil {
+		return err
+	}
+	return result
+}
+
+// run publishes enqueued messages one by one, so their order is kept
+func (publisher *Publisher) run() {
+	defer close(publisher.done)
+
+	for change := range publisher.queue {
+		message := NewMessage(change.previous, change.current, change.previousContent, change.currentContent)
+		if !publisher.send(message) {
+			return
+		}
+	}
+}
+
+// send publishes message and repeats failed attempts with exponential
+// backoff. False is returned when publishing has been stopped.
+func (publisher *Publisher) send(message Message) bool {
+	value, err := json.Marshal(message)
+	if err != nil {
+		// should not happen, the message is not published at all
+		log.Error().Err(err).Msg("Unable to encode Kafka message")
+		return true
+	}
+
+	backoff := durationOrDefault(publisher.config.RetryBackoff, DefaultRetryBackoff)
+	maxBackoff := durationOrDefault(publisher.config.MaxRetryBackoff, DefaultMaxRetryBackoff)
+
+	for {
+		partition, offset, err := publisher.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: publisher.config.Topic,
+			Key:   sarama.StringEncoder(messageKey),
+			Value: sarama.ByteEncoder(value),
+		})
+		if err == nil {
+			log.Info().
+				Str("snapshot", message.Snapshot.ID).
+				Int32("partition", partition).
+				Int64("offset", offset).
+				Msg("Content snapshot message published")
+			return true
+		}
+
+		log.Error().Err(err).
+			Str("snapshot", message.Snapshot.ID).
+			Dur("backoff", backoff).
+			Msg("Unable to publish content snapshot message, retrying")
+
+		select {
+		case <-publisher.stop:
+			log.Error().Str("snapshot", message.Snapshot.ID).Msg("Content snapshot message has not been delivered")
+			return false
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// durationOrDefault returns the provided duration or the default one when
+// the duration is not set
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+	return duration
+}
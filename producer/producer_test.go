@@ -0,0 +1,245 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/producer"
+)
+
+const topic = "platform.content-service.changes"
+
+var (
+	firstSnapshot  = producer.SnapshotInfo{ID: "aaaaaaaaaaaa", Hash: "aaaaaaaaaaaa00", RulesVersion: "1.0"}
+	secondSnapshot = producer.SnapshotInfo{ID: "bbbbbbbbbbbb", Hash: "bbbbbbbbbbbb00", RulesVersion: "1.1"}
+)
+
+// testConfig returns configuration with short backoff
+func testConfig(address string) producer.Configuration {
+	return producer.Configuration{
+		Enabled:         true,
+		Address:         address,
+		Topic:           topic,
+		Timeout:         time.Second,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+	}
+}
+
+// rulesContent constructs content containing given rules with one error key
+func rulesContent(names ...string) content.RuleContentDirectory {
+	contentDir := content.RuleContentDirectory{Rules: map[string]content.RuleContent{}}
+	for _, name := range names {
+		contentDir.Rules[name] = content.RuleContent{
+			ErrorKeys: map[string]content.RuleErrorKeyContent{"EK": {}},
+		}
+	}
+	return contentDir
+}
+
+// closePublisher waits for all messages to be published
+func closePublisher(t *testing.T, publisher *producer.Publisher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, publisher.Close(ctx))
+}
+
+// messageChecker returns function checking that published message is equal
+// to the expected one, timestamp is not compared
+func messageChecker(t *testing.T, expected producer.Message) mocks.ValueChecker {
+	return func(value []byte) error {
+		var message producer.Message
+		if err := json.Unmarshal(value, &message); err != nil {
+			return err
+		}
+		assert.WithinDuration(t, time.Now(), message.Timestamp, time.Minute)
+		message.Timestamp = expected.Timestamp
+		assert.Equal(t, expected, message)
+		return nil
+	}
+}
+
+// TestNewMessageFull checks message for unknown previous snapshot
+func TestNewMessageFull(t *testing.T) {
+	message := producer.NewMessage(nil, firstSnapshot, rulesContent("ignored"), rulesContent("rule1", "rule2"))
+
+	assert.Equal(t, producer.MessageVersion, message.Version)
+	assert.Equal(t, producer.MessageType, message.Type)
+	assert.Equal(t, firstSnapshot, message.Snapshot)
+	assert.Nil(t, message.Previous)
+	assert.True(t, message.Full)
+	assert.False(t, message.ConfigChanged)
+	assert.Equal(t, content.ErrorKeyIDs{
+		Added:    []string{"rule1|EK", "rule2|EK"},
+		Removed:  []string{},
+		Modified: []string{},
+	}, message.Changes)
+}
+
+// TestNewMessageChanges checks message listing changed error keys
+func TestNewMessageChanges(t *testing.T) {
+	previousContent := rulesContent("rule1", "rule2")
+	currentContent := rulesContent("rule2", "rule3")
+	currentContent.Config.Impact = map[string]int{"Data Loss": 4}
+
+	message := producer.NewMessage(&firstSnapshot, secondSnapshot, previousContent, currentContent)
+
+	assert.Equal(t, &firstSnapshot, message.Previous)
+	assert.False(t, message.Full)
+	assert.True(t, message.ConfigChanged)
+	assert.Equal(t, content.ErrorKeyIDs{
+		Added:    []string{"rule3|EK"},
+		Removed:  []string{"rule1|EK"},
+		Modified: []string{},
+	}, message.Changes)
+}
+
+// TestPublish checks that messages are published in order
+func TestPublish(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(messageChecker(t,
+		producer.NewMessage(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))))
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(messageChecker(t,
+		producer.NewMessage(&firstSnapshot, secondSnapshot, rulesContent("rule1"), rulesContent("rule2"))))
+
+	publisher := producer.NewWithProducer(testConfig(""), mockProducer)
+	publisher.Publish(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+	publisher.Publish(&firstSnapshot, secondSnapshot, rulesContent("rule1"), rulesContent("rule2"))
+
+	closePublisher(t, publisher)
+}
+
+// TestPublishRetried checks that failed attempts are repeated
+func TestPublishRetried(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(messageChecker(t,
+		producer.NewMessage(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))))
+
+	publisher := producer.NewWithProducer(testConfig(""), mockProducer)
+	publisher.Publish(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+
+	closePublisher(t, publisher)
+}
+
+// TestCloseNotDelivered checks that Close reports undelivered messages
+func TestCloseNotDelivered(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+
+	config := testConfig("")
+	config.RetryBackoff = time.Minute
+	publisher := producer.NewWithProducer(config, mockProducer)
+	publisher.Publish(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, producer.ErrNotDelivered, publisher.Close(ctx))
+
+	// messages are not accepted after close
+	publisher.Publish(&firstSnapshot, secondSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+}
+
+// mockBroker starts in-process Kafka broker responding to produce requests
+// with given error
+func mockBroker(t *testing.T, produceError sarama.KError) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	setProduceError(t, broker, produceError)
+	return broker
+}
+
+func setProduceError(t *testing.T, broker *sarama.MockBroker, produceError sarama.KError) {
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(topic, 0, broker.BrokerID()),
+		// produce request version used by default Kafka client version
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetVersion(3).
+			SetError(topic, 0, produceError),
+	})
+}
+
+// produceRequests returns number of produce requests received by broker
+func produceRequests(broker *sarama.MockBroker) int {
+	count := 0
+	for _, history := range broker.History() {
+		if _, ok := history.Request.(*sarama.ProduceRequest); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// TestPublishToBroker checks that message is published to Kafka broker and
+// that all in-sync replicas are required to acknowledge it
+func TestPublishToBroker(t *testing.T) {
+	broker := mockBroker(t, sarama.ErrNoError)
+
+	publisher, err := producer.New(testConfig(broker.Addr()))
+	assert.NoError(t, err)
+
+	publisher.Publish(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+	closePublisher(t, publisher)
+
+	assert.Equal(t, 1, produceRequests(broker))
+	for _, history := range broker.History() {
+		if request, ok := history.Request.(*sarama.ProduceRequest); ok {
+			assert.Equal(t, sarama.WaitForAll, request.RequiredAcks)
+		}
+	}
+}
+
+// TestPublishToBrokerRetried checks that message is published after broker
+// recovers from error
+func TestPublishToBrokerRetried(t *testing.T) {
+	broker := mockBroker(t, sarama.ErrNotEnoughReplicas)
+
+	publisher, err := producer.New(testConfig(broker.Addr()))
+	assert.NoError(t, err)
+
+	publisher.Publish(nil, firstSnapshot, content.RuleContentDirectory{}, rulesContent("rule1"))
+
+	assert.Eventually(t, func() bool {
+		return produceRequests(broker) >= 2
+	}, 5*time.Second, time.Millisecond)
+
+	setProduceError(t, broker, sarama.ErrNoError)
+	closePublisher(t, publisher)
+}
+
+// TestNewUnreachableBroker checks that unreachable broker is reported
+func TestNewUnreachableBroker(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	address := broker.Addr()
+	broker.Close()
+
+	_, err := producer.New(testConfig(address))
+	assert.True(t, errors.Is(err, sarama.ErrOutOfBrokers), err)
+}
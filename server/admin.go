@@ -0,0 +1,203 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+	"github.com/RedHatInsights/insights-content-service/webhooks"
+)
+
+const bearerPrefix = "Bearer "
+
+// ContentLoader is a function that reads and parses rule content, it is used
+// by the admin endpoint to reload content while the server is running
+type ContentLoader func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error)
+
+// adminAuth wraps handler of admin endpoint and lets the request pass only
+// if it contains the configured admin token. Admin endpoints are disabled
+// when no token is configured.
+func (server *HTTPServer) adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if server.Config.AdminToken == "" {
+			logResponseError(responses.SendForbidden(writer, "Admin endpoints are disabled"))
+			return
+		}
+
+		authorization := request.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, bearerPrefix)
+		if !strings.HasPrefix(authorization, bearerPrefix) ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(server.Config.AdminToken)) != 1 {
+			log.Warn().Str("endpoint", request.URL.Path).Msg("Unauthorized access to admin endpoint")
+			logResponseError(responses.SendUnauthorized(writer, "Missing or invalid admin token"))
+			return
+		}
+
+		handler(writer, request)
+	}
+}
+
+// RefusedContentError is returned by ReloadContent when new content would
+// drop more than configured share of currently loaded rules
+type RefusedContentError struct {
+	Reason string
+	Diff   content.Diff
+}
+
+func (err *RefusedContentError) Error() string {
+	return err.Reason
+}
+
+// ReloadContent method parses rule content again using ContentLoader and
+// replaces the content served by the server. The new content is refused
+// when it would drop more than configured share of currently loaded rules,
+// RefusedContentError is returned in such case.
+func (server *HTTPServer) ReloadContent() (content.Diff, error) {
+	if server.ContentLoader == nil {
+		return content.Diff{}, errors.New("content reload is not available")
+	}
+
+	// only one reload can run at a time
+	server.reloadMutex.Lock()
+	defer server.reloadMutex.Unlock()
+
+	// version is read before the content, so a content updated in the
+	// meantime is recorded with older version until the next reload rather
+	// than older content with newer version
+	server.mutex.RLock()
+	rulesVersion := server.RulesVersion
+	server.mutex.RUnlock()
+	if server.RulesVersionLoader != nil {
+		rulesVersion = server.RulesVersionLoader()
+	}
+
+	parseStart := time.Now()
+	contentDir, ruleContentStatusMap, err := server.ContentLoader()
+	parseDuration := time.Since(parseStart)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to reload rule content")
+		server.publishReloadFailure(content.Diff{}, err.Error())
+		return content.Diff{}, err
+	}
+
+	// disabled rules are not served, so they are not compared either
+	server.mutex.RLock()
+	servedContent, servedStatusMap := server.withoutDisabledRules(contentDir, ruleContentStatusMap)
+	diff := content.Compare(server.Content, server.ruleContentStatusMap, servedContent, servedStatusMap)
+	loadedRules := len(server.Content.Rules)
+	server.mutex.RUnlock()
+
+	logger := log.Info().
+		Int("added", len(diff.Added)).
+		Int("removed", len(diff.Removed)).
+		Int("changed", len(diff.Changed)).
+		Int("newly failing", len(diff.NewlyFailing))
+
+	maxRatio := server.Config.MaxDroppedRulesRatio
+	if maxRatio > 0 && loadedRules > 0 {
+		ratio := float64(diff.Dropped) / float64(loadedRules)
+		if ratio > maxRatio {
+			logger.Msg("New rule content refused")
+			reason := fmt.Sprintf("%d of %d loaded rules would be dropped, ratio %.2f exceeds threshold %.2f",
+				diff.Dropped, loadedRules, ratio, maxRatio)
+			server.publishReloadFailure(diff, reason)
+			return diff, &RefusedContentError{Reason: reason, Diff: diff}
+		}
+	}
+
+	// manifest is replaced together with the content it belongs to
+	var verified *manifest.Verified
+	if server.ManifestLoader != nil {
+		verified = server.ManifestLoader()
+	}
+
+	server.mutex.Lock()
+	server.setContent(contentDir, ruleContentStatusMap, rulesVersion)
+	if server.ManifestLoader != nil {
+		server.manifest = verified
+	}
+	server.mutex.Unlock()
+
+	if server.ContentInstalled != nil {
+		server.ContentInstalled()
+	}
+
+	content.Reloads.Inc()
+	content.UpdateMetrics(contentDir, ruleContentStatusMap, parseDuration, time.Now())
+	logger.Msg("Rule content reloaded")
+
+	return diff, nil
+}
+
+// reloadContent handler parses rule content again and replaces the content
+// served by the server
+func (server *HTTPServer) reloadContent(writer http.ResponseWriter, _ *http.Request) {
+	if server.ContentLoader == nil {
+		logResponseError(responses.SendServiceUnavailable(writer, "Content reload is not available"))
+		return
+	}
+
+	diff, err := server.ReloadContent()
+
+	var refused *RefusedContentError
+	switch {
+	case errors.As(err, &refused):
+		logResponseError(responses.Send(http.StatusConflict, writer, map[string]interface{}{
+			"status":    refused.Reason,
+			"installed": false,
+			"diff":      refused.Diff,
+		}))
+	case err != nil:
+		logResponseError(responses.SendInternalServerError(writer, err.Error()))
+	default:
+		response := responses.BuildOkResponseWithData("diff", diff)
+		response["installed"] = true
+		logResponseError(responses.SendOK(writer, response))
+	}
+}
+
+// webhookDeliveries handler returns recent webhook deliveries, the newest
+// one first
+func (server *HTTPServer) webhookDeliveries(writer http.ResponseWriter, _ *http.Request) {
+	deliveries := []webhooks.Delivery{}
+	if server.Webhooks != nil {
+		deliveries = server.Webhooks.Deliveries()
+	}
+
+	response := responses.BuildOkResponseWithData("deliveries", deliveries)
+	response["enabled"] = server.Webhooks != nil
+	logResponseError(responses.SendOK(writer, response))
+}
+
+// logResponseError logs error that happened during sending response
+func logResponseError(err error) {
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+		handleServerError(err)
+	}
+}
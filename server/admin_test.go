@@ -0,0 +1,311 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+	"github.com/RedHatInsights/insights-content-service/webhooks"
+)
+
+const adminToken = "secret-token"
+
+// adminConfig returns server configuration with admin endpoints enabled
+func adminConfig() server.Configuration {
+	cfg := config
+	cfg.AdminToken = adminToken
+	return cfg
+}
+
+// rulesContent constructs content and status map with given loaded rules
+func rulesContent(names ...string) (content.RuleContentDirectory, map[string]types.RuleContentStatus) {
+	contentDir := content.RuleContentDirectory{
+		Rules: map[string]content.RuleContent{},
+	}
+	statusMap := map[string]types.RuleContentStatus{}
+
+	for _, name := range names {
+		ruleContent := content.RuleContent{}
+		ruleContent.Summary = "summary of " + name
+		contentDir.Rules[name] = ruleContent
+		statusMap[name] = types.RuleContentStatus{RuleType: content.ExternalRulesGroup, Loaded: true}
+	}
+
+	return contentDir, statusMap
+}
+
+// sendReload sends request to admin reload endpoint with given token
+func sendReload(t *testing.T, s *server.HTTPServer, token string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, config.APIPrefix+server.AdminReloadEndpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return helpers.ExecuteRequest(s, req).Result()
+}
+
+// TestAdminReloadDisabled checks that admin endpoints are disabled when no
+// token is configured
+func TestAdminReloadDisabled(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusForbidden, response.StatusCode)
+}
+
+// TestAdminReloadUnauthorized checks that requests without proper token are refused
+func TestAdminReloadUnauthorized(t *testing.T) {
+	s := server.New(adminConfig(), nil, content.RuleContentDirectory{}, nil)
+
+	response := sendReload(t, s, "")
+	checkResponseCode(t, http.StatusUnauthorized, response.StatusCode)
+
+	response = sendReload(t, s, "wrong-token")
+	checkResponseCode(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+// TestAdminReloadWrongMethod checks that only POST method is allowed
+func TestAdminReloadWrongMethod(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.AdminReloadEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusMethodNotAllowed,
+	})
+}
+
+// TestAdminReloadLoaderError checks that content is kept when it can't be read
+func TestAdminReloadLoaderError(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return content.RuleContentDirectory{}, nil, errors.New("no content")
+	}
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Len(t, s.Content.Rules, 1)
+}
+
+// TestAdminReload checks that new content is installed and diff is reported
+func TestAdminReload(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1", "rule2", "rule3")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+
+	newContent, newStatus := rulesContent("rule1", "rule2", "rule4")
+	newContent.Rules["rule2"] = content.RuleContent{Summary: "changed"}
+	newStatus["rule5"] = types.RuleContentStatus{RuleType: content.InternalRulesGroup, Error: "bad"}
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return newContent, newStatus, nil
+	}
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{
+		"status": "ok",
+		"installed": true,
+		"diff": {
+			"added": ["rule4"],
+			"removed": ["rule3"],
+			"changed": ["rule2"],
+			"newly_failing": ["rule5"],
+			"dropped": 1
+		}
+	}`, response.Body)
+
+	assert.Contains(t, s.Content.Rules, "rule4")
+}
+
+// TestAdminReloadRulesVersion checks that version of reloaded rules is
+// recorded in the snapshot and provided by /info endpoint
+func TestAdminReloadRulesVersion(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+	s.RulesVersion = "1.0.0"
+	s.InfoParams["OCPRulesVersion"] = "1.0.0"
+
+	newContent, newStatus := rulesContent("rule1", "rule2")
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return newContent, newStatus, nil
+	}
+	s.RulesVersionLoader = func() string {
+		return "0123456789abcdef"
+	}
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	snapshots := s.Snapshots()
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "0123456789abcdef", snapshots[0].RulesVersion)
+	assert.Equal(t, "1.0.0", snapshots[1].RulesVersion)
+
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+"info", http.NoBody)
+	helpers.FailOnError(t, err)
+	response = helpers.ExecuteRequest(s, req).Result()
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{
+		"status": "ok",
+		"info": {"OCPRulesVersion": "0123456789abcdef"}
+	}`, response.Body)
+}
+
+// TestAdminReloadManifest checks that manifest of reloaded content replaces
+// the previous one
+func TestAdminReloadManifest(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+	s.SetManifest(&manifest.Verified{Digest: "old"})
+
+	newContent, newStatus := rulesContent("rule1", "rule2")
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return newContent, newStatus, nil
+	}
+	s.ManifestLoader = func() *manifest.Verified {
+		return &manifest.Verified{Digest: "new"}
+	}
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "new", s.Manifest().Digest)
+}
+
+// TestAdminReloadTooManyDropped checks that new content is refused when it
+// would drop too many loaded rules
+func TestAdminReloadTooManyDropped(t *testing.T) {
+	cfg := adminConfig()
+	cfg.MaxDroppedRulesRatio = 0.25
+
+	oldContent, oldStatus := rulesContent("rule1", "rule2", "rule3", "rule4")
+	s := server.New(cfg, nil, oldContent, oldStatus)
+
+	newContent, newStatus := rulesContent("rule1", "rule2")
+	newStatus["rule3"] = types.RuleContentStatus{RuleType: content.ExternalRulesGroup, Error: "bad"}
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return newContent, newStatus, nil
+	}
+	s.ContentInstalled = func() {
+		t.Error("refused content must not be reported as installed")
+	}
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusConflict, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{
+		"status": "2 of 4 loaded rules would be dropped, ratio 0.50 exceeds threshold 0.25",
+		"installed": false,
+		"diff": {
+			"added": [],
+			"removed": ["rule4"],
+			"changed": [],
+			"newly_failing": ["rule3"],
+			"dropped": 2
+		}
+	}`, response.Body)
+
+	assert.Len(t, s.Content.Rules, 4)
+}
+
+// TestAdminReloadContentInstalled checks that installation of reloaded
+// content is reported
+func TestAdminReloadContentInstalled(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		newContent, newStatus := rulesContent("rule1", "rule2")
+		return newContent, newStatus, nil
+	}
+	installed := 0
+	s.ContentInstalled = func() {
+		installed++
+	}
+
+	_, err := s.ReloadContent()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, installed)
+}
+
+// sendDeliveriesRequest sends request to webhook deliveries endpoint
+func sendDeliveriesRequest(t *testing.T, s *server.HTTPServer) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+server.AdminWebhookDeliveriesEndpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	return helpers.ExecuteRequest(s, req).Result()
+}
+
+// TestAdminWebhookDeliveriesDisabled checks response when webhooks are not
+// configured
+func TestAdminWebhookDeliveriesDisabled(t *testing.T) {
+	s := server.New(adminConfig(), nil, content.RuleContentDirectory{}, nil)
+
+	response := sendDeliveriesRequest(t, s)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{"status": "ok", "enabled": false, "deliveries": []}`, response.Body)
+}
+
+// TestAdminWebhookDeliveries checks that deliveries of events published by
+// the server are reported
+func TestAdminWebhookDeliveries(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	dispatcher, err := webhooks.New(webhooks.Configuration{URLs: []string{receiver.URL}, Secret: "secret"})
+	helpers.FailOnError(t, err)
+	defer dispatcher.Stop()
+
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(adminConfig(), nil, oldContent, oldStatus)
+	s.Webhooks = dispatcher
+	s.AddEventListener(func(event server.Event) {
+		dispatcher.Notify(event.Type, event)
+	})
+
+	s.SetContent(rulesContent("rule1", "rule2"))
+
+	assert.Eventually(t, func() bool {
+		deliveries := dispatcher.Deliveries()
+		return len(deliveries) == 1 && deliveries[0].Status == webhooks.StatusDelivered
+	}, 5*time.Second, time.Millisecond)
+
+	response := sendDeliveriesRequest(t, s)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	var body struct {
+		Enabled    bool                `json:"enabled"`
+		Deliveries []webhooks.Delivery `json:"deliveries"`
+	}
+	helpers.FailOnError(t, json.NewDecoder(response.Body).Decode(&body))
+	assert.True(t, body.Enabled)
+	assert.Len(t, body.Deliveries, 1)
+	assert.Equal(t, server.EventSnapshot, body.Deliveries[0].Event)
+	assert.Equal(t, receiver.URL, body.Deliveries[0].URL)
+}
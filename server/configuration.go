@@ -16,10 +16,110 @@ limitations under the License.
 
 package server
 
+import (
+	"net/http"
+	"time"
+)
+
+// Default values used for HTTP server timeouts and limits that are not set
+// in configuration
+const (
+	DefaultReadTimeout       = 1 * time.Minute
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+)
+
 // Configuration represents configuration of REST API HTTP server
 type Configuration struct {
 	Address     string `mapstructure:"address" toml:"address"`
 	APIPrefix   string `mapstructure:"api_prefix" toml:"api_prefix"`
 	APISpecFile string `mapstructure:"api_spec_file" toml:"api_spec_file"`
 	Debug       bool   `mapstructure:"debug" toml:"debug"`
+
+	// ReadTimeout is maximum duration for reading the entire request
+	ReadTimeout time.Duration `mapstructure:"read_timeout" toml:"read_timeout"`
+	// ReadHeaderTimeout is maximum duration for reading request headers
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" toml:"read_header_timeout"`
+	// WriteTimeout is maximum duration before timing out writes of the
+	// response, it needs to be long enough to send the whole content
+	WriteTimeout time.Duration `mapstructure:"write_timeout" toml:"write_timeout"`
+	// IdleTimeout is maximum time to wait for the next request when
+	// keep-alives are enabled, ReadTimeout is used when not set
+	IdleTimeout time.Duration `mapstructure:"idle_timeout" toml:"idle_timeout"`
+	// MaxHeaderBytes is maximum number of bytes the server will read
+	// parsing the request headers
+	MaxHeaderBytes int `mapstructure:"max_header_bytes" toml:"max_header_bytes"`
+	// DisableKeepAlives disables HTTP keep-alives, so each connection
+	// is closed after one request
+	DisableKeepAlives bool `mapstructure:"disable_keep_alives" toml:"disable_keep_alives"`
+
+	// ShutdownDrainPeriod is time to wait after shutdown signal is
+	// received before the server stops accepting new connections
+	ShutdownDrainPeriod time.Duration `mapstructure:"shutdown_drain_period" toml:"shutdown_drain_period"`
+	// ShutdownTimeout is maximum time to wait for active connections to
+	// finish during shutdown
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	// MaxInvalidRulesRatio is the highest ratio of rules that failed to
+	// be parsed for which the service is still reported as ready. The
+	// check is disabled when set to zero.
+	MaxInvalidRulesRatio float64 `mapstructure:"max_invalid_rules_ratio" toml:"max_invalid_rules_ratio"`
+
+	// MaxSnapshots is number of content snapshots kept by the server,
+	// including the current one
+	MaxSnapshots int `mapstructure:"max_snapshots" toml:"max_snapshots"`
+
+	// EventsHeartbeat is interval between heartbeats sent to events
+	// subscribers
+	EventsHeartbeat time.Duration `mapstructure:"events_heartbeat" toml:"events_heartbeat"`
+	// MaxEventSubscribers is maximum number of concurrent events
+	// subscribers
+	MaxEventSubscribers int `mapstructure:"max_event_subscribers" toml:"max_event_subscribers"`
+
+	// AdminToken is bearer token required by admin endpoints, the admin
+	// endpoints are disabled when not set
+	AdminToken string `mapstructure:"admin_token" toml:"admin_token"`
+	// MaxDroppedRulesRatio is the highest ratio of currently loaded rules
+	// that can be dropped by content reload. The check is disabled when
+	// set to zero.
+	MaxDroppedRulesRatio float64 `mapstructure:"max_dropped_rules_ratio" toml:"max_dropped_rules_ratio"`
+
+	// CORSAllowedOrigins contains origins allowed to access REST API from
+	// web browsers, "*" allows all origins
+	CORSAllowedOrigins []string `mapstructure:"cors_allowed_origins" toml:"cors_allowed_origins"`
+	// DisabledRules contains names of rules that are not served even if
+	// they are present in rule content
+	DisabledRules []string `mapstructure:"disabled_rules" toml:"disabled_rules"`
+}
+
+// durationOrDefault returns the provided duration or the default one when
+// the duration is not set
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration == 0 {
+		return defaultDuration
+	}
+	return duration
+}
+
+// newHTTPServer constructs HTTP server with timeouts and limits taken from
+// the configuration
+func newHTTPServer(config Configuration, handler http.Handler) *http.Server {
+	maxHeaderBytes := config.MaxHeaderBytes
+	if maxHeaderBytes == 0 {
+		maxHeaderBytes = DefaultMaxHeaderBytes
+	}
+
+	serv := &http.Server{
+		Addr:              config.Address,
+		Handler:           handler,
+		ReadTimeout:       durationOrDefault(config.ReadTimeout, DefaultReadTimeout),
+		ReadHeaderTimeout: durationOrDefault(config.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		WriteTimeout:      durationOrDefault(config.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+	serv.SetKeepAlivesEnabled(!config.DisableKeepAlives)
+
+	return serv
 }
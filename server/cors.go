@@ -0,0 +1,87 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+)
+
+const (
+	// allOrigins allows all origins to access REST API
+	allOrigins = "*"
+
+	corsAllowedMethods = "GET, POST, OPTIONS"
+	corsAllowedHeaders = "Authorization, Content-Type, Last-Event-ID"
+)
+
+// corsOriginsSet converts list of allowed origins into a set
+func corsOriginsSet(origins []string) map[string]bool {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return allowed
+}
+
+// SetCORSAllowedOrigins method replaces origins allowed to access REST API
+// from web browsers while the server is running
+func (server *HTTPServer) SetCORSAllowedOrigins(origins []string) {
+	allowed := corsOriginsSet(origins)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.corsOrigins = allowed
+}
+
+// corsOriginAllowed returns true if the origin is allowed to access REST API
+func (server *HTTPServer) corsOriginAllowed(origin string) bool {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	return server.corsOrigins[allOrigins] || server.corsOrigins[origin]
+}
+
+// cors middleware adds CORS headers to responses for allowed origins and
+// answers preflight requests sent by web browsers
+func (server *HTTPServer) cors(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		origin := request.Header.Get("Origin")
+		if origin == "" {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		// responses differ by origin, so caches need to know about it
+		header := writer.Header()
+		header.Add("Vary", "Origin")
+		if !server.corsOriginAllowed(origin) {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", origin)
+
+		if request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		nextHandler.ServeHTTP(writer, request)
+	})
+}
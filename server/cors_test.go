@@ -0,0 +1,98 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+)
+
+const allowedOrigin = "https://console.redhat.com"
+
+// sendWithOrigin sends request with Origin header to the server
+func sendWithOrigin(t *testing.T, s *server.HTTPServer, method, origin string) *http.Response {
+	req, err := http.NewRequest(method, config.APIPrefix+"info", http.NoBody)
+	helpers.FailOnError(t, err)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+
+	return helpers.ExecuteRequest(s, req).Result()
+}
+
+// TestCORSAllowedOrigin checks that CORS headers are sent just to allowed
+// origins
+func TestCORSAllowedOrigin(t *testing.T) {
+	cfg := config
+	cfg.CORSAllowedOrigins = []string{allowedOrigin}
+	s := server.New(cfg, nil, content.RuleContentDirectory{}, nil)
+
+	response := sendWithOrigin(t, s, http.MethodGet, allowedOrigin)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, allowedOrigin, response.Header.Get("Access-Control-Allow-Origin"))
+
+	response = sendWithOrigin(t, s, http.MethodGet, "https://example.com")
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+}
+
+// TestCORSAllOrigins checks that all origins are allowed by "*"
+func TestCORSAllOrigins(t *testing.T) {
+	cfg := config
+	cfg.CORSAllowedOrigins = []string{"*"}
+	s := server.New(cfg, nil, content.RuleContentDirectory{}, nil)
+
+	response := sendWithOrigin(t, s, http.MethodGet, "https://example.com")
+	assert.Equal(t, "https://example.com", response.Header.Get("Access-Control-Allow-Origin"))
+}
+
+// TestCORSPreflight checks that preflight requests of allowed origins are
+// answered
+func TestCORSPreflight(t *testing.T) {
+	cfg := config
+	cfg.CORSAllowedOrigins = []string{allowedOrigin}
+	s := server.New(cfg, nil, content.RuleContentDirectory{}, nil)
+
+	response := sendWithOrigin(t, s, http.MethodOptions, allowedOrigin)
+	checkResponseCode(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, allowedOrigin, response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, response.Header.Get("Access-Control-Allow-Methods"), http.MethodGet)
+
+	response = sendWithOrigin(t, s, http.MethodOptions, "https://example.com")
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Methods"))
+}
+
+// TestSetCORSAllowedOrigins checks that allowed origins can be changed while
+// the server is running
+func TestSetCORSAllowedOrigins(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+
+	response := sendWithOrigin(t, s, http.MethodGet, allowedOrigin)
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+
+	s.SetCORSAllowedOrigins([]string{allowedOrigin})
+	response = sendWithOrigin(t, s, http.MethodGet, allowedOrigin)
+	assert.Equal(t, allowedOrigin, response.Header.Get("Access-Control-Allow-Origin"))
+}
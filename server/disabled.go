@@ -0,0 +1,97 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	types "github.com/RedHatInsights/insights-results-types"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// disabledRulesSet converts list of disabled rules into a set
+func disabledRulesSet(rules []string) map[string]bool {
+	disabled := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		disabled[rule] = true
+	}
+	return disabled
+}
+
+// SetDisabledRules method replaces list of rules that are not served even if
+// they are present in rule content. The served content is replaced the same
+// way as by SetContent when the change affects any rule present in it.
+func (server *HTTPServer) SetDisabledRules(rules []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.disabledRules = disabledRulesSet(rules)
+	if !server.contentLoaded {
+		return
+	}
+
+	// content is not replaced when the same rules would be served
+	served, servedStatusMap := server.withoutDisabledRules(server.parsedContent, server.parsedStatusMap)
+	if sameKeys(served.Rules, server.Content.Rules) && sameKeys(servedStatusMap, server.ruleContentStatusMap) {
+		return
+	}
+
+	server.setContent(server.parsedContent, server.parsedStatusMap, server.RulesVersion)
+}
+
+// sameKeys returns true if both maps contain the same keys
+func sameKeys[V1, V2 any](first map[string]V1, second map[string]V2) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for key := range first {
+		if _, found := second[key]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// withoutDisabledRules returns rule content and status of parsed rules
+// without disabled rules. Provided content is returned when no rule is
+// disabled. The mutex needs to be locked.
+func (server *HTTPServer) withoutDisabledRules(contentDir content.RuleContentDirectory,
+	ruleContentStatusMap map[string]types.RuleContentStatus) (content.RuleContentDirectory, map[string]types.RuleContentStatus) {
+	if len(server.disabledRules) == 0 {
+		return contentDir, ruleContentStatusMap
+	}
+
+	// the content is replaced, not modified, so new maps are needed
+	rules := make(map[string]content.RuleContent, len(contentDir.Rules))
+	for name, rule := range contentDir.Rules {
+		if !server.disabledRules[name] {
+			rules[name] = rule
+		}
+	}
+	contentDir.Rules = rules
+
+	if ruleContentStatusMap == nil {
+		return contentDir, nil
+	}
+	statusMap := make(map[string]types.RuleContentStatus, len(ruleContentStatusMap))
+	for name, status := range ruleContentStatusMap {
+		if !server.disabledRules[name] {
+			statusMap[name] = status
+		}
+	}
+
+	return contentDir, statusMap
+}
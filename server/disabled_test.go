@@ -0,0 +1,129 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+)
+
+// servedRules returns names of rules provided by status endpoint
+func servedRules(t *testing.T, s *server.HTTPServer) []string {
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+server.StatusEndpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+	response := helpers.ExecuteRequest(s, req).Result()
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	var body struct {
+		Rules map[string]types.RuleContentStatus `json:"rules"`
+	}
+	helpers.FailOnError(t, json.NewDecoder(response.Body).Decode(&body))
+
+	names := []string{}
+	for name := range body.Rules {
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestDisabledRulesNotServed checks that rules disabled in configuration are
+// not served
+func TestDisabledRulesNotServed(t *testing.T) {
+	cfg := config
+	cfg.DisabledRules = []string{"rule2"}
+	contentDir, statusMap := rulesContent("rule1", "rule2")
+
+	s := server.New(cfg, nil, contentDir, statusMap)
+
+	assert.Contains(t, s.Content.Rules, "rule1")
+	assert.NotContains(t, s.Content.Rules, "rule2")
+	// provided content is not modified
+	assert.Contains(t, contentDir.Rules, "rule2")
+	assert.Contains(t, statusMap, "rule2")
+}
+
+// TestSetDisabledRules checks that disabled rules can be changed while the
+// server is running and that disabled rules can be enabled again
+func TestSetDisabledRules(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1", "rule2")
+	s := server.New(config, nil, contentDir, statusMap)
+	assert.Len(t, s.Snapshots(), 1)
+
+	s.SetDisabledRules([]string{"rule2"})
+	assert.NotContains(t, s.Content.Rules, "rule2")
+	assert.Len(t, s.Snapshots(), 2)
+
+	s.SetDisabledRules(nil)
+	assert.Contains(t, s.Content.Rules, "rule2")
+}
+
+// TestSetDisabledRulesSwapped checks that content is replaced when one
+// disabled rule is replaced by another one
+func TestSetDisabledRulesSwapped(t *testing.T) {
+	cfg := config
+	cfg.DisabledRules = []string{"rule1"}
+	contentDir, statusMap := rulesContent("rule1", "rule2", "rule3")
+	s := server.New(cfg, nil, contentDir, statusMap)
+	assert.ElementsMatch(t, []string{"rule2", "rule3"}, servedRules(t, s))
+
+	s.SetDisabledRules([]string{"rule2"})
+	assert.ElementsMatch(t, []string{"rule1", "rule3"}, servedRules(t, s))
+	assert.Contains(t, s.Content.Rules, "rule1")
+	assert.NotContains(t, s.Content.Rules, "rule2")
+}
+
+// TestSetDisabledRulesNotPresent checks that content is not replaced when
+// disabled rules are not present in it
+func TestSetDisabledRulesNotPresent(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1", "rule2")
+	s := server.New(config, nil, contentDir, statusMap)
+	assert.Len(t, s.Snapshots(), 1)
+
+	s.SetDisabledRules([]string{"rule3"})
+	assert.Len(t, s.Snapshots(), 1)
+}
+
+// TestAdminReloadDisabledRules checks that disabled rules are not installed
+// and not reported in diff when content is reloaded
+func TestAdminReloadDisabledRules(t *testing.T) {
+	cfg := adminConfig()
+	cfg.DisabledRules = []string{"rule3"}
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(cfg, nil, oldContent, oldStatus)
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		newContent, newStatus := rulesContent("rule1", "rule2", "rule3")
+		return newContent, newStatus, nil
+	}
+
+	diff, err := s.ReloadContent()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rule2"}, diff.Added)
+	assert.Contains(t, s.Content.Rules, "rule2")
+	assert.NotContains(t, s.Content.Rules, "rule3")
+
+	// disabled rule is available once it is enabled again
+	s.SetDisabledRules(nil)
+	assert.Contains(t, s.Content.Rules, "rule3")
+}
@@ -31,6 +31,8 @@ const (
 	MainEndpoint = ""
 	// GroupsEndpoint defines suffix of the groups request endpoint
 	GroupsEndpoint = "groups"
+	// GroupsStatusEndpoint returns problems found in groups configuration
+	GroupsStatusEndpoint = "groups/status"
 	// AllContentEndpoint defines suffix for all the content
 	AllContentEndpoint = "content"
 	// MetricsEndpoint returns Prometheus metrics
@@ -41,6 +43,30 @@ const (
 	// InfoEndpoint returns basic information about content service
 	// version, utils repository version, commit hash etc.
 	InfoEndpoint = "info"
+	// TagsEndpoint returns list of all tags together with their usage
+	TagsEndpoint = "tags"
+	// LivenessEndpoint reports whether the service is running
+	LivenessEndpoint = "health/live"
+	// ReadinessEndpoint reports whether the service is ready to serve
+	// content
+	ReadinessEndpoint = "health/ready"
+	// ContentChangesEndpoint returns difference between selected content
+	// snapshot and the current content
+	ContentChangesEndpoint = "content/changes"
+	// VersionsEndpoint returns list of all content snapshots
+	VersionsEndpoint = "versions"
+	// VersionedRuleEndpoint returns content of one rule from selected
+	// content snapshot
+	VersionedRuleEndpoint = "versions/{version}/rules/{rule}"
+	// ManifestEndpoint returns verified manifest of the served content
+	ManifestEndpoint = "manifest"
+	// EventsEndpoint streams server-sent events announcing content changes
+	EventsEndpoint = "events"
+	// AdminReloadEndpoint reads rule content again and replaces the served
+	// content
+	AdminReloadEndpoint = "admin/reload"
+	// AdminWebhookDeliveriesEndpoint returns recent webhook deliveries
+	AdminWebhookDeliveriesEndpoint = "admin/webhooks/deliveries"
 )
 
 // addEndpointsToRouter method registers handlers for all REST API endpoints
@@ -51,9 +77,26 @@ func (server *HTTPServer) addEndpointsToRouter(router *mux.Router) {
 	// common REST API endpoints
 	router.HandleFunc(apiPrefix+MainEndpoint, server.mainEndpoint).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+GroupsEndpoint, server.listOfGroups).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+GroupsStatusEndpoint, server.groupsStatus).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+AllContentEndpoint, server.getStaticContent).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+StatusEndpoint, server.ruleContentStates).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+InfoEndpoint, server.infoMap).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+TagsEndpoint, server.listOfTags).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+ContentChangesEndpoint, server.contentChanges).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+VersionsEndpoint, server.listOfVersions).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+VersionedRuleEndpoint, server.getVersionedRule).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+ManifestEndpoint, server.contentManifest).Methods(http.MethodGet, http.MethodOptions)
+
+	// health checks
+	router.HandleFunc(apiPrefix+LivenessEndpoint, server.liveness).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ReadinessEndpoint, server.readiness).Methods(http.MethodGet)
+
+	// server-sent events
+	router.HandleFunc(apiPrefix+EventsEndpoint, server.eventsStream).Methods(http.MethodGet).Name(streamingRouteName)
+
+	// admin endpoints
+	router.HandleFunc(apiPrefix+AdminReloadEndpoint, server.adminAuth(server.reloadContent)).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+AdminWebhookDeliveriesEndpoint, server.adminAuth(server.webhookDeliveries)).Methods(http.MethodGet)
 
 	// Prometheus metrics
 	router.Handle(apiPrefix+MetricsEndpoint, promhttp.Handler()).Methods(http.MethodGet)
@@ -0,0 +1,318 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+// Types of events sent to subscribers
+const (
+	// EventSnapshot is sent when new content snapshot is installed
+	EventSnapshot = "snapshot"
+	// EventReloadFailed is sent when content reload fails or when new
+	// content is refused
+	EventReloadFailed = "reload_failed"
+)
+
+const (
+	// DefaultEventsHeartbeat is interval between heartbeats sent to
+	// subscribers when it is not configured
+	DefaultEventsHeartbeat = 15 * time.Second
+	// DefaultMaxEventSubscribers is number of subscribers allowed when it
+	// is not configured
+	DefaultMaxEventSubscribers = 100
+
+	// eventsHistorySize is number of events kept for resuming streams
+	eventsHistorySize = 100
+	// subscriberBufferSize is number of events buffered for a subscriber,
+	// slow subscribers that do not keep up are disconnected
+	subscriberBufferSize = 16
+
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+var (
+	// errTooManySubscribers is returned when the limit of subscribers is
+	// reached
+	errTooManySubscribers = errors.New("too many events subscribers")
+	// errEventsClosed is returned when the server is being stopped
+	errEventsClosed = errors.New("events are not sent anymore, service is shutting down")
+)
+
+// Event announces a change of the served content
+type Event struct {
+	ID           uint64    `json:"id"`
+	Type         string    `json:"type"`
+	Hash         string    `json:"hash"`
+	Timestamp    time.Time `json:"timestamp"`
+	Added        int       `json:"added"`
+	Removed      int       `json:"removed"`
+	Changed      int       `json:"changed"`
+	NewlyFailing int       `json:"newly_failing"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// eventBroker distributes events to all subscribers. Publishing never
+// blocks, subscribers whose buffer is full are disconnected.
+type eventBroker struct {
+	mutex       sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	listeners   []EventListener
+	// closed is set when the server is being stopped
+	closed bool
+}
+
+// EventListener is a function called for each published event. Listeners
+// are called synchronously, so they must not block.
+type EventListener func(Event)
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// publish assigns ID to the event and sends it to all subscribers
+func (broker *eventBroker) publish(event Event) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.lastID++
+	event.ID = broker.lastID
+
+	broker.history = append(broker.history, event)
+	if len(broker.history) > eventsHistorySize {
+		broker.history = broker.history[len(broker.history)-eventsHistorySize:]
+	}
+
+	for _, listener := range broker.listeners {
+		listener(event)
+	}
+
+	for subscriber := range broker.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Warn().Msg("Events subscriber is too slow, disconnecting")
+			delete(broker.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// subscribe registers new subscriber and returns events published after
+// the provided event ID that are still kept. Error is returned when there
+// are too many subscribers or when the broker has been closed.
+func (broker *eventBroker) subscribe(lastEventID uint64, maxSubscribers int) (chan Event, []Event, error) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	if broker.closed {
+		return nil, nil, errEventsClosed
+	}
+	if len(broker.subscribers) >= maxSubscribers {
+		return nil, nil, errTooManySubscribers
+	}
+
+	missed := []Event{}
+	for _, event := range broker.history {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	subscriber := make(chan Event, subscriberBufferSize)
+	broker.subscribers[subscriber] = struct{}{}
+
+	return subscriber, missed, nil
+}
+
+// addListener registers function called for each published event
+func (broker *eventBroker) addListener(listener EventListener) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.listeners = append(broker.listeners, listener)
+}
+
+// unsubscribe removes the subscriber if it is still registered
+func (broker *eventBroker) unsubscribe(subscriber chan Event) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	if _, found := broker.subscribers[subscriber]; found {
+		delete(broker.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// closeAll disconnects all subscribers and refuses new ones, so streams of
+// events don't prevent graceful shutdown
+func (broker *eventBroker) closeAll() {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.closed = true
+	for subscriber := range broker.subscribers {
+		delete(broker.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// AddEventListener method registers function called for each event
+// announcing content change, it is used to forward events to other
+// notification channels
+func (server *HTTPServer) AddEventListener(listener EventListener) {
+	server.events.addListener(listener)
+}
+
+// diffEvent constructs event with change counts taken from content diff
+func diffEvent(eventType, hash string, diff content.Diff) Event {
+	return Event{
+		Type:         eventType,
+		Hash:         hash,
+		Timestamp:    time.Now().UTC(),
+		Added:        len(diff.Added),
+		Removed:      len(diff.Removed),
+		Changed:      len(diff.Changed),
+		NewlyFailing: len(diff.NewlyFailing),
+	}
+}
+
+// currentHash returns hash of the current content snapshot. The mutex
+// needs to be locked.
+func (server *HTTPServer) currentHash() string {
+	server.ensureSnapshot()
+	if len(server.snapshots) == 0 {
+		return ""
+	}
+	return server.snapshots[0].Hash
+}
+
+// publishReloadFailure announces failed or refused content reload
+func (server *HTTPServer) publishReloadFailure(diff content.Diff, reason string) {
+	server.mutex.Lock()
+	hash := server.currentHash()
+	server.mutex.Unlock()
+
+	event := diffEvent(EventReloadFailed, hash, diff)
+	event.Error = reason
+	server.events.publish(event)
+}
+
+// writeEvent writes one event in server-sent events format
+func writeEvent(writer http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// eventsStream handler sends server-sent events announcing content changes.
+// Events missed since the event specified in Last-Event-ID header are sent
+// first when they are still kept.
+func (server *HTTPServer) eventsStream(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		logResponseError(responses.SendInternalServerError(writer, "Streaming is not supported"))
+		return
+	}
+
+	var lastEventID uint64
+	if header := request.Header.Get(lastEventIDHeader); header != "" {
+		parsed, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			logResponseError(responses.SendBadRequest(writer, "Invalid "+lastEventIDHeader+" header: "+header))
+			return
+		}
+		lastEventID = parsed
+	}
+
+	maxSubscribers := server.Config.MaxEventSubscribers
+	if maxSubscribers <= 0 {
+		maxSubscribers = DefaultMaxEventSubscribers
+	}
+
+	subscriber, missed, err := server.events.subscribe(lastEventID, maxSubscribers)
+	if errors.Is(err, errTooManySubscribers) {
+		logResponseError(responses.SendServiceUnavailable(writer, "Too many events subscribers"))
+		return
+	}
+	if err != nil {
+		logResponseError(responses.SendServiceUnavailable(writer, "Service is shutting down"))
+		return
+	}
+	defer server.events.unsubscribe(subscriber)
+
+	// the stream is not limited by server write timeout; error is returned
+	// when the writer does not support deadlines and it is safe to ignore it
+	_ = http.NewResponseController(writer).SetWriteDeadline(time.Time{})
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err := writeEvent(writer, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeatInterval := durationOrDefault(server.Config.EventsHeartbeat, DefaultEventsHeartbeat)
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, open := <-subscriber:
+			if !open {
+				// subscriber has been disconnected because it is too
+				// slow or because the server is being stopped, the client
+				// is expected to reconnect with Last-Event-ID header
+				return
+			}
+			if err := writeEvent(writer, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
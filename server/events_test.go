@@ -0,0 +1,253 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+)
+
+// eventsStream represents connection to events endpoint
+type eventsStream struct {
+	response *http.Response
+	reader   *bufio.Reader
+}
+
+// openEventsStream connects to events endpoint of the test server
+func openEventsStream(t *testing.T, testServer *httptest.Server, lastEventID string) *eventsStream {
+	req, err := http.NewRequest(http.MethodGet, testServer.URL+config.APIPrefix+server.EventsEndpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	helpers.FailOnError(t, err)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	t.Cleanup(func() {
+		_ = response.Body.Close()
+	})
+
+	return &eventsStream{response: response, reader: bufio.NewReader(response.Body)}
+}
+
+// next reads next message from the stream, heartbeats are returned as
+// comments
+func (stream *eventsStream) next(t *testing.T) (server.Event, bool) {
+	var event server.Event
+	heartbeat := false
+
+	for {
+		line, err := stream.reader.ReadString('\n')
+		helpers.FailOnError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			return event, heartbeat
+		case strings.HasPrefix(line, ":"):
+			heartbeat = true
+		case strings.HasPrefix(line, "data: "):
+			helpers.FailOnError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		}
+	}
+}
+
+// newEventsTestServer starts test server with provided content, the server
+// is closed after all streams opened by the test
+func newEventsTestServer(t *testing.T, cfg server.Configuration, names ...string) (*server.HTTPServer, *httptest.Server) {
+	contentDir, statusMap := rulesContent(names...)
+	s := server.New(cfg, nil, contentDir, statusMap)
+	testServer := httptest.NewServer(s.Initialize())
+	t.Cleanup(testServer.Close)
+	return s, testServer
+}
+
+// TestEventsSnapshot checks that event is sent when new snapshot is installed
+func TestEventsSnapshot(t *testing.T) {
+	s, testServer := newEventsTestServer(t, config, "rule1", "rule2")
+
+	stream := openEventsStream(t, testServer, "")
+
+	newContent, newStatus := rulesContent("rule1", "rule3")
+	newStatus["rule4"] = types.RuleContentStatus{Error: "bad"}
+	s.SetContent(newContent, newStatus)
+
+	event, heartbeat := stream.next(t)
+	assert.False(t, heartbeat)
+	assert.Equal(t, uint64(1), event.ID)
+	assert.Equal(t, server.EventSnapshot, event.Type)
+	assert.Equal(t, s.Snapshots()[0].Hash, event.Hash)
+	assert.Equal(t, 1, event.Added)
+	assert.Equal(t, 1, event.Removed)
+	assert.Equal(t, 0, event.Changed)
+	assert.Equal(t, 1, event.NewlyFailing)
+}
+
+// TestEventsReloadFailed checks that event is sent when reload fails
+func TestEventsReloadFailed(t *testing.T) {
+	s, testServer := newEventsTestServer(t, adminConfig(), "rule1")
+	s.ContentLoader = func() (content.RuleContentDirectory, map[string]types.RuleContentStatus, error) {
+		return content.RuleContentDirectory{}, nil, errors.New("no content")
+	}
+
+	stream := openEventsStream(t, testServer, "")
+
+	response := sendReload(t, s, adminToken)
+	checkResponseCode(t, http.StatusInternalServerError, response.StatusCode)
+
+	event, _ := stream.next(t)
+	assert.Equal(t, server.EventReloadFailed, event.Type)
+	assert.Equal(t, "no content", event.Error)
+	assert.Equal(t, s.Snapshots()[0].Hash, event.Hash)
+}
+
+// TestEventsResume checks that missed events are sent when Last-Event-ID
+// header is provided
+func TestEventsResume(t *testing.T) {
+	s, testServer := newEventsTestServer(t, config, "rule1")
+
+	for _, name := range []string{"rule2", "rule3", "rule4"} {
+		contentDir, statusMap := rulesContent(name)
+		s.SetContent(contentDir, statusMap)
+	}
+
+	stream := openEventsStream(t, testServer, "1")
+
+	event, _ := stream.next(t)
+	assert.Equal(t, uint64(2), event.ID)
+	event, _ = stream.next(t)
+	assert.Equal(t, uint64(3), event.ID)
+}
+
+// TestEventsInvalidLastEventID checks that malformed Last-Event-ID is refused
+func TestEventsInvalidLastEventID(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.EventsEndpoint,
+		ExtraHeaders: http.Header{"Last-Event-ID": []string{"xyzzy"}},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+// TestEventsHeartbeat checks that heartbeats are sent
+func TestEventsHeartbeat(t *testing.T) {
+	cfg := config
+	cfg.EventsHeartbeat = 10 * time.Millisecond
+	_, testServer := newEventsTestServer(t, cfg, "rule1")
+
+	stream := openEventsStream(t, testServer, "")
+
+	_, heartbeat := stream.next(t)
+	assert.True(t, heartbeat)
+}
+
+// TestEventsTooManySubscribers checks that number of subscribers is limited
+func TestEventsTooManySubscribers(t *testing.T) {
+	cfg := config
+	cfg.MaxEventSubscribers = 1
+	_, testServer := newEventsTestServer(t, cfg, "rule1")
+
+	openEventsStream(t, testServer, "")
+
+	response, err := http.Get(testServer.URL + config.APIPrefix + server.EventsEndpoint)
+	helpers.FailOnError(t, err)
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	checkResponseCode(t, http.StatusServiceUnavailable, response.StatusCode)
+}
+
+// TestEventBrokerSlowSubscriber checks that slow subscribers do not block
+// publishing and that they are disconnected
+func TestEventBrokerSlowSubscriber(t *testing.T) {
+	broker := server.NewEventBroker()
+	subscriber, missed, err := broker.Subscribe(0, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, missed)
+
+	// nobody reads the events
+	for i := 0; i < 100; i++ {
+		broker.Publish(server.Event{Type: server.EventSnapshot})
+	}
+
+	received := 0
+	for range subscriber {
+		received++
+	}
+	assert.Less(t, received, 100)
+
+	// the slot is free again
+	subscriber, missed, err = broker.Subscribe(0, 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, subscriber)
+	assert.Len(t, missed, 100)
+}
+
+// TestEventBrokerCloseAll checks that all subscribers are disconnected and
+// new ones are refused when broker is closed
+func TestEventBrokerCloseAll(t *testing.T) {
+	broker := server.NewEventBroker()
+	subscriber, _, err := broker.Subscribe(0, 10)
+	assert.NoError(t, err)
+
+	broker.CloseAll()
+	_, open := <-subscriber
+	assert.False(t, open)
+
+	_, _, err = broker.Subscribe(0, 10)
+	assert.Error(t, err)
+}
+
+// TestServerStopWithEventsSubscriber checks that connected subscriber of
+// events does not prevent graceful shutdown
+func TestServerStopWithEventsSubscriber(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1")
+	s := server.New(config, nil, contentDir, statusMap)
+	testServer := httptest.NewUnstartedServer(s.Initialize())
+	s.Serv = testServer.Config
+	testServer.Start()
+	t.Cleanup(testServer.Close)
+
+	stream := openEventsStream(t, testServer, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, s.Stop(ctx))
+
+	// the stream has been finished by the server
+	_, err := stream.reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
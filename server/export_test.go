@@ -28,4 +28,24 @@ package server
 // symbols (externally invisible) in unit tests.
 var (
 	FilterStatusMap = filterStatusMap
+	NewHTTPServer   = newHTTPServer
+	NewEventBroker  = newEventBroker
 )
+
+// EventBroker is an alias for the events broker used by the server
+type EventBroker = eventBroker
+
+// Publish publishes event via broker
+func (broker *eventBroker) Publish(event Event) {
+	broker.publish(event)
+}
+
+// Subscribe registers new subscriber in broker
+func (broker *eventBroker) Subscribe(lastEventID uint64, maxSubscribers int) (chan Event, []Event, error) {
+	return broker.subscribe(lastEventID, maxSubscribers)
+}
+
+// CloseAll disconnects all subscribers of broker
+func (broker *eventBroker) CloseAll() {
+	broker.closeAll()
+}
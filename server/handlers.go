@@ -28,6 +28,12 @@ import (
 	"github.com/RedHatInsights/insights-content-service/groups"
 )
 
+// formats supported by the groups endpoint
+const (
+	groupsFormatFlat = "flat"
+	groupsFormatTree = "tree"
+)
+
 // mainEndpoint will handle the requests for / endpoint
 func (server *HTTPServer) mainEndpoint(writer http.ResponseWriter, _ *http.Request) {
 	err := responses.SendOK(writer, responses.BuildOkResponse())
@@ -38,17 +44,79 @@ func (server *HTTPServer) mainEndpoint(writer http.ResponseWriter, _ *http.Reque
 	}
 }
 
-// listOfGroups handler returns the list of defined groups
+// listOfGroups handler returns the list of defined groups. Tags of child
+// groups are rolled up into their parents. When format=tree is specified,
+// the groups are returned as a tree instead of flat list.
 func (server *HTTPServer) listOfGroups(writer http.ResponseWriter, request *http.Request) {
+	format := request.URL.Query().Get("format")
+
+	var data interface{}
+
+	switch format {
+	case "", groupsFormatFlat:
+		data = server.rolledUpGroups()
+	case groupsFormatTree:
+		// groups map is replaced, not modified, when groups are reloaded
+		server.mutex.RLock()
+		groupsMap := server.Groups
+		server.mutex.RUnlock()
+
+		data = groups.Tree(groupsMap)
+	default:
+		err := responses.SendBadRequest(writer, "Unknown format: "+format)
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+			handleServerError(err)
+		}
+		return
+	}
+
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("groups", data))
+	if err != nil {
+		log.Error().Err(err)
+		handleServerError(err)
+		return
+	}
+}
+
+// rolledUpGroups method returns flat list of groups with tags of child
+// groups rolled up into their parents. The list is computed once for each
+// groups configuration.
+func (server *HTTPServer) rolledUpGroups() []groups.Group {
+	server.mutex.RLock()
+	groupsList := server.groupsList
+	server.mutex.RUnlock()
+
+	if groupsList != nil {
+		return groupsList
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	// the list might have been computed by another request in the meantime
 	if server.groupsList == nil {
-		server.groupsList = make([]groups.Group, 0, len(server.Groups))
+		rolledUp := groups.RollUp(server.Groups)
+		server.groupsList = make([]groups.Group, 0, len(rolledUp))
 
-		for _, group := range server.Groups {
+		for _, group := range rolledUp {
 			server.groupsList = append(server.groupsList, group)
 		}
 	}
+	return server.groupsList
+}
+
+// groupsStatus handler returns warnings found in groups configuration
+func (server *HTTPServer) groupsStatus(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.RLock()
+	findings := server.GroupsFindings
+	server.mutex.RUnlock()
 
-	err := responses.SendOK(writer, responses.BuildOkResponseWithData("groups", server.groupsList))
+	if findings == nil {
+		findings = groups.Findings{}
+	}
+
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("findings", findings))
 	if err != nil {
 		log.Error().Err(err)
 		handleServerError(err)
@@ -56,16 +124,61 @@ func (server *HTTPServer) listOfGroups(writer http.ResponseWriter, request *http
 	}
 }
 
+// listOfTags handler returns the list of all tags used in content metadata
+// or in groups configuration together with their usage
+func (server *HTTPServer) listOfTags(writer http.ResponseWriter, request *http.Request) {
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("tags", server.tagsCatalog()))
+	if err != nil {
+		log.Error().Err(err)
+		handleServerError(err)
+		return
+	}
+}
+
+// tagsCatalog method returns all tags used in content metadata or in groups
+// configuration. The catalog is computed once for each content and groups
+// configuration.
+func (server *HTTPServer) tagsCatalog() []groups.Tag {
+	server.mutex.RLock()
+	tagsList := server.tagsList
+	server.mutex.RUnlock()
+
+	if tagsList != nil {
+		return tagsList
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	// the catalog might have been computed by another request in the meantime
+	if server.tagsList == nil {
+		server.tagsList = groups.TagsCatalog(server.Groups, server.Content)
+	}
+	return server.tagsList
+}
+
 // infoMap handler returns map of additional information about this service
 func (server *HTTPServer) infoMap(writer http.ResponseWriter, request *http.Request) {
-	if server.InfoParams == nil {
+	// rules version can be updated when content is reloaded, so the map
+	// is copied
+	server.mutex.RLock()
+	var infoParams map[string]string
+	if server.InfoParams != nil {
+		infoParams = make(map[string]string, len(server.InfoParams))
+		for key, value := range server.InfoParams {
+			infoParams[key] = value
+		}
+	}
+	server.mutex.RUnlock()
+
+	if infoParams == nil {
 		err := errors.New("InfoParams is empty")
 		log.Error().Err(err)
 		handleServerError(err)
 		return
 	}
 
-	err := responses.SendOK(writer, responses.BuildOkResponseWithData("info", server.InfoParams))
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("info", infoParams))
 	if err != nil {
 		log.Error().Err(err)
 		handleServerError(err)
@@ -83,12 +196,17 @@ func (server *HTTPServer) ruleContentStates(writer http.ResponseWriter, request
 		return
 	}
 
+	// status map is replaced, not modified, when content is reloaded
+	server.mutex.RLock()
+	allStates := server.ruleContentStatusMap
+	server.mutex.RUnlock()
+
 	// apply filters if specified on command line
-	ruleContentStatusMap := filterStatusMap(server.ruleContentStatusMap, query)
+	ruleContentStatusMap := filterStatusMap(allStates, query)
 
 	// log basic info about filtering results
 	log.Info().
-		Int("All rule states", len(server.ruleContentStatusMap)).
+		Int("All rule states", len(allStates)).
 		Int("Filtered rule states", len(ruleContentStatusMap)).
 		Msg("Rule content states filtering results")
 
@@ -101,22 +219,73 @@ func (server *HTTPServer) ruleContentStates(writer http.ResponseWriter, request
 	}
 }
 
-// getStaticContent handler returns all the parsed rules' content
+// getStaticContent handler returns all the parsed rules' content. Content
+// of older snapshot is returned when version is specified.
 func (server *HTTPServer) getStaticContent(writer http.ResponseWriter, request *http.Request) {
+	if version := request.URL.Query().Get(versionParam); version != "" {
+		server.getVersionedContent(writer, version)
+		return
+	}
+
+	// the mutex is not held while the response is written, so slow
+	// clients block neither other requests nor content reloads
+	encodedContent, err := server.encodedStaticContent()
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot encode rules static content")
+		handleServerError(err)
+		return
+	}
+
+	err = responses.Send(http.StatusOK, writer, encodedContent)
+	if err != nil {
+		log.Error().Err(err)
+		handleServerError(err)
+		return
+	}
+}
+
+// encodedStaticContent method returns gob encoded current content. The
+// content is encoded once for each content version.
+func (server *HTTPServer) encodedStaticContent() ([]byte, error) {
+	server.mutex.RLock()
+	encodedContent := server.encodedContent
+	server.mutex.RUnlock()
+
+	if encodedContent != nil {
+		return encodedContent, nil
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	// the content might have been encoded by another request in the meantime
 	if server.encodedContent == nil {
 		buffer := new(bytes.Buffer)
-		encoder := gob.NewEncoder(buffer)
-
-		if err := encoder.Encode(server.Content); err != nil {
-			log.Error().Err(err).Msg("Cannot encode rules static content")
-			handleServerError(err)
-			return
+		if err := gob.NewEncoder(buffer).Encode(server.Content); err != nil {
+			return nil, err
 		}
-
 		server.encodedContent = buffer.Bytes()
 	}
+	return server.encodedContent, nil
+}
+
+// contentManifest handler returns manifest the served content has been
+// verified against together with the key it is signed with
+func (server *HTTPServer) contentManifest(writer http.ResponseWriter, _ *http.Request) {
+	verified := server.Manifest()
+	if verified == nil {
+		logResponseError(responses.SendNotFound(writer, "Content manifest is not verified"))
+		return
+	}
+
+	response := responses.BuildOkResponse()
+	response["manifest"] = verified.Manifest
+	response["signer"] = verified.Signer
+	response["key_fingerprint"] = verified.KeyFingerprint
+	response["digest"] = verified.Digest
+	response["verified_at"] = verified.VerifiedAt
 
-	err := responses.Send(http.StatusOK, writer, server.encodedContent)
+	err := responses.SendOK(writer, response)
 	if err != nil {
 		log.Error().Err(err)
 		handleServerError(err)
@@ -0,0 +1,106 @@
+/*
+Copyright © 2020, 2021, 2022, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// Reasons reported by health check endpoints
+const (
+	reasonRunning           = "service is running"
+	reasonReady             = "content is loaded"
+	reasonContentNotLoaded  = "content is not loaded"
+	reasonShuttingDown      = "service is shutting down"
+	reasonTooManyInvalidFmt = "%d of %d rules failed to parse, ratio %.2f exceeds threshold %.2f"
+)
+
+// readinessState returns whether the service is ready to serve content
+// together with the reason and number of all and invalid rules
+func (server *HTTPServer) readinessState() (ready bool, reason string, rules, invalidRules int) {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	rules = len(server.ruleContentStatusMap)
+	for _, status := range server.ruleContentStatusMap {
+		if !status.Loaded {
+			invalidRules++
+		}
+	}
+
+	if server.IsDraining() {
+		return false, reasonShuttingDown, rules, invalidRules
+	}
+
+	if !server.contentLoaded {
+		return false, reasonContentNotLoaded, rules, invalidRules
+	}
+
+	threshold := server.Config.MaxInvalidRulesRatio
+	if threshold > 0 && rules > 0 {
+		ratio := float64(invalidRules) / float64(rules)
+		if ratio > threshold {
+			reason = fmt.Sprintf(reasonTooManyInvalidFmt, invalidRules, rules, ratio, threshold)
+			return false, reason, rules, invalidRules
+		}
+	}
+
+	return true, reasonReady, rules, invalidRules
+}
+
+// liveness handler reports that the service is running
+func (server *HTTPServer) liveness(writer http.ResponseWriter, _ *http.Request) {
+	response := responses.BuildOkResponse()
+	response["live"] = true
+	response["reason"] = reasonRunning
+
+	err := responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+		handleServerError(err)
+		return
+	}
+}
+
+// readiness handler reports whether the service is ready to serve content
+func (server *HTTPServer) readiness(writer http.ResponseWriter, _ *http.Request) {
+	ready, reason, rules, invalidRules := server.readinessState()
+
+	statusCode := http.StatusOK
+	response := responses.BuildOkResponse()
+	if !ready {
+		statusCode = http.StatusServiceUnavailable
+		response = responses.BuildResponse("not ready")
+		log.Warn().Str("reason", reason).Msg("Service is not ready")
+	}
+
+	response["ready"] = ready
+	response["reason"] = reason
+	response["rules"] = rules
+	response["invalid_rules"] = invalidRules
+
+	err := responses.Send(statusCode, writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+		handleServerError(err)
+		return
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+)
+
+// TestServeLiveness checks the REST API server behaviour for liveness endpoint
+func TestServeLiveness(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "health/live",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "live": true, "reason": "service is running"}`,
+	})
+}
+
+// TestServeReadiness checks the REST API server behaviour for readiness endpoint
+func TestServeReadiness(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "health/ready",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"ready": true,
+			"reason": "content is loaded",
+			"rules": 4,
+			"invalid_rules": 2
+		}`,
+	})
+}
+
+// TestServeReadinessTooManyInvalidRules checks that the service is not ready
+// when too many rules failed to parse
+func TestServeReadinessTooManyInvalidRules(t *testing.T) {
+	thresholdConfig := config
+	thresholdConfig.MaxInvalidRulesRatio = 0.25
+
+	helpers.AssertAPIRequest(t, &thresholdConfig, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "health/ready",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body: `{
+			"status": "not ready",
+			"ready": false,
+			"reason": "2 of 4 rules failed to parse, ratio 0.50 exceeds threshold 0.25",
+			"rules": 4,
+			"invalid_rules": 2
+		}`,
+	})
+}
+
+// checkReadiness sends request to readiness endpoint and checks the response code
+func checkReadiness(t *testing.T, s *server.HTTPServer, expectedStatusCode int) {
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+server.ReadinessEndpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+
+	response := helpers.ExecuteRequest(s, req).Result()
+	checkResponseCode(t, expectedStatusCode, response.StatusCode)
+}
+
+// TestServeReadinessContentNotLoaded checks that the service is not ready
+// until content is loaded
+func TestServeReadinessContentNotLoaded(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+	checkReadiness(t, s, http.StatusServiceUnavailable)
+
+	s.SetContent(content.RuleContentDirectory{}, map[string]types.RuleContentStatus{})
+	checkReadiness(t, s, http.StatusOK)
+}
+
+// TestServeReadinessDraining checks that the service is not ready when it is
+// going to be stopped
+func TestServeReadinessDraining(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, map[string]types.RuleContentStatus{})
+	assert.False(t, s.IsDraining())
+	checkReadiness(t, s, http.StatusOK)
+
+	s.StartDraining()
+	assert.True(t, s.IsDraining())
+	checkReadiness(t, s, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2020, 2021, 2022, 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// MetricsServer is HTTP server that provides just Prometheus metrics. It is
+// used when metrics need to be served on different address than REST API,
+// for example when the service is deployed by Clowder.
+type MetricsServer struct {
+	Address string
+	Path    string
+	Serv    *http.Server
+}
+
+// NewMetricsServer constructs new metrics server
+func NewMetricsServer(address, path string) *MetricsServer {
+	router := http.NewServeMux()
+	router.Handle(path, promhttp.Handler())
+
+	// HTTP server is constructed in advance, so it can be stopped even
+	// before it is started
+	return &MetricsServer{
+		Address: address,
+		Path:    path,
+		Serv: &http.Server{
+			Addr:              address,
+			Handler:           router,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// Start method starts metrics server
+func (server *MetricsServer) Start() error {
+	log.Info().Str(addressAttribute, server.Address).Str("path", server.Path).Msg("Starting metrics server")
+
+	err := server.Serv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		log.Error().Err(err).Msg("Unable to start metrics server")
+		return err
+	}
+
+	return nil
+}
+
+// Stop method stops metrics server's execution
+func (server *MetricsServer) Stop(ctx context.Context) error {
+	if server.Serv == nil {
+		return nil
+	}
+	return server.Serv.Shutdown(ctx)
+}
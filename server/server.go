@@ -22,7 +22,8 @@ package server
 import (
 	"context"
 	"net/http"
-	"time"
+	"sync"
+	"sync/atomic"
 
 	httputils "github.com/RedHatInsights/insights-operator-utils/http"
 	types "github.com/RedHatInsights/insights-results-types"
@@ -31,10 +32,18 @@ import (
 
 	"github.com/RedHatInsights/insights-content-service/content"
 	"github.com/RedHatInsights/insights-content-service/groups"
+	"github.com/RedHatInsights/insights-content-service/manifest"
+	"github.com/RedHatInsights/insights-content-service/webhooks"
 )
 
 const (
 	addressAttribute = "address"
+
+	// streamingRouteName is name of routes that stream responses
+	streamingRouteName = "streaming"
+
+	// rulesVersionInfoParam is key of rules version in /info response
+	rulesVersionInfoParam = "OCPRulesVersion"
 )
 
 // HTTPServer in an implementation of Server interface
@@ -45,36 +54,182 @@ type HTTPServer struct {
 	Content    content.RuleContentDirectory
 	Serv       *http.Server
 
+	// GroupsFindings contains warnings found in groups configuration
+	GroupsFindings groups.Findings
+
+	// RulesVersion is version of rules recorded in content snapshots
+	RulesVersion string
+
+	// ContentLoader is used to read rule content again when reload is
+	// requested via admin endpoint
+	ContentLoader ContentLoader
+
+	// RulesVersionLoader is optional function returning version of rules
+	// provided by ContentLoader, the version replaces RulesVersion when the
+	// content is reloaded
+	RulesVersionLoader func() string
+
+	// ManifestLoader is optional function returning manifest the content
+	// provided by ContentLoader has been verified against, the manifest
+	// replaces the current one when the content is reloaded
+	ManifestLoader func() *manifest.Verified
+
+	// ContentInstalled is optional function called when content provided
+	// by ContentLoader has been installed, content refused by reload is
+	// not reported
+	ContentInstalled func()
+
+	// Webhooks is dispatcher of webhook notifications, its deliveries are
+	// provided via admin endpoint
+	Webhooks *webhooks.Dispatcher
+
 	encodedContent       []byte
 	groupsList           []groups.Group
+	tagsList             []groups.Tag
+	snapshots            []*Snapshot
+	snapshotListeners    []SnapshotListener
+	events               *eventBroker
 	ruleContentStatusMap map[string]types.RuleContentStatus
+	manifest             *manifest.Verified
+
+	// parsedContent and parsedStatusMap contain content including
+	// disabled rules, so the rules can be enabled again
+	parsedContent   content.RuleContentDirectory
+	parsedStatusMap map[string]types.RuleContentStatus
+	disabledRules   map[string]bool
+	corsOrigins     map[string]bool
+
+	// mutex guards data that can be replaced while the server is running
+	mutex sync.RWMutex
+
+	// draining is set when the server is going to be stopped
+	draining atomic.Bool
+
+	// contentLoaded is set when rule content has been provided
+	contentLoaded bool
+
+	// reloadMutex serializes content reloads requested via admin endpoint
+	reloadMutex sync.Mutex
 }
 
 // New constructs new implementation of Server interface
 func New(config Configuration, groupsMap map[string]groups.Group,
 	contentDir content.RuleContentDirectory,
 	ruleContentStatusMap map[string]types.RuleContentStatus) *HTTPServer {
-	return &HTTPServer{
-		Config:               config,
-		Groups:               groupsMap,
-		Content:              contentDir,
-		ruleContentStatusMap: ruleContentStatusMap,
-		InfoParams:           make(map[string]string),
+	server := &HTTPServer{
+		Config:          config,
+		Groups:          groupsMap,
+		parsedContent:   contentDir,
+		parsedStatusMap: ruleContentStatusMap,
+		disabledRules:   disabledRulesSet(config.DisabledRules),
+		corsOrigins:     corsOriginsSet(config.CORSAllowedOrigins),
+		InfoParams:      make(map[string]string),
+		events:          newEventBroker(),
+		// status map is always provided by content parser
+		contentLoaded: ruleContentStatusMap != nil,
+	}
+	server.Content, server.ruleContentStatusMap = server.withoutDisabledRules(contentDir, ruleContentStatusMap)
+
+	// HTTP server is constructed in advance, so it can be stopped even
+	// before it is started
+	server.Serv = newHTTPServer(config, nil)
+
+	return server
+}
+
+// SetContent method replaces rule content and status of all parsed rules
+// while the server is running
+func (server *HTTPServer) SetContent(contentDir content.RuleContentDirectory,
+	ruleContentStatusMap map[string]types.RuleContentStatus) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.setContent(contentDir, ruleContentStatusMap, server.RulesVersion)
+}
+
+// SetVersionedContent method replaces rule content together with version of
+// rules the content has been read from. The version is recorded in the new
+// snapshot and provided by /info endpoint as OCPRulesVersion.
+func (server *HTTPServer) SetVersionedContent(contentDir content.RuleContentDirectory,
+	ruleContentStatusMap map[string]types.RuleContentStatus, rulesVersion string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.setContent(contentDir, ruleContentStatusMap, rulesVersion)
+}
+
+// SetManifest method sets manifest the served content has been verified
+// against
+func (server *HTTPServer) SetManifest(verified *manifest.Verified) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.manifest = verified
+}
+
+// Manifest method returns manifest the served content has been verified
+// against, nil is returned when the content is not verified
+func (server *HTTPServer) Manifest() *manifest.Verified {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	return server.manifest
+}
+
+// setContent replaces rule content, the mutex needs to be locked
+func (server *HTTPServer) setContent(contentDir content.RuleContentDirectory,
+	ruleContentStatusMap map[string]types.RuleContentStatus, rulesVersion string) {
+	// disabled rules are not served, but they are kept to be enabled later
+	server.parsedContent = contentDir
+	server.parsedStatusMap = ruleContentStatusMap
+	contentDir, ruleContentStatusMap = server.withoutDisabledRules(contentDir, ruleContentStatusMap)
+
+	// the original content is kept as a snapshot
+	server.ensureSnapshot()
+	var previous *Snapshot
+	if len(server.snapshots) > 0 {
+		previous = server.snapshots[0]
 	}
+	diff := content.Compare(server.Content, server.ruleContentStatusMap, contentDir, ruleContentStatusMap)
+
+	server.RulesVersion = rulesVersion
+	if server.InfoParams != nil {
+		server.InfoParams[rulesVersionInfoParam] = rulesVersion
+	}
+
+	server.Content = contentDir
+	server.ruleContentStatusMap = ruleContentStatusMap
+	server.contentLoaded = true
+	server.addSnapshot(contentDir, ruleContentStatusMap)
+
+	// subscribers are notified about the new snapshot
+	server.events.publish(diffEvent(EventSnapshot, server.currentHash(), diff))
+	server.notifySnapshotListeners(previous)
+
+	// cached data needs to be computed again
+	server.encodedContent = nil
+	server.tagsList = nil
+}
+
+// SetGroups method replaces groups and warnings found in groups
+// configuration while the server is running
+func (server *HTTPServer) SetGroups(groupsMap map[string]groups.Group, findings groups.Findings) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.Groups = groupsMap
+	server.GroupsFindings = findings
+
+	// cached data needs to be computed again
+	server.groupsList = nil
+	server.tagsList = nil
 }
 
 // Start method starts server
 func (server *HTTPServer) Start() error {
 	address := server.Config.Address
 	log.Info().Str(addressAttribute, address).Msg("Starting HTTP server")
-	router := server.Initialize()
-	server.Serv = &http.Server{
-		Addr:              address,
-		Handler:           router,
-		ReadTimeout:       1 * time.Minute,
-		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      30 * time.Second,
-	}
+	server.Serv.Handler = server.Initialize()
 
 	err := server.Serv.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
@@ -87,18 +242,56 @@ func (server *HTTPServer) Start() error {
 
 // Stop method stops server's execution
 func (server *HTTPServer) Stop(ctx context.Context) error {
+	if server.Serv == nil {
+		return nil
+	}
+
+	// shutdown does not cancel contexts of active requests, so streams of
+	// events need to be finished explicitly, otherwise shutdown waits for
+	// them until it times out
+	server.events.closeAll()
+
 	return server.Serv.Shutdown(ctx)
 }
 
+// StartDraining method marks the server as going to be stopped, so it is
+// not reported as ready to accept new requests anymore
+func (server *HTTPServer) StartDraining() {
+	server.draining.Store(true)
+}
+
+// IsDraining method returns true if the server is going to be stopped
+func (server *HTTPServer) IsDraining() bool {
+	return server.draining.Load()
+}
+
+// logRequest middleware logs requests and updates API metrics. Responses of
+// streaming routes are not wrapped, because the wrapper used by the logging
+// middleware does not support flushing.
+func logRequest(nextHandler http.Handler) http.Handler {
+	logging := httputils.LogRequest(nextHandler)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if route := mux.CurrentRoute(request); route != nil && route.GetName() == streamingRouteName {
+			log.Info().Msgf("Streaming request received - URI: %s", request.RequestURI)
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+		logging.ServeHTTP(writer, request)
+	})
+}
+
 // Initialize method performs the server initialization
 func (server *HTTPServer) Initialize() http.Handler {
 	log.Info().Str(addressAttribute, server.Config.Address).Msg("Initializing HTTP server at")
 
 	router := mux.NewRouter().StrictSlash(true)
-	router.Use(httputils.LogRequest)
+	router.Use(logRequest)
 
 	server.addEndpointsToRouter(router)
 	log.Info().Msg("Server has been initiliazed")
 
-	return router
+	// preflight requests are answered before routing, because routes
+	// don't accept OPTIONS method
+	return server.cors(router)
 }
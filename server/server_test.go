@@ -20,12 +20,15 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/manifest"
 	"github.com/RedHatInsights/insights-content-service/server"
 	"github.com/RedHatInsights/insights-content-service/tests/helpers"
 )
@@ -65,14 +68,6 @@ func TestServerStartHTTP(t *testing.T) {
 		}, nil, contentDir, nil)
 
 		go func() {
-			for {
-				if s.Serv != nil {
-					break
-				}
-
-				time.Sleep(500 * time.Millisecond)
-			}
-
 			// doing some request to be sure server started successfully
 			req, err := http.NewRequest(http.MethodGet, config.APIPrefix, http.NoBody)
 			helpers.FailOnError(t, err)
@@ -219,3 +214,246 @@ func TestServerContent(t *testing.T) {
 		StatusCode: http.StatusOK,
 	})
 }
+
+// TestServeListOfTags checks the REST API server behaviour for tags listing endpoint
+func TestServeListOfTags(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "tags",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"tags": [
+				{"name": "tag1", "error_keys": 0, "groups": ["group name: foo"], "orphaned": true, "orphan_reason": "unused"},
+				{"name": "tag2", "error_keys": 0, "groups": ["group name: foo"], "orphaned": true, "orphan_reason": "unused"},
+				{"name": "tag3", "error_keys": 0, "groups": ["group name: bar"], "orphaned": true, "orphan_reason": "unused"},
+				{"name": "tag4", "error_keys": 0, "groups": ["group name: bar"], "orphaned": true, "orphan_reason": "unused"}
+			]
+		}`,
+	})
+}
+
+// TestServeListOfGroupsTree checks the REST API server behaviour for group listing endpoint with tree format
+func TestServeListOfGroupsTree(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "groups?format=tree",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"groups": [
+				{"title": "group name: bar", "description": "group description: bar", "tags": ["tag3", "tag4"], "children": []},
+				{"title": "group name: foo", "description": "group description: foo", "tags": ["tag1", "tag2"], "children": []}
+			]
+		}`,
+	})
+}
+
+// TestServeListOfGroupsUnknownFormat checks the REST API server behaviour for group listing endpoint with unknown format
+func TestServeListOfGroupsUnknownFormat(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "groups?format=foobar",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+// TestServeGroupsStatus checks the REST API server behaviour for groups status endpoint
+func TestServeGroupsStatus(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: "groups/status",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "findings": []}`,
+	})
+}
+
+// TestServerStopNotStarted checks that server that has not been started can be stopped
+func TestServerStopNotStarted(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+	assert.NoError(t, s.Stop(context.Background()))
+}
+
+// TestServerStartStopped checks that server stopped before it has been
+// started does not start serving requests
+func TestServerStartStopped(t *testing.T) {
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		s := server.New(server.Configuration{
+			// will use any free port
+			Address:   ":0",
+			APIPrefix: config.APIPrefix,
+		}, nil, content.RuleContentDirectory{}, nil)
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, s.Start())
+	}, 5*time.Second)
+}
+
+// TestMetricsServerStartStopped checks that metrics server stopped before it
+// has been started does not start serving requests
+func TestMetricsServerStartStopped(t *testing.T) {
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		s := server.NewMetricsServer(":0", "/metrics")
+		assert.NoError(t, s.Stop(context.Background()))
+		assert.NoError(t, s.Start())
+	}, 5*time.Second)
+}
+
+// TestNewHTTPServerDefaults checks that default timeouts and limits are used
+// when they are not configured
+func TestNewHTTPServerDefaults(t *testing.T) {
+	serv := server.NewHTTPServer(config, http.NewServeMux())
+
+	assert.Equal(t, config.Address, serv.Addr)
+	assert.Equal(t, server.DefaultReadTimeout, serv.ReadTimeout)
+	assert.Equal(t, server.DefaultReadHeaderTimeout, serv.ReadHeaderTimeout)
+	assert.Equal(t, server.DefaultWriteTimeout, serv.WriteTimeout)
+	assert.Equal(t, time.Duration(0), serv.IdleTimeout)
+	assert.Equal(t, server.DefaultMaxHeaderBytes, serv.MaxHeaderBytes)
+}
+
+// TestNewHTTPServerConfigured checks that configured timeouts and limits are used
+func TestNewHTTPServerConfigured(t *testing.T) {
+	cfg := config
+	cfg.ReadTimeout = 2 * time.Minute
+	cfg.ReadHeaderTimeout = 10 * time.Second
+	cfg.WriteTimeout = 5 * time.Minute
+	cfg.IdleTimeout = 3 * time.Minute
+	cfg.MaxHeaderBytes = 4096
+	cfg.DisableKeepAlives = true
+
+	serv := server.NewHTTPServer(cfg, http.NewServeMux())
+
+	assert.Equal(t, 2*time.Minute, serv.ReadTimeout)
+	assert.Equal(t, 10*time.Second, serv.ReadHeaderTimeout)
+	assert.Equal(t, 5*time.Minute, serv.WriteTimeout)
+	assert.Equal(t, 3*time.Minute, serv.IdleTimeout)
+	assert.Equal(t, 4096, serv.MaxHeaderBytes)
+}
+
+// TestServeManifestNotVerified checks the manifest endpoint when content is
+// not verified
+func TestServeManifestNotVerified(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ManifestEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+// TestServeManifest checks that verified manifest is returned together with
+// its signer
+func TestServeManifest(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+	s.SetManifest(&manifest.Verified{
+		Manifest: manifest.Manifest{
+			Version:   "1.0.0",
+			CreatedAt: time.Date(2021, 3, 18, 10, 0, 0, 0, time.UTC),
+			Files:     map[string]string{"config.yaml": "0123"},
+		},
+		Signer:         "rules-team",
+		KeyFingerprint: "89abcdef01234567",
+		Digest:         "4567",
+		VerifiedAt:     time.Date(2021, 3, 18, 11, 0, 0, 0, time.UTC),
+	})
+
+	response := sendGet(t, s, server.ManifestEndpoint)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{
+		"status": "ok",
+		"manifest": {
+			"version": "1.0.0",
+			"created_at": "2021-03-18T10:00:00Z",
+			"files": {"config.yaml": "0123"}
+		},
+		"signer": "rules-team",
+		"key_fingerprint": "89abcdef01234567",
+		"digest": "4567",
+		"verified_at": "2021-03-18T11:00:00Z"
+	}`, response.Body)
+}
+
+// blockingWriter is response writer that blocks until it is released, it
+// simulates slow client
+type blockingWriter struct {
+	header  http.Header
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{
+		header:  http.Header{},
+		writing: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (writer *blockingWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *blockingWriter) WriteHeader(int) {}
+
+func (writer *blockingWriter) Write(data []byte) (int, error) {
+	writer.once.Do(func() {
+		close(writer.writing)
+	})
+	<-writer.release
+	return len(data), nil
+}
+
+// assertNotBlockedBySlowClient checks that content can be replaced while
+// response of provided endpoint is written to slow client
+func assertNotBlockedBySlowClient(t *testing.T, s *server.HTTPServer, endpoint string) {
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+endpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+
+	writer := newBlockingWriter()
+	served := make(chan struct{})
+	go func() {
+		s.Initialize().ServeHTTP(writer, req)
+		close(served)
+	}()
+	<-writer.writing
+
+	replaced := make(chan struct{})
+	go func() {
+		s.SetContent(rulesContent("rule1", "rule2"))
+		close(replaced)
+	}()
+
+	select {
+	case <-replaced:
+	case <-time.After(time.Second):
+		t.Errorf("content can't be replaced while response of %s endpoint is written", endpoint)
+	}
+
+	close(writer.release)
+	<-served
+	<-replaced
+}
+
+// TestSlowClientDoesNotBlockReload checks that no endpoint holds the server
+// mutex while writing its response
+func TestSlowClientDoesNotBlockReload(t *testing.T) {
+	for _, endpoint := range []string{
+		server.AllContentEndpoint,
+		server.TagsEndpoint,
+		server.GroupsEndpoint,
+		server.GroupsEndpoint + "?format=tree",
+		server.GroupsStatusEndpoint,
+		server.InfoEndpoint,
+		server.StatusEndpoint,
+	} {
+		t.Run(endpoint, func(t *testing.T) {
+			contentDir, statusMap := rulesContent("rule1")
+			assertNotBlockedBySlowClient(t, server.New(config, nil, contentDir, statusMap), endpoint)
+		})
+	}
+}
@@ -0,0 +1,370 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	types "github.com/RedHatInsights/insights-results-types"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+)
+
+const (
+	// DefaultMaxSnapshots is number of content snapshots kept when it is
+	// not configured
+	DefaultMaxSnapshots = 5
+
+	// snapshotIDLength is number of hash characters used as snapshot ID
+	snapshotIDLength = 12
+
+	// unknownRulesVersion is placeholder used as rules version when the
+	// version is not provided at build time
+	unknownRulesVersion = "*not set*"
+
+	versionParam = "version"
+	sinceParam   = "since"
+	ruleParam    = "rule"
+)
+
+// Snapshot describes one version of rule content kept by the server
+type Snapshot struct {
+	// ID is short form of the content hash
+	ID string `json:"id"`
+	// Hash is SHA-256 hash of the rule content
+	Hash string `json:"hash"`
+	// RulesVersion is version of rules the content has been read from
+	RulesVersion string `json:"rules_version"`
+	// LoadedAt is time when the content has been loaded
+	LoadedAt time.Time `json:"loaded_at"`
+	// Rules is number of rules in the content
+	Rules int `json:"rules"`
+	// Current is set for the snapshot that is served by default
+	Current bool `json:"current"`
+
+	content   content.RuleContentDirectory
+	statusMap map[string]types.RuleContentStatus
+
+	encoded *snapshotEncoding
+}
+
+// snapshotEncoding contains gob encoded content of snapshot, the content is
+// encoded once when it is requested for the first time
+type snapshotEncoding struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// SnapshotChange describes installation of new content snapshot
+type SnapshotChange struct {
+	// Previous is metadata of the snapshot served before, it is nil when
+	// the previous snapshot is not known
+	Previous *Snapshot
+	// Current is metadata of the installed snapshot
+	Current Snapshot
+	// PreviousContent is rule content of the previous snapshot
+	PreviousContent content.RuleContentDirectory
+	// CurrentContent is rule content of the installed snapshot
+	CurrentContent content.RuleContentDirectory
+}
+
+// SnapshotListener is a function called when new content snapshot is
+// installed. Listeners are called synchronously, so they must not block.
+type SnapshotListener func(SnapshotChange)
+
+// newSnapshot constructs snapshot for provided rule content
+func newSnapshot(contentDir content.RuleContentDirectory,
+	statusMap map[string]types.RuleContentStatus, rulesVersion string) (*Snapshot, error) {
+	hash, err := content.Hash(contentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		ID:           hash[:snapshotIDLength],
+		Hash:         hash,
+		RulesVersion: rulesVersion,
+		LoadedAt:     time.Now().UTC(),
+		Rules:        len(contentDir.Rules),
+		content:      contentDir,
+		statusMap:    statusMap,
+		encoded:      &snapshotEncoding{},
+	}, nil
+}
+
+// encode returns gob encoded content of the snapshot. It can be called
+// without the server mutex locked.
+func (snapshot *Snapshot) encode() ([]byte, error) {
+	encoded := snapshot.encoded
+	encoded.once.Do(func() {
+		buffer := new(bytes.Buffer)
+		encoded.err = gob.NewEncoder(buffer).Encode(snapshot.content)
+		encoded.data = buffer.Bytes()
+	})
+	return encoded.data, encoded.err
+}
+
+// metadata returns copy of the snapshot without its content
+func (snapshot *Snapshot) metadata() Snapshot {
+	return Snapshot{
+		ID:           snapshot.ID,
+		Hash:         snapshot.Hash,
+		RulesVersion: snapshot.RulesVersion,
+		LoadedAt:     snapshot.LoadedAt,
+		Rules:        snapshot.Rules,
+		Current:      snapshot.Current,
+	}
+}
+
+// matches returns true if the snapshot can be identified by provided
+// version, which is either snapshot ID, full hash or rules version. Rules
+// version is not used when it is unknown, because all snapshots would match.
+func (snapshot *Snapshot) matches(version string) bool {
+	if version == snapshot.ID || version == snapshot.Hash {
+		return true
+	}
+	return snapshot.RulesVersion != "" && snapshot.RulesVersion != unknownRulesVersion &&
+		version == snapshot.RulesVersion
+}
+
+// maxSnapshots returns configured number of snapshots to keep
+func (server *HTTPServer) maxSnapshots() int {
+	if server.Config.MaxSnapshots <= 0 {
+		return DefaultMaxSnapshots
+	}
+	return server.Config.MaxSnapshots
+}
+
+// ensureSnapshot records snapshot of the current content if no snapshot
+// exists yet. Snapshots are created lazily, so the rules version set after
+// the server construction is used. The mutex needs to be locked.
+func (server *HTTPServer) ensureSnapshot() {
+	if len(server.snapshots) > 0 || !server.contentLoaded {
+		return
+	}
+	server.addSnapshot(server.Content, server.ruleContentStatusMap)
+}
+
+// addSnapshot records snapshot of provided content as the current one and
+// drops the oldest snapshots if there are too many of them. Snapshot with
+// the same content hash is replaced. The mutex needs to be locked.
+func (server *HTTPServer) addSnapshot(contentDir content.RuleContentDirectory,
+	statusMap map[string]types.RuleContentStatus) {
+	snapshot, err := newSnapshot(contentDir, statusMap, server.RulesVersion)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to compute content hash, snapshot is not recorded")
+		return
+	}
+
+	snapshots := make([]*Snapshot, 0, len(server.snapshots)+1)
+	snapshots = append(snapshots, snapshot)
+	for _, previous := range server.snapshots {
+		if previous.Hash != snapshot.Hash {
+			previous.Current = false
+			snapshots = append(snapshots, previous)
+		}
+	}
+	snapshot.Current = true
+
+	if len(snapshots) > server.maxSnapshots() {
+		snapshots = snapshots[:server.maxSnapshots()]
+	}
+
+	server.snapshots = snapshots
+}
+
+// snapshotList returns all snapshots, the newest first. The list is
+// replaced, not modified, when new snapshot is recorded and content of
+// recorded snapshots never changes, so the list can be used after the mutex
+// is unlocked. The mutex must not be locked.
+func (server *HTTPServer) snapshotList() []*Snapshot {
+	server.mutex.RLock()
+	snapshots := server.snapshots
+	contentLoaded := server.contentLoaded
+	server.mutex.RUnlock()
+
+	if len(snapshots) > 0 || !contentLoaded {
+		return snapshots
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.ensureSnapshot()
+	return server.snapshots
+}
+
+// findSnapshot function returns the newest snapshot identified by provided
+// version or nil when there's no such snapshot
+func findSnapshot(snapshots []*Snapshot, version string) *Snapshot {
+	for _, snapshot := range snapshots {
+		if snapshot.matches(version) {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// Snapshots method returns metadata of all content snapshots kept by the
+// server, the newest snapshot first
+func (server *HTTPServer) Snapshots() []Snapshot {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.ensureSnapshot()
+
+	snapshots := make([]Snapshot, len(server.snapshots))
+	for i, snapshot := range server.snapshots {
+		snapshots[i] = snapshot.metadata()
+	}
+	return snapshots
+}
+
+// AddSnapshotListener method registers function called for each installed
+// content snapshot. When content is already loaded, the listener is called
+// immediately with the current snapshot and unknown previous snapshot.
+func (server *HTTPServer) AddSnapshotListener(listener SnapshotListener) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.snapshotListeners = append(server.snapshotListeners, listener)
+
+	server.ensureSnapshot()
+	if len(server.snapshots) > 0 {
+		listener(SnapshotChange{
+			Current:        server.snapshots[0].metadata(),
+			CurrentContent: server.snapshots[0].content,
+		})
+	}
+}
+
+// notifySnapshotListeners calls all snapshot listeners. The mutex needs to
+// be locked.
+func (server *HTTPServer) notifySnapshotListeners(previous *Snapshot) {
+	if len(server.snapshotListeners) == 0 || len(server.snapshots) == 0 {
+		return
+	}
+
+	change := SnapshotChange{
+		Current:        server.snapshots[0].metadata(),
+		CurrentContent: server.snapshots[0].content,
+	}
+	if previous != nil {
+		previousMetadata := previous.metadata()
+		previousMetadata.Current = false
+		change.Previous = &previousMetadata
+		change.PreviousContent = previous.content
+	}
+
+	for _, listener := range server.snapshotListeners {
+		listener(change)
+	}
+}
+
+// listOfVersions handler returns metadata of all content snapshots
+func (server *HTTPServer) listOfVersions(writer http.ResponseWriter, _ *http.Request) {
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("versions", server.Snapshots()))
+	if err != nil {
+		log.Error().Err(err)
+		handleServerError(err)
+		return
+	}
+}
+
+// getVersionedContent handler returns gob encoded content of the snapshot
+// selected by version query parameter
+func (server *HTTPServer) getVersionedContent(writer http.ResponseWriter, version string) {
+	snapshot := findSnapshot(server.snapshotList(), version)
+	if snapshot == nil {
+		logResponseError(responses.SendNotFound(writer, "Unknown content version: "+version))
+		return
+	}
+
+	encodedContent, err := snapshot.encode()
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot encode rules static content")
+		handleServerError(err)
+		return
+	}
+
+	logResponseError(responses.Send(http.StatusOK, writer, encodedContent))
+}
+
+// getVersionedRule handler returns content of one rule from selected
+// content snapshot
+func (server *HTTPServer) getVersionedRule(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	version := strings.TrimSpace(vars[versionParam])
+	ruleName := strings.TrimSpace(vars[ruleParam])
+
+	snapshot := findSnapshot(server.snapshotList(), version)
+	if snapshot == nil {
+		logResponseError(responses.SendNotFound(writer, "Unknown content version: "+version))
+		return
+	}
+
+	ruleContent, found := snapshot.content.Rules[ruleName]
+	if !found {
+		logResponseError(responses.SendNotFound(writer, "Unknown rule: "+ruleName))
+		return
+	}
+
+	logResponseError(responses.SendOK(writer, responses.BuildOkResponseWithData("rule", ruleContent)))
+}
+
+// contentChanges handler returns structured difference between the content
+// snapshot selected by since query parameter and the current content. When
+// the selected snapshot is not kept anymore, the whole current content is
+// returned as added rules.
+func (server *HTTPServer) contentChanges(writer http.ResponseWriter, request *http.Request) {
+	since := strings.TrimSpace(request.URL.Query().Get(sinceParam))
+	if since == "" {
+		logResponseError(responses.SendBadRequest(writer, "Missing parameter: "+sinceParam))
+		return
+	}
+
+	snapshots := server.snapshotList()
+	if len(snapshots) == 0 {
+		logResponseError(responses.SendServiceUnavailable(writer, "Content is not loaded"))
+		return
+	}
+	current := snapshots[0]
+
+	baseContent := content.RuleContentDirectory{}
+	baseID := ""
+	base := findSnapshot(snapshots, since)
+	if base != nil {
+		baseContent = base.content
+		baseID = base.ID
+	}
+
+	response := responses.BuildOkResponseWithData("changes", content.DetailedChanges(baseContent, current.content))
+	response["base"] = baseID
+	response["current"] = current.ID
+	response["full"] = base == nil
+
+	logResponseError(responses.SendOK(writer, response))
+}
@@ -0,0 +1,289 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server_test
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/server"
+	"github.com/RedHatInsights/insights-content-service/tests/helpers"
+)
+
+// sendGet sends GET request to provided endpoint of the server
+func sendGet(t *testing.T, s *server.HTTPServer, endpoint string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, config.APIPrefix+endpoint, http.NoBody)
+	helpers.FailOnError(t, err)
+
+	return helpers.ExecuteRequest(s, req).Result()
+}
+
+// TestSnapshots checks that older content is kept as snapshots
+func TestSnapshots(t *testing.T) {
+	cfg := config
+	cfg.MaxSnapshots = 2
+
+	firstContent, firstStatus := rulesContent("rule1")
+	s := server.New(cfg, nil, firstContent, firstStatus)
+	s.RulesVersion = "1.0.0"
+
+	snapshots := s.Snapshots()
+	assert.Len(t, snapshots, 1)
+	assert.True(t, snapshots[0].Current)
+	assert.Equal(t, "1.0.0", snapshots[0].RulesVersion)
+	assert.Equal(t, 1, snapshots[0].Rules)
+	assert.Equal(t, snapshots[0].Hash[:len(snapshots[0].ID)], snapshots[0].ID)
+	firstID := snapshots[0].ID
+
+	s.RulesVersion = "2.0.0"
+	secondContent, secondStatus := rulesContent("rule1", "rule2")
+	s.SetContent(secondContent, secondStatus)
+
+	snapshots = s.Snapshots()
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "2.0.0", snapshots[0].RulesVersion)
+	assert.True(t, snapshots[0].Current)
+	assert.Equal(t, firstID, snapshots[1].ID)
+	assert.False(t, snapshots[1].Current)
+
+	// the oldest snapshot is dropped
+	s.RulesVersion = "3.0.0"
+	thirdContent, thirdStatus := rulesContent("rule3")
+	s.SetContent(thirdContent, thirdStatus)
+
+	snapshots = s.Snapshots()
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "3.0.0", snapshots[0].RulesVersion)
+	assert.Equal(t, "2.0.0", snapshots[1].RulesVersion)
+}
+
+// TestSnapshotsSameContent checks that the same content is not kept twice
+func TestSnapshotsSameContent(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1")
+	s := server.New(config, nil, contentDir, statusMap)
+
+	otherContent, otherStatus := rulesContent("rule2")
+	s.SetContent(otherContent, otherStatus)
+	s.SetContent(contentDir, statusMap)
+
+	snapshots := s.Snapshots()
+	assert.Len(t, snapshots, 2)
+	assert.True(t, snapshots[0].Current)
+	assert.Equal(t, 1, snapshots[0].Rules)
+}
+
+// TestServeVersions checks the list of versions endpoint
+func TestServeVersions(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1")
+	s := server.New(config, nil, contentDir, statusMap)
+
+	response := sendGet(t, s, server.VersionsEndpoint)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+}
+
+// TestServeVersionedContent checks that content of older snapshot can be requested
+func TestServeVersionedContent(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(config, nil, oldContent, oldStatus)
+	s.RulesVersion = "1.0.0"
+	oldID := s.Snapshots()[0].ID
+
+	s.RulesVersion = "2.0.0"
+	newContent, newStatus := rulesContent("rule1", "rule2")
+	s.SetContent(newContent, newStatus)
+
+	for _, version := range []string{oldID, "1.0.0"} {
+		response := sendGet(t, s, server.AllContentEndpoint+"?version="+version)
+		checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+		var received content.RuleContentDirectory
+		helpers.FailOnError(t, gob.NewDecoder(response.Body).Decode(&received))
+		assert.Len(t, received.Rules, 1)
+	}
+
+	response := sendGet(t, s, server.AllContentEndpoint+"?version=unknown")
+	checkResponseCode(t, http.StatusNotFound, response.StatusCode)
+}
+
+// TestServeVersionedContentUnknownRulesVersion checks that snapshots are not
+// identified by placeholder used when rules version is not known
+func TestServeVersionedContentUnknownRulesVersion(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(config, nil, oldContent, oldStatus)
+	s.RulesVersion = "*not set*"
+	newContent, newStatus := rulesContent("rule1", "rule2")
+	s.SetContent(newContent, newStatus)
+
+	response := sendGet(t, s, server.AllContentEndpoint+"?version="+url.QueryEscape("*not set*"))
+	checkResponseCode(t, http.StatusNotFound, response.StatusCode)
+}
+
+// TestServeVersionedRule checks that one rule of older snapshot can be requested
+func TestServeVersionedRule(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1")
+	s := server.New(config, nil, oldContent, oldStatus)
+	oldID := s.Snapshots()[0].ID
+
+	newContent, newStatus := rulesContent("rule2")
+	s.SetContent(newContent, newStatus)
+
+	response := sendGet(t, s, "versions/"+oldID+"/rules/rule1")
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	response = sendGet(t, s, "versions/"+oldID+"/rules/rule2")
+	checkResponseCode(t, http.StatusNotFound, response.StatusCode)
+
+	response = sendGet(t, s, "versions/unknown/rules/rule1")
+	checkResponseCode(t, http.StatusNotFound, response.StatusCode)
+}
+
+// TestServeContentChanges checks the content changes endpoint
+func TestServeContentChanges(t *testing.T) {
+	oldContent, oldStatus := rulesContent("rule1", "rule2")
+	s := server.New(config, nil, oldContent, oldStatus)
+	oldID := s.Snapshots()[0].ID
+
+	newContent, newStatus := rulesContent("rule1", "rule3")
+	s.SetContent(newContent, newStatus)
+	newID := s.Snapshots()[0].ID
+
+	response := sendGet(t, s, server.ContentChangesEndpoint+"?since="+oldID)
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+	helpers.CheckResponseBodyJSON(t, `{
+		"status": "ok",
+		"base": "`+oldID+`",
+		"current": "`+newID+`",
+		"full": false,
+		"changes": {
+			"config": [],
+			"added": [
+				{
+					"name": "rule3",
+					"content": {
+						"plugin": {"name": "", "node_id": "", "product_code": "", "python_module": ""},
+						"error_keys": null,
+						"generic": "",
+						"summary": "summary of rule3",
+						"resolution": "",
+						"more_info": "",
+						"reason": "",
+						"HasReason": false
+					}
+				}
+			],
+			"removed": ["rule2"],
+			"modified": []
+		}
+	}`, response.Body)
+}
+
+// TestServeContentChangesUnknownBase checks that full content is returned
+// when the base snapshot is not kept
+func TestServeContentChangesUnknownBase(t *testing.T) {
+	contentDir, statusMap := rulesContent("rule1", "rule2")
+	s := server.New(config, nil, contentDir, statusMap)
+
+	response := sendGet(t, s, server.ContentChangesEndpoint+"?since=unknown")
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	var body struct {
+		Base    string          `json:"base"`
+		Full    bool            `json:"full"`
+		Changes content.Changes `json:"changes"`
+	}
+	helpers.FailOnError(t, json.NewDecoder(response.Body).Decode(&body))
+	assert.True(t, body.Full)
+	assert.Empty(t, body.Base)
+	assert.Len(t, body.Changes.Added, 2)
+}
+
+// TestServeContentChangesMissingSince checks that since parameter is required
+func TestServeContentChangesMissingSince(t *testing.T) {
+	helpers.AssertAPIRequest(t, &config, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ContentChangesEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+// TestSnapshotListener checks that listeners are notified about the current
+// snapshot and about all installed snapshots
+func TestSnapshotListener(t *testing.T) {
+	firstContent, firstStatus := rulesContent("rule1")
+	s := server.New(config, nil, firstContent, firstStatus)
+
+	changes := []server.SnapshotChange{}
+	s.AddSnapshotListener(func(change server.SnapshotChange) {
+		changes = append(changes, change)
+	})
+
+	assert.Len(t, changes, 1)
+	assert.Nil(t, changes[0].Previous)
+	assert.Equal(t, firstContent, changes[0].CurrentContent)
+	assert.True(t, changes[0].Current.Current)
+
+	secondContent, secondStatus := rulesContent("rule1", "rule2")
+	s.SetContent(secondContent, secondStatus)
+
+	assert.Len(t, changes, 2)
+	assert.NotNil(t, changes[1].Previous)
+	assert.Equal(t, changes[0].Current.Hash, changes[1].Previous.Hash)
+	assert.False(t, changes[1].Previous.Current)
+	assert.Equal(t, firstContent, changes[1].PreviousContent)
+	assert.Equal(t, secondContent, changes[1].CurrentContent)
+	assert.Equal(t, 2, changes[1].Current.Rules)
+}
+
+// TestSnapshotListenerNoContent checks that listener is not called before
+// content is loaded
+func TestSnapshotListenerNoContent(t *testing.T) {
+	s := server.New(config, nil, content.RuleContentDirectory{}, nil)
+
+	changes := []server.SnapshotChange{}
+	s.AddSnapshotListener(func(change server.SnapshotChange) {
+		changes = append(changes, change)
+	})
+	assert.Empty(t, changes)
+
+	s.SetContent(rulesContent("rule1"))
+	assert.Len(t, changes, 1)
+	assert.Nil(t, changes[0].Previous)
+}
+
+// TestSlowClientDoesNotBlockReloadVersioned checks that snapshot endpoints
+// don't hold the server mutex while writing their responses
+func TestSlowClientDoesNotBlockReloadVersioned(t *testing.T) {
+	for _, endpoint := range []string{
+		server.AllContentEndpoint + "?version=%s",
+		"versions/%s/rules/rule1",
+		server.ContentChangesEndpoint + "?since=%s",
+	} {
+		t.Run(endpoint, func(t *testing.T) {
+			contentDir, statusMap := rulesContent("rule1")
+			s := server.New(config, nil, contentDir, statusMap)
+			assertNotBlockedBySlowClient(t, s, fmt.Sprintf(endpoint, s.Snapshots()[0].ID))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxBundleSize limits size of downloaded bundle and of its extracted
+	// content
+	maxBundleSize = 1 << 30
+
+	// globalConfigFile is the file expected in root of content directory
+	globalConfigFile = "config.yaml"
+)
+
+// extractBundle extracts gzipped tar archive into target directory
+func extractBundle(archive io.Reader, targetDir string) error {
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = gzipReader.Close()
+	}()
+
+	return extractTar(gzipReader, targetDir)
+}
+
+// extractTar extracts tar archive into target directory. Only regular files
+// and directories are extracted, other entries like symbolic links are
+// refused, so no content is lost unnoticed. Entries pointing outside of the
+// target directory are refused too.
+func extractTar(archive io.Reader, targetDir string) error {
+	var extracted int64
+	tarReader := tar.NewReader(archive)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := entryPath(targetDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			extracted += header.Size
+			if extracted > maxBundleSize {
+				return fmt.Errorf("extracted bundle is larger than %d bytes", maxBundleSize)
+			}
+			if err := extractFile(tarReader, target, header.Size); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+			// metadata of the whole archive
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("link '%s' is not supported in bundle", header.Name)
+		default:
+			return fmt.Errorf("bundle entry '%s' is neither regular file nor directory", header.Name)
+		}
+	}
+}
+
+// entryPath returns path of archive or tree entry within target directory.
+// Entries pointing outside of the target directory are refused.
+func entryPath(targetDir, entryName string) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(entryName))
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry '%s' points outside of content directory", entryName)
+	}
+	return filepath.Join(targetDir, name), nil
+}
+
+// extractFile writes one file from the archive
+func extractFile(reader io.Reader, target string, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Clean(target), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(file, reader, size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// contentRoot returns directory containing the global content config. Bundle
+// can contain the content directly or in one top level directory.
+func contentRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, globalConfigFile)); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		nested := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(nested, globalConfigFile)); err == nil {
+			return nested, nil
+		}
+	}
+
+	return "", fmt.Errorf("bundle does not contain %s", globalConfigFile)
+}
+
+// parseChecksum returns SHA-256 digest from checksum file in format used by
+// sha256sum tool, i.e. the hex digest optionally followed by file name
+func parseChecksum(data []byte) (string, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum")
+	}
+
+	checksum := strings.ToLower(fields[0])
+	if len(checksum) != 64 || strings.Trim(checksum, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid SHA-256 checksum '%s'", fields[0])
+	}
+	return checksum, nil
+}
@@ -0,0 +1,304 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultGitContentPath is directory with rule content in ccx-rules-ocp
+	// repository
+	DefaultGitContentPath = "content"
+
+	gitDir = "git"
+)
+
+// GitConfiguration represents configuration of rule content read from local
+// git repository
+type GitConfiguration struct {
+	// Enabled turns reading of content from git repository on
+	Enabled bool `mapstructure:"enabled" toml:"enabled"`
+	// Repository is path to local repository, it can be a bare one
+	Repository string `mapstructure:"repository" toml:"repository"`
+	// Ref is tag, branch or commit the content is read from
+	Ref string `mapstructure:"ref" toml:"ref"`
+	// Path is directory with rule content within the repository
+	Path string `mapstructure:"path" toml:"path"`
+	// CacheDir is directory where content tree is extracted
+	CacheDir string `mapstructure:"cache_dir" toml:"cache_dir"`
+	// CheckInterval is interval between checks whether the ref points to
+	// another commit
+	CheckInterval time.Duration `mapstructure:"check_interval" toml:"check_interval"`
+}
+
+// GitSource reads rule content from local git repository at configured ref.
+// Content tree is read directly from git objects, so no checkout is needed
+// and git command does not need to be installed.
+type GitSource struct {
+	config   GitConfiguration
+	cacheDir string
+
+	mutex  sync.Mutex
+	commit string
+	path   string
+	// accepted is commit whose content has been accepted
+	accepted string
+}
+
+// NewGit constructs content source for configured repository
+func NewGit(config GitConfiguration) (*GitSource, error) {
+	if config.CacheDir == "" {
+		return nil, fmt.Errorf("cache directory is not configured")
+	}
+	if config.Ref == "" || strings.HasPrefix(config.Ref, "-") {
+		return nil, fmt.Errorf("invalid git ref '%s'", config.Ref)
+	}
+
+	source := &GitSource{
+		config:   config,
+		cacheDir: filepath.Join(config.CacheDir, gitDir),
+	}
+
+	if err := os.MkdirAll(source.cacheDir, 0o750); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// Path returns local directory with content of the latest resolved commit
+func (source *GitSource) Path() string {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	return source.path
+}
+
+// Version returns the latest resolved commit, it is used as rules version
+func (source *GitSource) Version() string {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	return source.commit
+}
+
+// Fetch resolves configured ref and extracts content tree of the commit when
+// it differs from the previously resolved one. Content trees of previous
+// commits are kept until content of newer commit is accepted.
+func (source *GitSource) Fetch(ctx context.Context) (string, bool, error) {
+	commitObject, err := source.resolve()
+	if err != nil {
+		return source.Path(), false, err
+	}
+	commit := commitObject.Hash.String()
+
+	source.mutex.Lock()
+	current, currentPath, accepted := source.commit, source.path, source.accepted
+	source.mutex.Unlock()
+
+	// content that has not been accepted is offered again
+	if commit == current {
+		return currentPath, commit != accepted, nil
+	}
+
+	path := filepath.Join(source.cacheDir, commit)
+	if !isDirectory(path) {
+		if err := source.extract(ctx, commitObject, path); err != nil {
+			return currentPath, false, err
+		}
+	}
+
+	source.mutex.Lock()
+	source.commit = commit
+	source.path = path
+	source.mutex.Unlock()
+
+	log.Info().
+		Str("repository", source.config.Repository).
+		Str("ref", source.config.Ref).
+		Str("commit", commit).
+		Msg("Content read from git repository")
+
+	return path, commit != accepted, nil
+}
+
+// Accept records content tree of a commit as installed and removes content
+// trees of all other commits except the latest resolved one
+func (source *GitSource) Accept(path string) {
+	if filepath.Dir(path) != source.cacheDir {
+		log.Warn().Str("path", path).Msg("Unknown content tree can't be accepted")
+		return
+	}
+	commit := filepath.Base(path)
+
+	source.mutex.Lock()
+	source.accepted = commit
+	current := source.commit
+	source.mutex.Unlock()
+
+	source.removeStaleTrees(commit, current)
+}
+
+// resolve returns commit the configured ref points to. Repository is opened
+// for each check, so objects added to it in the meantime are found.
+func (source *GitSource) resolve() (*object.Commit, error) {
+	repository, err := git.PlainOpen(source.config.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open git repository '%s': %w", source.config.Repository, err)
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(source.config.Ref))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve git ref '%s': %w", source.config.Ref, err)
+	}
+
+	commit, err := repository.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read commit %s: %w", hash, err)
+	}
+	return commit, nil
+}
+
+// extract writes content tree of the commit into target directory. Tree is
+// extracted into temporary directory first, so partially extracted tree
+// never replaces complete one.
+func (source *GitSource) extract(ctx context.Context, commit *object.Commit, target string) error {
+	treeName := commit.Hash.String()
+	tree, err := commit.Tree()
+	if err == nil {
+		if contentPath := strings.Trim(source.config.Path, "/"); contentPath != "" {
+			treeName += ":" + contentPath
+			tree, err = tree.Tree(contentPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read content tree %s: %w", treeName, err)
+	}
+
+	tmpDir, err := os.MkdirTemp(source.cacheDir, "extract-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	if err := extractTree(ctx, tree, tmpDir); err != nil {
+		return fmt.Errorf("unable to extract content tree %s: %w", treeName, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, globalConfigFile)); err != nil {
+		return fmt.Errorf("content tree %s does not contain %s", treeName, globalConfigFile)
+	}
+
+	return os.Rename(tmpDir, target)
+}
+
+// extractTree writes all files of git tree into target directory. Only
+// regular files and directories are extracted, symbolic links and
+// submodules are refused, so no content is lost unnoticed.
+func extractTree(ctx context.Context, tree *object.Tree, targetDir string) error {
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	var extracted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := entryPath(targetDir, name)
+		if err != nil {
+			return err
+		}
+
+		switch entry.Mode {
+		case filemode.Dir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return err
+			}
+		case filemode.Regular, filemode.Executable, filemode.Deprecated:
+			file, err := tree.TreeEntryFile(&entry)
+			if err != nil {
+				return err
+			}
+			extracted += file.Size
+			if extracted > maxBundleSize {
+				return fmt.Errorf("extracted content is larger than %d bytes", maxBundleSize)
+			}
+			if err := extractBlob(file, target); err != nil {
+				return err
+			}
+		case filemode.Symlink:
+			return fmt.Errorf("symbolic link '%s' is not supported in content tree", name)
+		default:
+			return fmt.Errorf("entry '%s' of type %s is not supported in content tree", name, entry.Mode)
+		}
+	}
+}
+
+// extractBlob writes content of one file from git tree
+func extractBlob(file *object.File, target string) error {
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	return extractFile(reader, target, file.Size)
+}
+
+// removeStaleTrees removes content trees of all commits except the accepted
+// and the current one
+func (source *GitSource) removeStaleTrees(accepted, current string) {
+	entries, err := os.ReadDir(source.cacheDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != accepted && entry.Name() != current {
+			if err := os.RemoveAll(filepath.Join(source.cacheDir, entry.Name())); err != nil {
+				log.Warn().Err(err).Str("tree", entry.Name()).Msg("Unable to remove stale content tree")
+			}
+		}
+	}
+}
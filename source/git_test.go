@@ -0,0 +1,282 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/source"
+)
+
+// gitRepository is work tree with rule content and its bare clone
+type gitRepository struct {
+	workTree string
+	bare     string
+}
+
+// runGit runs git command in given directory and returns its output
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// newGitRepository creates repository with test content in content
+// directory, tags the commit and clones the repository as a bare one
+func newGitRepository(t *testing.T, tag string) *gitRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repository := &gitRepository{
+		workTree: t.TempDir(),
+		bare:     filepath.Join(t.TempDir(), "ccx-rules-ocp.git"),
+	}
+
+	runGit(t, repository.workTree, "init", "--quiet", "--initial-branch=master")
+	copyDir(t, "../tests/content/ok", filepath.Join(repository.workTree, source.DefaultGitContentPath))
+	repository.commit(t, tag)
+	runGit(t, repository.workTree, "clone", "--quiet", "--bare", repository.workTree, repository.bare)
+
+	return repository
+}
+
+// commit records all changes in work tree and tags the commit
+func (repository *gitRepository) commit(t *testing.T, tag string) string {
+	runGit(t, repository.workTree, "add", "--all")
+	runGit(t, repository.workTree, "commit", "--quiet", "--message", "content "+tag)
+	runGit(t, repository.workTree, "tag", tag)
+	return runGit(t, repository.workTree, "rev-parse", "HEAD")
+}
+
+// push updates the bare clone
+func (repository *gitRepository) push(t *testing.T) {
+	runGit(t, repository.workTree, "push", "--quiet", "--force", "--tags", repository.bare, "HEAD:refs/heads/master")
+}
+
+// copyDir copies directory tree
+func copyDir(t *testing.T, from, to string) {
+	err := filepath.WalkDir(from, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, name)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0o750)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o600)
+	})
+	assert.NoError(t, err)
+}
+
+// gitConfig returns configuration of source reading from the repository
+func gitConfig(t *testing.T, repository *gitRepository, ref string) source.GitConfiguration {
+	return source.GitConfiguration{
+		Enabled:    true,
+		Repository: repository.bare,
+		Ref:        ref,
+		Path:       source.DefaultGitContentPath,
+		CacheDir:   t.TempDir(),
+	}
+}
+
+// TestGitFetch checks that content is read at the pinned tag and resolved
+// commit is provided as the version
+func TestGitFetch(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+	commit := runGit(t, repository.workTree, "rev-parse", "HEAD")
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, "v1.0.0"))
+	assert.NoError(t, err)
+	assert.Empty(t, gitSource.Path())
+	assert.Empty(t, gitSource.Version())
+
+	path, changed, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, path, gitSource.Path())
+	assert.Equal(t, commit, gitSource.Version())
+	assert.Contains(t, parseContent(t, path).Rules, "rule1")
+
+	// content that has not been accepted is offered again
+	samePath, changed, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, path, samePath)
+
+	// the same commit is not extracted again
+	gitSource.Accept(path)
+	samePath, changed, err = gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, path, samePath)
+}
+
+// TestGitFetchMovedRef checks that content is extracted again when the ref
+// points to another commit
+func TestGitFetchMovedRef(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, "master"))
+	assert.NoError(t, err)
+
+	path, _, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	gitSource.Accept(path)
+
+	summary := filepath.Join(repository.workTree, source.DefaultGitContentPath, "external/rules/rule1/summary.md")
+	assert.NoError(t, os.WriteFile(summary, []byte("new summary"), 0o600))
+	commit := repository.commit(t, "v1.1.0")
+	repository.push(t)
+
+	newPath, changed, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEqual(t, path, newPath)
+	assert.Equal(t, commit, gitSource.Version())
+	assert.Equal(t, "new summary", parseContent(t, newPath).Rules["rule1"].Summary)
+
+	// tree of the previous commit is kept until the new one is accepted
+	assert.DirExists(t, path)
+	gitSource.Accept(newPath)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestGitFetchCommit checks that content can be pinned to a commit
+func TestGitFetchCommit(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+	commit := runGit(t, repository.workTree, "rev-parse", "HEAD")
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, commit[:12]))
+	assert.NoError(t, err)
+
+	_, changed, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, commit, gitSource.Version())
+}
+
+// TestGitFetchUnknownRef checks that unknown ref is reported
+func TestGitFetchUnknownRef(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, "v2.0.0"))
+	assert.NoError(t, err)
+
+	_, changed, err := gitSource.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to resolve git ref 'v2.0.0'")
+	assert.False(t, changed)
+	assert.Empty(t, gitSource.Path())
+}
+
+// TestGitFetchMissingContent checks that commit without content directory
+// is refused
+func TestGitFetchMissingContent(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+
+	config := gitConfig(t, repository, "v1.0.0")
+	config.Path = "rules"
+	gitSource, err := source.NewGit(config)
+	assert.NoError(t, err)
+
+	_, _, err = gitSource.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, gitSource.Version())
+}
+
+// TestGitFetchAnnotatedTag checks that content can be pinned to annotated
+// tag
+func TestGitFetchAnnotatedTag(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+	commit := runGit(t, repository.workTree, "rev-parse", "HEAD")
+	runGit(t, repository.workTree, "tag", "--annotate", "--message", "release", "v1.0.1")
+	repository.push(t)
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, "v1.0.1"))
+	assert.NoError(t, err)
+
+	_, changed, err := gitSource.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, commit, gitSource.Version())
+}
+
+// TestGitFetchSymlink checks that content tree with symbolic link is refused
+// instead of dropping the link silently
+func TestGitFetchSymlink(t *testing.T) {
+	repository := newGitRepository(t, "v1.0.0")
+
+	summary := filepath.Join(repository.workTree, source.DefaultGitContentPath, "external/rules/rule1/summary.md")
+	assert.NoError(t, os.Remove(summary))
+	assert.NoError(t, os.Symlink("../../../config.yaml", summary))
+	repository.commit(t, "v1.1.0")
+	repository.push(t)
+
+	gitSource, err := source.NewGit(gitConfig(t, repository, "v1.1.0"))
+	assert.NoError(t, err)
+
+	_, _, err = gitSource.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "symbolic link 'external/rules/rule1/summary.md' is not supported")
+	assert.Empty(t, gitSource.Version())
+}
+
+// TestGitFetchMissingRepository checks that missing repository is reported
+func TestGitFetchMissingRepository(t *testing.T) {
+	gitSource, err := source.NewGit(source.GitConfiguration{
+		Repository: filepath.Join(t.TempDir(), "xyzzy.git"),
+		Ref:        "master",
+		CacheDir:   t.TempDir(),
+	})
+	assert.NoError(t, err)
+
+	_, _, err = gitSource.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to open git repository")
+}
+
+// TestNewGitInvalidRef checks that refs that can't be valid git refs are
+// refused
+func TestNewGitInvalidRef(t *testing.T) {
+	_, err := source.NewGit(source.GitConfiguration{
+		Repository: t.TempDir(),
+		Ref:        "--output=/tmp/x",
+		CacheDir:   t.TempDir(),
+	})
+	assert.Error(t, err)
+}
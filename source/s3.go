@@ -0,0 +1,456 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rs/zerolog/log"
+)
+
+// Default values used when they are not configured
+const (
+	DefaultS3Region      = "us-east-1"
+	DefaultCheckInterval = 5 * time.Minute
+	DefaultTimeout       = 1 * time.Minute
+)
+
+const (
+	// checksumSuffix is appended to bundle key to get key of the checksum
+	// object when it is not configured
+	checksumSuffix = ".sha256"
+
+	s3StateFile  = "state.json"
+	s3BundlesDir = "bundles"
+)
+
+// S3Configuration represents configuration of content bundle stored in
+// S3-compatible object store
+type S3Configuration struct {
+	// Enabled turns downloading of content from object store on
+	Enabled bool `mapstructure:"enabled" toml:"enabled"`
+	// Endpoint is URL of S3-compatible service, AWS S3 is used when not set
+	Endpoint string `mapstructure:"endpoint" toml:"endpoint"`
+	// Region is region of the bucket
+	Region string `mapstructure:"region" toml:"region"`
+	// Bucket is name of bucket containing the bundle
+	Bucket string `mapstructure:"bucket" toml:"bucket"`
+	// Key is key of gzipped tar archive with rule content
+	Key string `mapstructure:"key" toml:"key"`
+	// ChecksumKey is key of object containing SHA-256 checksum of the
+	// bundle, key of the bundle with .sha256 suffix is used when not set
+	ChecksumKey string `mapstructure:"checksum_key" toml:"checksum_key"`
+	// AccessKeyID and SecretAccessKey are static credentials, default
+	// AWS credentials chain is used when they are not set
+	AccessKeyID     string `mapstructure:"access_key_id" toml:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key" toml:"secret_access_key"`
+	// PathStyle selects path-style URLs required by MinIO and other
+	// S3-compatible services
+	PathStyle bool `mapstructure:"path_style" toml:"path_style"`
+	// CacheDir is directory where downloaded content is cached
+	CacheDir string `mapstructure:"cache_dir" toml:"cache_dir"`
+	// CheckInterval is interval between checks for newer bundle
+	CheckInterval time.Duration `mapstructure:"check_interval" toml:"check_interval"`
+	// Timeout is timeout of requests to object store
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+}
+
+// s3State of accepted content is stored in cache directory, so the content
+// can be used after restart
+type s3State struct {
+	// Version is object version ID or ETag when bucket is not versioned
+	Version  string `json:"version"`
+	Checksum string `json:"checksum"`
+	Path     string `json:"path"`
+}
+
+// S3Source downloads content bundle from S3-compatible object store
+type S3Source struct {
+	config   S3Configuration
+	client   *s3.S3
+	cacheDir string
+
+	mutex sync.Mutex
+	state s3State
+	// fetched is state of downloaded content that has not been accepted
+	// yet
+	fetched *s3State
+}
+
+// NewS3 constructs content source for configured bucket. Content accepted by
+// previous run is available via Path immediately.
+func NewS3(config S3Configuration) (*S3Source, error) {
+	if config.CacheDir == "" {
+		return nil, fmt.Errorf("cache directory is not configured")
+	}
+
+	awsConfig := &aws.Config{
+		Region:           aws.String(config.Region),
+		S3ForcePathStyle: aws.Bool(config.PathStyle),
+		HTTPClient:       &http.Client{Timeout: durationOrDefault(config.Timeout, DefaultTimeout)},
+	}
+	if config.Region == "" {
+		awsConfig.Region = aws.String(DefaultS3Region)
+	}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+	if config.AccessKeyID != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, "")
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &S3Source{
+		config:   config,
+		client:   s3.New(awsSession),
+		cacheDir: filepath.Join(config.CacheDir, "s3"),
+	}
+
+	if err := os.MkdirAll(source.cacheDir, 0o750); err != nil {
+		return nil, err
+	}
+	source.loadState()
+
+	return source, nil
+}
+
+// Path returns local directory with the latest fetched content
+func (source *S3Source) Path() string {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.fetched != nil {
+		return source.fetched.Path
+	}
+	return source.state.Path
+}
+
+// Fetch downloads the bundle when its version differs from the accepted
+// one. The bundle is verified by its SHA-256 checksum before it is
+// extracted. Downloaded bundle is not recorded in cache directory until it
+// is accepted.
+func (source *S3Source) Fetch(ctx context.Context) (string, bool, error) {
+	head, err := source.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(source.config.Bucket),
+		Key:    aws.String(source.config.Key),
+	})
+	if err != nil {
+		return source.Path(), false, fmt.Errorf("unable to read bundle metadata: %w", err)
+	}
+
+	version := objectVersion(head.VersionId, head.ETag)
+	accepted, fetched := source.states()
+	if version == accepted.Version && isDirectory(accepted.Path) {
+		source.clearFetched()
+		return accepted.Path, false, nil
+	}
+	if fetched != nil && version == fetched.Version && isDirectory(fetched.Path) {
+		// content that has not been accepted is offered again
+		return fetched.Path, true, nil
+	}
+
+	checksum, err := source.readChecksum(ctx)
+	if err != nil {
+		return source.Path(), false, err
+	}
+
+	cached := accepted
+	if fetched != nil && fetched.Checksum == checksum {
+		cached = *fetched
+	}
+	path, err := source.download(ctx, head, checksum, cached)
+	if err != nil {
+		return source.Path(), false, err
+	}
+
+	// the same bundle might have been uploaded again
+	if path == accepted.Path {
+		accepted.Version = version
+		source.clearFetched()
+		source.setState(accepted)
+		return accepted.Path, false, nil
+	}
+
+	source.mutex.Lock()
+	source.fetched = &s3State{Version: version, Checksum: checksum, Path: path}
+	source.mutex.Unlock()
+
+	log.Info().
+		Str("bucket", source.config.Bucket).
+		Str("key", source.config.Key).
+		Str("version", version).
+		Msg("Content bundle downloaded")
+
+	return path, true, nil
+}
+
+// Accept records downloaded content as installed, so it is used after
+// restart, and removes all other extracted bundles except the one downloaded
+// later
+func (source *S3Source) Accept(path string) {
+	source.mutex.Lock()
+	state := source.state
+	keep := []string{}
+	if source.fetched != nil {
+		if source.fetched.Path == path {
+			state = *source.fetched
+			source.fetched = nil
+		} else {
+			keep = append(keep, source.fetched.Checksum)
+		}
+	}
+	source.mutex.Unlock()
+
+	if state.Path != path {
+		log.Warn().Str("path", path).Msg("Unknown content bundle can't be accepted")
+		return
+	}
+
+	source.setState(state)
+	source.removeStaleBundles(append(keep, state.Checksum)...)
+}
+
+// readChecksum reads expected SHA-256 checksum of the bundle
+func (source *S3Source) readChecksum(ctx context.Context) (string, error) {
+	checksumKey := source.config.ChecksumKey
+	if checksumKey == "" {
+		checksumKey = source.config.Key + checksumSuffix
+	}
+
+	object, err := source.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(source.config.Bucket),
+		Key:    aws.String(checksumKey),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to read bundle checksum: %w", err)
+	}
+	defer func() {
+		_ = object.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(object.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("unable to read bundle checksum: %w", err)
+	}
+	return parseChecksum(data)
+}
+
+// download downloads the bundle, verifies it and extracts it into cache
+// directory. Path to extracted content is returned, the bundle is not
+// extracted again when it is the cached one.
+func (source *S3Source) download(ctx context.Context, head *s3.HeadObjectOutput,
+	checksum string, cached s3State) (string, error) {
+	// the object must not be replaced between reading its metadata and
+	// downloading it
+	input := &s3.GetObjectInput{
+		Bucket:  aws.String(source.config.Bucket),
+		Key:     aws.String(source.config.Key),
+		IfMatch: head.ETag,
+	}
+	if aws.StringValue(head.VersionId) != "" && aws.StringValue(head.VersionId) != "null" {
+		input.VersionId = head.VersionId
+	}
+
+	object, err := source.client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("unable to download bundle: %w", err)
+	}
+	defer func() {
+		_ = object.Body.Close()
+	}()
+
+	archive, err := os.CreateTemp(source.cacheDir, "bundle-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archive.Name())
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(archive, hash), io.LimitReader(object.Body, maxBundleSize+1))
+	if err != nil {
+		return "", fmt.Errorf("unable to download bundle: %w", err)
+	}
+	if size > maxBundleSize {
+		return "", fmt.Errorf("bundle is larger than %d bytes", maxBundleSize)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return "", fmt.Errorf("bundle checksum %s does not match expected checksum %s", actual, checksum)
+	}
+
+	if checksum == cached.Checksum && isDirectory(cached.Path) {
+		return cached.Path, nil
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	bundleDir := filepath.Join(source.cacheDir, s3BundlesDir, checksum)
+	if err := extractInto(archive, bundleDir); err != nil {
+		return "", err
+	}
+
+	return contentRoot(bundleDir)
+}
+
+// extractInto extracts bundle into temporary directory first, so partially
+// extracted bundle never replaces complete one
+func extractInto(archive io.Reader, bundleDir string) error {
+	if err := os.MkdirAll(filepath.Dir(bundleDir), 0o750); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(bundleDir), "extract-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	if err := extractBundle(archive, tmpDir); err != nil {
+		return fmt.Errorf("unable to extract bundle: %w", err)
+	}
+
+	if err := os.RemoveAll(bundleDir); err != nil {
+		return err
+	}
+	return os.Rename(tmpDir, bundleDir)
+}
+
+// removeStaleBundles removes all extracted bundles except the ones with
+// provided checksums
+func (source *S3Source) removeStaleBundles(checksums ...string) {
+	bundlesDir := filepath.Join(source.cacheDir, s3BundlesDir)
+	entries, err := os.ReadDir(bundlesDir)
+	if err != nil {
+		return
+	}
+
+	keep := make(map[string]bool, len(checksums))
+	for _, checksum := range checksums {
+		keep[checksum] = true
+	}
+
+	for _, entry := range entries {
+		if !keep[entry.Name()] {
+			if err := os.RemoveAll(filepath.Join(bundlesDir, entry.Name())); err != nil {
+				log.Warn().Err(err).Str("bundle", entry.Name()).Msg("Unable to remove stale content bundle")
+			}
+		}
+	}
+}
+
+// states returns state of accepted content and state of downloaded content
+// that has not been accepted yet
+func (source *S3Source) states() (s3State, *s3State) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	return source.state, source.fetched
+}
+
+// clearFetched forgets content that has not been accepted
+func (source *S3Source) clearFetched() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	source.fetched = nil
+}
+
+// setState replaces state of accepted content and stores it in cache
+// directory
+func (source *S3Source) setState(state s3State) {
+	source.mutex.Lock()
+	source.state = state
+	source.mutex.Unlock()
+
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(source.cacheDir, s3StateFile), data, 0o600)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to store content bundle state")
+	}
+}
+
+// loadState reads state stored by previous run, it is ignored when the
+// cached content does not exist anymore
+func (source *S3Source) loadState() {
+	data, err := os.ReadFile(filepath.Join(source.cacheDir, s3StateFile))
+	if err != nil {
+		return
+	}
+
+	state := s3State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Warn().Err(err).Msg("Ignoring invalid content bundle state")
+		return
+	}
+	if isDirectory(state.Path) {
+		source.state = state
+	}
+}
+
+// objectVersion returns object version ID, ETag is used for buckets without
+// versioning
+func objectVersion(versionID, etag *string) string {
+	if version := aws.StringValue(versionID); version != "" && version != "null" {
+		return version
+	}
+	return aws.StringValue(etag)
+}
+
+// isDirectory returns true if path is an existing directory
+func isDirectory(path string) bool {
+	if path == "" {
+		return false
+	}
+	fileInfo, err := os.Stat(path)
+	return err == nil && fileInfo.IsDir()
+}
+
+// durationOrDefault returns the provided duration or the default one when
+// the duration is not set
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+	return duration
+}
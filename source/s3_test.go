@@ -0,0 +1,390 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/content"
+	"github.com/RedHatInsights/insights-content-service/source"
+)
+
+const (
+	bucket    = "content"
+	bundleKey = "bundles/rules-content.tar.gz"
+)
+
+// objectStore is minimal S3-compatible service serving objects by path-style
+// URLs /bucket/key
+type objectStore struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+	server  *httptest.Server
+}
+
+func newObjectStore(t *testing.T) *objectStore {
+	store := &objectStore{objects: map[string][]byte{}}
+	store.server = httptest.NewServer(http.HandlerFunc(store.handle))
+	t.Cleanup(store.server.Close)
+	return store
+}
+
+func (store *objectStore) put(key string, data []byte) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.objects["/"+bucket+"/"+key] = data
+}
+
+// putBundle uploads bundle together with its checksum
+func (store *objectStore) putBundle(data []byte) {
+	sum := sha256.Sum256(data)
+	store.put(bundleKey+".sha256", []byte(hex.EncodeToString(sum[:])+"  rules-content.tar.gz\n"))
+	store.put(bundleKey, data)
+}
+
+func (store *objectStore) handle(writer http.ResponseWriter, request *http.Request) {
+	store.mutex.Lock()
+	data, found := store.objects[request.URL.Path]
+	store.mutex.Unlock()
+
+	if !found {
+		writer.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(writer, "<Error><Code>NoSuchKey</Code></Error>")
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, md5.Sum(data))
+	if ifMatch := request.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag {
+		writer.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	writer.Header().Set("ETag", etag)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	writer.WriteHeader(http.StatusOK)
+	if request.Method == http.MethodGet {
+		_, _ = writer.Write(data)
+	}
+}
+
+// s3Config returns configuration of source reading from the object store
+func s3Config(t *testing.T, store *objectStore) source.S3Configuration {
+	return source.S3Configuration{
+		Enabled:         true,
+		Endpoint:        store.server.URL,
+		Bucket:          bucket,
+		Key:             bundleKey,
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+		CacheDir:        t.TempDir(),
+		Timeout:         5 * time.Second,
+	}
+}
+
+// bundle creates gzipped tar archive of content directory, optionally with
+// all files placed in given top level directory
+func bundle(t *testing.T, contentDir, prefix string, modify func(name string, data []byte) []byte) []byte {
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.WalkDir(contentDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(contentDir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if modify != nil {
+			data = modify(filepath.ToSlash(name), data)
+		}
+		return writeTarFile(tarWriter, filepath.ToSlash(filepath.Join(prefix, name)), data)
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return buffer.Bytes()
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, data []byte) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tarWriter.Write(data)
+	return err
+}
+
+// parseContent parses content fetched into provided directory
+func parseContent(t *testing.T, path string) content.RuleContentDirectory {
+	contentDir, _, err := content.ParseRuleContentDir(path)
+	assert.NoError(t, err)
+	return contentDir
+}
+
+// TestS3Fetch checks that bundle is downloaded only when it changes
+func TestS3Fetch(t *testing.T) {
+	store := newObjectStore(t)
+	store.putBundle(bundle(t, "../tests/content/ok", "", nil))
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+	assert.Empty(t, s3Source.Path())
+
+	path, changed, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, path, s3Source.Path())
+	assert.Contains(t, parseContent(t, path).Rules, "rule1")
+
+	// content that has not been accepted is offered again
+	samePath, changed, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, path, samePath)
+
+	// the same object is not downloaded again
+	s3Source.Accept(path)
+	samePath, changed, err = s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, path, samePath)
+
+	// new bundle with the content in top level directory
+	store.putBundle(bundle(t, "../tests/content/ok", "rules-content", func(name string, data []byte) []byte {
+		if name == "external/rules/rule1/summary.md" {
+			return []byte("new summary")
+		}
+		return data
+	}))
+
+	newPath, changed, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEqual(t, path, newPath)
+	assert.Equal(t, "new summary", parseContent(t, newPath).Rules["rule1"].Summary)
+
+	// the previous bundle is removed from cache when the new one is
+	// accepted
+	assert.DirExists(t, path)
+	s3Source.Accept(newPath)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestS3FetchNotAccepted checks that bundle which has not been accepted is
+// not used after restart
+func TestS3FetchNotAccepted(t *testing.T) {
+	store := newObjectStore(t)
+	store.putBundle(bundle(t, "../tests/content/ok", "", nil))
+
+	config := s3Config(t, store)
+	s3Source, err := source.NewS3(config)
+	assert.NoError(t, err)
+
+	path, _, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	s3Source.Accept(path)
+
+	store.putBundle(bundle(t, "../tests/content/ok", "rules-content", nil))
+	newPath, changed, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, newPath, s3Source.Path())
+
+	s3Source, err = source.NewS3(config)
+	assert.NoError(t, err)
+	assert.Equal(t, path, s3Source.Path())
+}
+
+// TestS3FetchInvalidChecksum checks that bundle not matching its checksum
+// is refused and the cached content is kept
+func TestS3FetchInvalidChecksum(t *testing.T) {
+	store := newObjectStore(t)
+	store.putBundle(bundle(t, "../tests/content/ok", "", nil))
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+
+	path, _, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+
+	store.put(bundleKey, bundle(t, "../tests/content/ok", "other", nil))
+
+	currentPath, changed, err := s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match expected checksum")
+	assert.False(t, changed)
+	assert.Equal(t, path, currentPath)
+	assert.Equal(t, path, s3Source.Path())
+}
+
+// TestS3FetchMissingChecksum checks that bundle without checksum is refused
+func TestS3FetchMissingChecksum(t *testing.T) {
+	store := newObjectStore(t)
+	store.put(bundleKey, bundle(t, "../tests/content/ok", "", nil))
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+
+	_, changed, err := s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, s3Source.Path())
+}
+
+// TestS3FetchMissingBundle checks that missing bundle is reported
+func TestS3FetchMissingBundle(t *testing.T) {
+	store := newObjectStore(t)
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+
+	_, _, err = s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+}
+
+// TestS3FetchUnsafeBundle checks that bundle with entries outside of the
+// content directory is refused
+func TestS3FetchUnsafeBundle(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	assert.NoError(t, writeTarFile(tarWriter, "../escaped.yaml", []byte("x")))
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+
+	store := newObjectStore(t)
+	store.putBundle(buffer.Bytes())
+
+	config := s3Config(t, store)
+	s3Source, err := source.NewS3(config)
+	assert.NoError(t, err)
+
+	_, _, err = s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outside of content directory")
+
+	_, err = os.Stat(filepath.Join(config.CacheDir, "escaped.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestS3FetchBundleWithSymlink checks that bundle with symbolic link is
+// refused instead of dropping the link silently
+func TestS3FetchBundleWithSymlink(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	assert.NoError(t, writeTarFile(tarWriter, "config.yaml", []byte("impact: {}")))
+	assert.NoError(t, tarWriter.WriteHeader(&tar.Header{
+		Name:     "external/rules/rule1/summary.md",
+		Typeflag: tar.TypeSymlink,
+		Linkname: "../../../summary.md",
+	}))
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+
+	store := newObjectStore(t)
+	store.putBundle(buffer.Bytes())
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+
+	_, _, err = s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "link 'external/rules/rule1/summary.md' is not supported")
+}
+
+// TestS3CachedContent checks that content cached by previous run is used
+// when the object store is not available
+func TestS3CachedContent(t *testing.T) {
+	store := newObjectStore(t)
+	store.putBundle(bundle(t, "../tests/content/ok", "", nil))
+
+	config := s3Config(t, store)
+	s3Source, err := source.NewS3(config)
+	assert.NoError(t, err)
+
+	path, _, err := s3Source.Fetch(context.Background())
+	assert.NoError(t, err)
+	s3Source.Accept(path)
+
+	store.server.Close()
+
+	s3Source, err = source.NewS3(config)
+	assert.NoError(t, err)
+	assert.Equal(t, path, s3Source.Path())
+
+	currentPath, changed, err := s3Source.Fetch(context.Background())
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, path, currentPath)
+}
+
+// TestWatch checks that new content is announced
+func TestWatch(t *testing.T) {
+	store := newObjectStore(t)
+	store.putBundle(bundle(t, "../tests/content/ok", "", nil))
+
+	s3Source, err := source.NewS3(s3Config(t, store))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string, 10)
+	go source.Watch(ctx, s3Source, 10*time.Millisecond, func(path string) {
+		changes <- path
+	})
+
+	select {
+	case path := <-changes:
+		assert.Equal(t, s3Source.Path(), path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("new content has not been announced")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package source contains implementation of rule content sources other than
+// local directory. Content is fetched into local cache directory, so it can
+// be parsed the same way as content copied into the container image, and the
+// source is checked periodically for newer content.
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Source provides rule content that can be updated while the service is
+// running
+type Source interface {
+	// Fetch checks whether newer content is available and downloads it.
+	// Path to local directory with the latest content is returned together
+	// with flag whether the content differs from the accepted one. Content
+	// that has not been accepted is reported as changed by each call, so
+	// its installation is retried.
+	Fetch(ctx context.Context) (string, bool, error)
+
+	// Path returns local directory with the latest fetched content, empty
+	// string is returned when no content has been fetched yet
+	Path() string
+
+	// Accept records content in provided directory as installed. Content
+	// fetched before the accepted one is removed.
+	Accept(path string)
+}
+
+// Watch function calls Fetch periodically until the context is cancelled
+// and calls onChange when new content has been fetched
+func Watch(ctx context.Context, source Source, interval time.Duration, onChange func(path string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		path, changed, err := source.Fetch(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to check rule content source")
+			continue
+		}
+		if changed {
+			log.Info().Str("path", path).Msg("New rule content fetched")
+			onChange(path)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import "time"
+
+// Configuration represents configuration of outgoing webhook notifications
+type Configuration struct {
+	// URLs contains all webhook URLs that are notified, notifications are
+	// disabled when no URL is configured
+	URLs []string `mapstructure:"urls" toml:"urls"`
+	// Secret is key used to sign payloads by HMAC-SHA256
+	Secret string `mapstructure:"secret" toml:"secret"`
+	// MaxRetries is number of retries after the first failed attempt
+	MaxRetries int `mapstructure:"max_retries" toml:"max_retries"`
+	// InitialBackoff is time to wait before the first retry, it is doubled
+	// for each subsequent retry
+	InitialBackoff time.Duration `mapstructure:"initial_backoff" toml:"initial_backoff"`
+	// MaxBackoff limits time to wait between retries
+	MaxBackoff time.Duration `mapstructure:"max_backoff" toml:"max_backoff"`
+	// Timeout is timeout of one delivery attempt
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+	// DeliveryLog is path to file where outcomes of all deliveries are
+	// persisted, the log is not persisted when not set
+	DeliveryLog string `mapstructure:"delivery_log" toml:"delivery_log"`
+	// MaxDeliveryLogSize is size of delivery log in bytes when the log is
+	// compacted to recent deliveries
+	MaxDeliveryLogSize int64 `mapstructure:"max_delivery_log_size" toml:"max_delivery_log_size"`
+}
+
+// Enabled returns true if at least one webhook URL is configured
+func (configuration Configuration) Enabled() bool {
+	return len(configuration.URLs) > 0
+}
@@ -0,0 +1,469 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks contains implementation of outgoing webhook
+// notifications. Payloads are sent as JSON in POST requests and they are
+// signed by HMAC-SHA256, failed deliveries are retried with exponential
+// backoff and outcomes of all deliveries are recorded in delivery log.
+package webhooks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Headers sent with each webhook request
+const (
+	// SignatureHeader contains HMAC-SHA256 signature of timestamp and
+	// request body in form sha256=<hex digest>
+	SignatureHeader = "X-Content-Service-Signature"
+	// TimestampHeader contains time of the delivery attempt as Unix time
+	// in seconds, it is signed together with request body
+	TimestampHeader = "X-Content-Service-Timestamp"
+	// EventHeader contains type of the event
+	EventHeader = "X-Content-Service-Event"
+	// DeliveryHeader contains unique ID of the delivery
+	DeliveryHeader = "X-Content-Service-Delivery"
+
+	signaturePrefix = "sha256="
+)
+
+// Default values used when they are not configured
+const (
+	DefaultInitialBackoff = 1 * time.Second
+	DefaultMaxBackoff     = 1 * time.Minute
+	DefaultTimeout        = 10 * time.Second
+	// DefaultMaxDeliveryLogSize is size of delivery log in bytes when it
+	// is compacted
+	DefaultMaxDeliveryLogSize = 1024 * 1024
+)
+
+const (
+	// maxPendingDeliveries limits number of deliveries in progress
+	maxPendingDeliveries = 100
+	// deliveriesHistorySize is number of deliveries kept in memory
+	deliveriesHistorySize = 100
+)
+
+// Status of delivery
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Delivery describes one attempt to notify one webhook URL
+type Delivery struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Event      string    `json:"event"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Dispatcher sends webhook notifications
+type Dispatcher struct {
+	config  Configuration
+	client  *http.Client
+	pending chan struct{}
+
+	mutex      sync.Mutex
+	deliveries []*Delivery
+	// stopped is set when the dispatcher is stopped, no deliveries are
+	// started afterwards
+	stopped bool
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// New constructs new dispatcher. Deliveries recorded in delivery log are
+// loaded when the log exists.
+func New(config Configuration) (*Dispatcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	dispatcher := &Dispatcher{
+		config:  config,
+		client:  &http.Client{Timeout: durationOrDefault(config.Timeout, DefaultTimeout)},
+		pending: make(chan struct{}, maxPendingDeliveries),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if config.DeliveryLog != "" {
+		if err := dispatcher.loadDeliveryLog(); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	return dispatcher, nil
+}
+
+// Sign returns signature of payload as sent in SignatureHeader. Timestamp
+// sent in TimestampHeader is signed together with the payload in form
+// <timestamp>.<payload>, so receivers can refuse replayed deliveries.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + "."))
+	_, _ = mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if signature matches the payload and the timestamp and
+// the timestamp differs from the current time by tolerance at most
+func Verify(secret, timestamp string, payload []byte, signature string, tolerance time.Duration) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return false
+	}
+
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
+
+// Notify sends payload encoded as JSON to all configured webhook URLs. The
+// deliveries are performed asynchronously, so this method never blocks.
+func (dispatcher *Dispatcher) Notify(event string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Str("event", event).Msg("Unable to encode webhook payload")
+		return
+	}
+
+	if !dispatcher.begin() {
+		log.Warn().Str("event", event).Msg("Webhook dispatcher is stopped, notification is not sent")
+		return
+	}
+	defer dispatcher.running.Done()
+
+	for _, url := range dispatcher.config.URLs {
+		delivery := dispatcher.newDelivery(url, event)
+
+		select {
+		case dispatcher.pending <- struct{}{}:
+			dispatcher.running.Add(1)
+			go dispatcher.deliver(delivery, body)
+		default:
+			dispatcher.finish(delivery, 0, fmt.Errorf("too many pending deliveries"))
+		}
+	}
+}
+
+// Deliveries returns recorded deliveries, the newest one first
+func (dispatcher *Dispatcher) Deliveries() []Delivery {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	deliveries := make([]Delivery, len(dispatcher.deliveries))
+	for i, delivery := range dispatcher.deliveries {
+		deliveries[len(deliveries)-1-i] = *delivery
+	}
+	return deliveries
+}
+
+// Stop cancels all retries and waits for deliveries in progress to finish
+func (dispatcher *Dispatcher) Stop() {
+	dispatcher.mutex.Lock()
+	dispatcher.stopped = true
+	dispatcher.mutex.Unlock()
+
+	dispatcher.cancel()
+	dispatcher.running.Wait()
+}
+
+// begin registers notification in progress, so Stop waits for deliveries it
+// starts. False is returned when the dispatcher has been stopped already.
+func (dispatcher *Dispatcher) begin() bool {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	if dispatcher.stopped {
+		return false
+	}
+	dispatcher.running.Add(1)
+	return true
+}
+
+// newDelivery records new pending delivery
+func (dispatcher *Dispatcher) newDelivery(url, event string) *Delivery {
+	now := time.Now().UTC()
+	delivery := &Delivery{
+		ID:        newDeliveryID(),
+		URL:       url,
+		Event:     event,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	dispatcher.record(delivery)
+	return delivery
+}
+
+// record adds delivery to history. The mutex needs to be locked.
+func (dispatcher *Dispatcher) record(delivery *Delivery) {
+	dispatcher.deliveries = append(dispatcher.deliveries, delivery)
+	if len(dispatcher.deliveries) > deliveriesHistorySize {
+		dispatcher.deliveries = dispatcher.deliveries[len(dispatcher.deliveries)-deliveriesHistorySize:]
+	}
+}
+
+// deliver sends payload to webhook URL and retries with exponential backoff
+// when the attempt fails
+func (dispatcher *Dispatcher) deliver(delivery *Delivery, body []byte) {
+	defer dispatcher.running.Done()
+	defer func() { <-dispatcher.pending }()
+
+	backoff := durationOrDefault(dispatcher.config.InitialBackoff, DefaultInitialBackoff)
+	maxBackoff := durationOrDefault(dispatcher.config.MaxBackoff, DefaultMaxBackoff)
+
+	for attempt := 0; ; attempt++ {
+		statusCode, retry, err := dispatcher.attempt(delivery, body)
+
+		dispatcher.mutex.Lock()
+		delivery.Attempts++
+		dispatcher.mutex.Unlock()
+
+		if err == nil || !retry || attempt >= dispatcher.config.MaxRetries {
+			dispatcher.finish(delivery, statusCode, err)
+			return
+		}
+
+		log.Warn().Err(err).
+			Str("url", delivery.URL).
+			Dur("backoff", backoff).
+			Msg("Webhook delivery failed, retrying")
+
+		select {
+		case <-dispatcher.ctx.Done():
+			dispatcher.finish(delivery, statusCode, fmt.Errorf("delivery cancelled: %v", err))
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// attempt performs one delivery attempt. Flag whether the attempt can be
+// retried is returned together with error.
+func (dispatcher *Dispatcher) attempt(delivery *Delivery, body []byte) (int, bool, error) {
+	request, err := http.NewRequestWithContext(dispatcher.ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+
+	// each attempt is signed with its own timestamp
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(dispatcher.config.Secret, timestamp, body))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, true, err
+	}
+	_ = response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return response.StatusCode, false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return response.StatusCode, true, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	default:
+		return response.StatusCode, false, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+}
+
+// finish records the outcome of delivery and appends it to delivery log
+func (dispatcher *Dispatcher) finish(delivery *Delivery, statusCode int, err error) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	delivery.StatusCode = statusCode
+	delivery.UpdatedAt = time.Now().UTC()
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+		log.Error().Err(err).Str("url", delivery.URL).Str("event", delivery.Event).Msg("Webhook delivery failed")
+	} else {
+		delivery.Status = StatusDelivered
+		log.Info().Str("url", delivery.URL).Str("event", delivery.Event).Msg("Webhook delivered")
+	}
+
+	if dispatcher.config.DeliveryLog != "" {
+		if err := dispatcher.appendDeliveryLog(delivery); err != nil {
+			log.Error().Err(err).Msg("Unable to write webhook delivery log")
+		}
+	}
+}
+
+// appendDeliveryLog writes delivery as one JSON line to delivery log. The log
+// is compacted when it grows over configured size. The mutex needs to be
+// locked.
+func (dispatcher *Dispatcher) appendDeliveryLog(delivery *Delivery) error {
+	file, err := os.OpenFile(filepath.Clean(dispatcher.config.DeliveryLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(file).Encode(delivery)
+	var fileInfo os.FileInfo
+	if err == nil {
+		fileInfo, err = file.Stat()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Size() > dispatcher.maxDeliveryLogSize() {
+		return dispatcher.compactDeliveryLog()
+	}
+	return nil
+}
+
+// compactDeliveryLog replaces delivery log by the newest finished deliveries
+// kept in memory that fit into half of configured size, so the log is not
+// compacted by each append. The log is written into temporary file first, so
+// it is never truncated partially. The mutex needs to be locked.
+func (dispatcher *Dispatcher) compactDeliveryLog() error {
+	var lines [][]byte
+	size := 0
+	for i := len(dispatcher.deliveries) - 1; i >= 0; i-- {
+		if dispatcher.deliveries[i].Status == StatusPending {
+			continue
+		}
+		line, err := json.Marshal(dispatcher.deliveries[i])
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if int64(size+len(line)) > dispatcher.maxDeliveryLogSize()/2 {
+			break
+		}
+		size += len(line)
+		lines = append(lines, line)
+	}
+
+	path := filepath.Clean(dispatcher.config.DeliveryLog)
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	// deliveries are stored from the oldest one
+	for i := len(lines) - 1; i >= 0 && err == nil; i-- {
+		_, err = file.Write(lines[i])
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// maxDeliveryLogSize returns configured size of delivery log
+func (dispatcher *Dispatcher) maxDeliveryLogSize() int64 {
+	if dispatcher.config.MaxDeliveryLogSize <= 0 {
+		return DefaultMaxDeliveryLogSize
+	}
+	return dispatcher.config.MaxDeliveryLogSize
+}
+
+// loadDeliveryLog reads deliveries recorded in delivery log
+func (dispatcher *Dispatcher) loadDeliveryLog() error {
+	file, err := os.Open(filepath.Clean(dispatcher.config.DeliveryLog))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		delivery := &Delivery{}
+		if err := json.Unmarshal(scanner.Bytes(), delivery); err != nil {
+			log.Warn().Err(err).Msg("Skipping malformed webhook delivery log entry")
+			continue
+		}
+		dispatcher.record(delivery)
+	}
+	return scanner.Err()
+}
+
+// newDeliveryID returns random delivery ID
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// durationOrDefault returns the provided duration or the default one when
+// the duration is not set
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+	return duration
+}
@@ -0,0 +1,362 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-content-service/webhooks"
+)
+
+const secret = "webhook-secret"
+
+// receivedRequest is request recorded by test receiver
+type receivedRequest struct {
+	body      []byte
+	event     string
+	delivery  string
+	timestamp string
+	signature string
+}
+
+// receiver is local HTTP server that records all received requests and
+// responds with predefined status codes
+type receiver struct {
+	mutex    sync.Mutex
+	requests []receivedRequest
+	statuses []int
+	server   *httptest.Server
+}
+
+// newReceiver starts receiver that responds with given status codes, the
+// last one is used for all remaining requests
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		assert.NoError(t, err)
+
+		r.mutex.Lock()
+		r.requests = append(r.requests, receivedRequest{
+			body:      body,
+			event:     request.Header.Get(webhooks.EventHeader),
+			delivery:  request.Header.Get(webhooks.DeliveryHeader),
+			timestamp: request.Header.Get(webhooks.TimestampHeader),
+			signature: request.Header.Get(webhooks.SignatureHeader),
+		})
+		status := r.statuses[0]
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+		r.mutex.Unlock()
+
+		writer.WriteHeader(status)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]receivedRequest{}, r.requests...)
+}
+
+// testConfig returns configuration with short backoff
+func testConfig(urls ...string) webhooks.Configuration {
+	return webhooks.Configuration{
+		URLs:           urls,
+		Secret:         secret,
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+	}
+}
+
+// notifyAndWait sends notification and waits for all deliveries to finish
+func notifyAndWait(t *testing.T, dispatcher *webhooks.Dispatcher, event string, payload interface{}) {
+	dispatcher.Notify(event, payload)
+
+	assert.Eventually(t, func() bool {
+		for _, delivery := range dispatcher.Deliveries() {
+			if delivery.Status == webhooks.StatusPending {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, time.Millisecond)
+}
+
+// TestSignAndVerify checks signing of payloads together with timestamps
+func TestSignAndVerify(t *testing.T) {
+	payload := []byte(`{"type":"snapshot"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := webhooks.Sign(secret, timestamp, payload)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, webhooks.Verify(secret, timestamp, payload, signature, time.Minute))
+	assert.False(t, webhooks.Verify("other-secret", timestamp, payload, signature, time.Minute))
+	assert.False(t, webhooks.Verify(secret, timestamp, []byte(`{}`), signature, time.Minute))
+	assert.False(t, webhooks.Verify(secret, "", payload, signature, time.Minute))
+
+	// timestamp can't be changed without changing the signature
+	otherTimestamp := strconv.FormatInt(time.Now().Unix()-1, 10)
+	assert.False(t, webhooks.Verify(secret, otherTimestamp, payload, signature, time.Minute))
+}
+
+// TestVerifyReplayed checks that signature with old timestamp is refused
+func TestVerifyReplayed(t *testing.T) {
+	payload := []byte(`{"type":"snapshot"}`)
+	timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	signature := webhooks.Sign(secret, timestamp, payload)
+
+	assert.False(t, webhooks.Verify(secret, timestamp, payload, signature, 5*time.Minute))
+	assert.True(t, webhooks.Verify(secret, timestamp, payload, signature, 2*time.Hour))
+}
+
+// TestNotifyDelivered checks that signed payload is sent to all URLs
+func TestNotifyDelivered(t *testing.T) {
+	first := newReceiver(t, http.StatusOK)
+	second := newReceiver(t, http.StatusNoContent)
+
+	dispatcher, err := webhooks.New(testConfig(first.server.URL, second.server.URL))
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	notifyAndWait(t, dispatcher, "snapshot", map[string]int{"added": 1})
+
+	for _, r := range []*receiver{first, second} {
+		requests := r.received()
+		assert.Len(t, requests, 1)
+		assert.JSONEq(t, `{"added": 1}`, string(requests[0].body))
+		assert.Equal(t, "snapshot", requests[0].event)
+		assert.NotEmpty(t, requests[0].delivery)
+		assert.True(t, webhooks.Verify(secret, requests[0].timestamp, requests[0].body, requests[0].signature, time.Minute))
+	}
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		assert.Equal(t, webhooks.StatusDelivered, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Empty(t, delivery.Error)
+	}
+}
+
+// TestNotifyRetried checks that server errors are retried
+func TestNotifyRetried(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+
+	dispatcher, err := webhooks.New(testConfig(r.server.URL))
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	notifyAndWait(t, dispatcher, "snapshot", "payload")
+
+	requests := r.received()
+	assert.Len(t, requests, 3)
+	// the same delivery is retried
+	assert.Equal(t, requests[0].delivery, requests[2].delivery)
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, webhooks.StatusDelivered, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+}
+
+// TestNotifyRetriesExhausted checks that delivery fails after configured
+// number of retries
+func TestNotifyRetriesExhausted(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError)
+
+	dispatcher, err := webhooks.New(testConfig(r.server.URL))
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	notifyAndWait(t, dispatcher, "reload_failed", "payload")
+
+	assert.Len(t, r.received(), 4)
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, webhooks.StatusFailed, deliveries[0].Status)
+	assert.Equal(t, 4, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	assert.Contains(t, deliveries[0].Error, "500")
+}
+
+// TestNotifyClientErrorNotRetried checks that client errors are not retried
+func TestNotifyClientErrorNotRetried(t *testing.T) {
+	r := newReceiver(t, http.StatusBadRequest)
+
+	dispatcher, err := webhooks.New(testConfig(r.server.URL))
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	notifyAndWait(t, dispatcher, "snapshot", "payload")
+
+	assert.Len(t, r.received(), 1)
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, webhooks.StatusFailed, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+// TestNotifyUnreachable checks that network errors are retried and reported
+func TestNotifyUnreachable(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	url := r.server.URL
+	r.server.Close()
+
+	config := testConfig(url)
+	config.MaxRetries = 1
+	dispatcher, err := webhooks.New(config)
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	notifyAndWait(t, dispatcher, "snapshot", "payload")
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, webhooks.StatusFailed, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Zero(t, deliveries[0].StatusCode)
+	assert.NotEmpty(t, deliveries[0].Error)
+}
+
+// TestNotifyStopped checks that no deliveries are started when the
+// dispatcher has been stopped
+func TestNotifyStopped(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	dispatcher, err := webhooks.New(testConfig(r.server.URL))
+	assert.NoError(t, err)
+
+	dispatcher.Stop()
+	dispatcher.Notify("snapshot", "payload")
+
+	assert.Empty(t, dispatcher.Deliveries())
+}
+
+// TestNotifyWhileStopping checks that notifications sent concurrently with
+// Stop are handled safely
+func TestNotifyWhileStopping(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	dispatcher, err := webhooks.New(testConfig(r.server.URL))
+	assert.NoError(t, err)
+
+	var notifying sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		notifying.Add(1)
+		go func() {
+			defer notifying.Done()
+			dispatcher.Notify("snapshot", "payload")
+		}()
+	}
+	dispatcher.Stop()
+	notifying.Wait()
+
+	for _, delivery := range dispatcher.Deliveries() {
+		assert.NotEqual(t, webhooks.StatusPending, delivery.Status)
+	}
+}
+
+// TestDeliveryLog checks that deliveries are persisted and loaded again
+func TestDeliveryLog(t *testing.T) {
+	r := newReceiver(t, http.StatusOK, http.StatusBadRequest)
+
+	config := testConfig(r.server.URL)
+	config.DeliveryLog = filepath.Join(t.TempDir(), "deliveries.log")
+
+	dispatcher, err := webhooks.New(config)
+	assert.NoError(t, err)
+
+	notifyAndWait(t, dispatcher, "snapshot", "first")
+	notifyAndWait(t, dispatcher, "reload_failed", "second")
+	dispatcher.Stop()
+
+	expected := dispatcher.Deliveries()
+	assert.Len(t, expected, 2)
+
+	dispatcher, err = webhooks.New(config)
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	deliveries := dispatcher.Deliveries()
+	assert.Len(t, deliveries, 2)
+	for i := range deliveries {
+		assert.Equal(t, expected[i].ID, deliveries[i].ID)
+		assert.Equal(t, expected[i].Event, deliveries[i].Event)
+		assert.Equal(t, expected[i].Status, deliveries[i].Status)
+		assert.Equal(t, expected[i].StatusCode, deliveries[i].StatusCode)
+	}
+	assert.Equal(t, "reload_failed", deliveries[0].Event)
+	assert.Equal(t, webhooks.StatusFailed, deliveries[0].Status)
+}
+
+// TestDeliveryLogCompacted checks that delivery log is compacted when it
+// grows over configured size
+func TestDeliveryLogCompacted(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+
+	config := testConfig(r.server.URL)
+	config.DeliveryLog = filepath.Join(t.TempDir(), "deliveries.log")
+	config.MaxDeliveryLogSize = 4096
+
+	dispatcher, err := webhooks.New(config)
+	assert.NoError(t, err)
+
+	for i := 0; i < 150; i++ {
+		notifyAndWait(t, dispatcher, "snapshot", i)
+	}
+	dispatcher.Stop()
+
+	fileInfo, err := os.Stat(config.DeliveryLog)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, fileInfo.Size(), config.MaxDeliveryLogSize)
+
+	// the newest deliveries are kept
+	expected := dispatcher.Deliveries()
+	dispatcher, err = webhooks.New(config)
+	assert.NoError(t, err)
+	defer dispatcher.Stop()
+
+	deliveries := dispatcher.Deliveries()
+	assert.NotEmpty(t, deliveries)
+	assert.Equal(t, expected[0].ID, deliveries[0].ID)
+}
+
+// TestDeliveryLogInvalidPath checks that unreadable delivery log is reported
+func TestDeliveryLogInvalidPath(t *testing.T) {
+	config := testConfig("http://localhost")
+	config.DeliveryLog = t.TempDir()
+
+	_, err := webhooks.New(config)
+	assert.Error(t, err)
+}